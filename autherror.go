@@ -0,0 +1,133 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// Errors returned when a token can't be obtained.
+var (
+	// ErrStateMismatch is returned if the state of the authorization response
+	// doesn't match the request, which may be Cross-Site Request Forgery (CSRF).
+	ErrStateMismatch = errors.New("msgraph4go: state mismatch, potential Cross-Site Request Forgery (CSRF)")
+
+	// ErrInteractiveLoginRequired is returned if the user must sign in again,
+	// e.g. the refresh token expired or was revoked, and no LoginFunc is set.
+	ErrInteractiveLoginRequired = errors.New("msgraph4go: interactive login required")
+
+	// ErrConsentRequired is returned if the user, or an administrator, must
+	// consent to scopes that haven't been granted to the application.
+	ErrConsentRequired = errors.New("msgraph4go: consent required for the requested scopes")
+)
+
+// AuthError is an error response from the Microsoft identity platform.
+//
+// Use errors.Is with ErrInteractiveLoginRequired or ErrConsentRequired to
+// check if the user must sign in again or consent to new scopes.
+type AuthError struct {
+	// Op is the operation that failed, e.g. "authorize", "exchange", "refresh", or "client credentials".
+	Op string `json:"-"`
+
+	// StatusCode is the HTTP status code of the response, if any.
+	StatusCode int `json:"-"`
+
+	// Code is the OAuth 2.0 error code, e.g. "invalid_grant".
+	Code string `json:"error"`
+
+	// Description is a message describing the error.
+	Description string `json:"error_description"`
+
+	// ErrorCodes are the AADSTS error codes, e.g. 65001 when consent is required.
+	// See https://docs.microsoft.com/en-us/azure/active-directory/develop/reference-aadsts-error-codes
+	ErrorCodes []int `json:"error_codes"`
+
+	// Err is the underlying error, if any.
+	Err error `json:"-"`
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("msgraph4go: %s failed: %s: %s", e.Op, e.Code, e.Description)
+}
+
+// Unwrap returns the underlying error.
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether e matches ErrConsentRequired or ErrInteractiveLoginRequired.
+func (e *AuthError) Is(target error) bool {
+	switch target {
+	case ErrConsentRequired:
+		return e.consentRequired()
+	case ErrInteractiveLoginRequired:
+		if e.consentRequired() {
+			return false
+		}
+		switch e.Code {
+		case "interaction_required", "login_required", "invalid_grant":
+			return true
+		}
+	}
+
+	return false
+}
+
+// consentRequired returns true if the error requires consent to new scopes.
+func (e *AuthError) consentRequired() bool {
+	if e.Code == "consent_required" {
+		return true
+	}
+
+	// the error of an authorize redirect only has the code in the description
+	if strings.HasPrefix(e.Description, "AADSTS65001") {
+		return true
+	}
+
+	for _, code := range e.ErrorCodes {
+		// AADSTS65001: the user or administrator has not consented to use the application
+		if code == 65001 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// authError returns an AuthError for op if err is a token endpoint error response, otherwise err.
+func authError(op string, err error) error {
+	var retrieveErr *oauth2.RetrieveError
+	if !errors.As(err, &retrieveErr) {
+		return err
+	}
+
+	authErr := &AuthError{Op: op, Err: err}
+	if retrieveErr.Response != nil {
+		authErr.StatusCode = retrieveErr.Response.StatusCode
+	}
+
+	if json.Unmarshal(retrieveErr.Body, authErr) != nil || authErr.Code == "" {
+		return err
+	}
+
+	return authErr
+}
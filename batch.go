@@ -0,0 +1,367 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// maxBatchSize is the maximum number of requests in a single JSON batch.
+const maxBatchSize = 20
+
+// BatchRequest is a single request within a JSON batch.
+//
+// See https://docs.microsoft.com/en-us/graph/json-batching for more information.
+type BatchRequest struct {
+	// ID identifies the request within the batch. If empty, the position
+	// of the request, starting at 1, is used.
+	ID string `json:"id"`
+
+	// Method is the HTTP method of the request, e.g. "GET" or "PATCH".
+	Method string `json:"method"`
+
+	// URL is relative to the Graph API base URL, e.g. "/me/messages?$top=5".
+	URL string `json:"url"`
+
+	// Headers are additional headers for the request.
+	// The Content-Type defaults to "application/json" if Body is set.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Body is encoded as JSON. Use json.RawMessage to provide encoded JSON.
+	Body interface{} `json:"body,omitempty"`
+
+	// DependsOn are the IDs of requests that must complete before this request is executed.
+	// Requests that depend on each other are always sent in the same batch.
+	DependsOn []string `json:"dependsOn,omitempty"`
+}
+
+// BatchResponse is the response to a single request within a JSON batch.
+type BatchResponse struct {
+	// ID is the ID of the corresponding BatchRequest.
+	ID string `json:"id"`
+
+	// Status is the HTTP status code of the response.
+	Status int `json:"status"`
+
+	// Headers are the headers of the response.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Body is the JSON body of the response, if any.
+	Body json.RawMessage `json:"body,omitempty"`
+}
+
+// batchRequestBody is the body of a $batch request.
+type batchRequestBody struct {
+	Requests []BatchRequest `json:"requests"`
+}
+
+// batchResponseBody is the body of a $batch response.
+type batchResponseBody struct {
+	Responses []BatchResponse `json:"responses"`
+}
+
+// Err returns a GraphErrorResponse if the request failed, otherwise nil.
+func (r *BatchResponse) Err() error {
+	if !codeIsError(r.Status) {
+		return nil
+	}
+
+	header := make(http.Header, len(r.Headers))
+	for k, v := range r.Headers {
+		header.Set(k, v)
+	}
+
+	return newGraphError(r.Status, header, r.Body)
+}
+
+// Decode unmarshals the body of the response into v, e.g. a *Message.
+//
+// If the request failed, then the GraphErrorResponse is returned instead.
+func (r *BatchResponse) Decode(v interface{}) error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(r.Body, v)
+}
+
+// Batch combines requests into JSON batches, returning the responses in the same order as requests.
+//
+// Requests are sent in batches of up to 20 requests. Requests related by
+// DependsOn are kept in the same batch, so a chain of dependent requests
+// cannot exceed 20 requests, and each is sent after the requests it
+// depends on. A circular dependency is an error.
+//
+// An error is returned only if a batch could not be sent. Use the Err or
+// Decode method of each BatchResponse to check the individual requests.
+func (c *MSGraphClient) Batch(requests []BatchRequest) (responses []BatchResponse, err error) {
+	return c.BatchWithContext(context.Background(), requests)
+}
+
+// BatchWithContext is like Batch, but uses ctx for the request.
+func (c *MSGraphClient) BatchWithContext(ctx context.Context, requests []BatchRequest) (responses []BatchResponse, err error) {
+	requests, err = prepareBatch(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	for n := range requests {
+		requests[n].URL, err = c.resolveMe(requests[n].URL)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	chunks, err := chunkBatch(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	// the conditions of ctx apply to a single request, not to the batch
+	ctx = withoutConditions(ctx)
+
+	// map each request ID to the response
+	byID := make(map[string]BatchResponse, len(requests))
+
+	for _, chunk := range chunks {
+		data, err := json.Marshal(batchRequestBody{Requests: chunk})
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := c.send(ctx, http.MethodPost, "/$batch", nil, bytes.NewReader(data), "application/json")
+		if err != nil {
+			return nil, err
+		}
+
+		var result batchResponseBody
+		err = json.Unmarshal(body, &result)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, resp := range result.Responses {
+			byID[resp.ID] = resp
+		}
+	}
+
+	responses = make([]BatchResponse, len(requests))
+	for n, req := range requests {
+		resp, ok := byID[req.ID]
+		if !ok {
+			return nil, fmt.Errorf("msgraph4go: no response for batch request %q", req.ID)
+		}
+		responses[n] = resp
+	}
+
+	return responses, nil
+}
+
+// prepareBatch returns a copy of requests with default IDs and headers set.
+func prepareBatch(requests []BatchRequest) ([]BatchRequest, error) {
+	prepared := make([]BatchRequest, len(requests))
+	ids := make(map[string]bool, len(requests))
+
+	for n, req := range requests {
+		if req.ID == "" {
+			req.ID = strconv.Itoa(n + 1)
+		}
+		if ids[req.ID] {
+			return nil, fmt.Errorf("msgraph4go: duplicate batch request id %q", req.ID)
+		}
+		ids[req.ID] = true
+
+		if req.Method == "" {
+			req.Method = http.MethodGet
+		}
+
+		if req.Body != nil && !hasHeader(req.Headers, "Content-Type") {
+			req.Headers = withHeader(req.Headers, "Content-Type", "application/json")
+		}
+
+		if !hasHeader(req.Headers, "ConsistencyLevel") && needsEventualConsistency(splitBatchURL(req.URL)) {
+			req.Headers = withHeader(req.Headers, "ConsistencyLevel", "eventual")
+		}
+
+		prepared[n] = req
+	}
+
+	for _, req := range prepared {
+		for _, id := range req.DependsOn {
+			if !ids[id] {
+				return nil, fmt.Errorf("msgraph4go: batch request %q depends on unknown id %q", req.ID, id)
+			}
+		}
+	}
+
+	return prepared, nil
+}
+
+// chunkBatch splits requests into batches of up to maxBatchSize requests,
+// keeping requests related by DependsOn in the same batch.
+func chunkBatch(requests []BatchRequest) ([][]BatchRequest, error) {
+	// group related requests using union-find on the request positions
+	parent := make([]int, len(requests))
+	for n := range parent {
+		parent[n] = n
+	}
+
+	var find func(int) int
+	find = func(n int) int {
+		if parent[n] != n {
+			parent[n] = find(parent[n])
+		}
+		return parent[n]
+	}
+
+	position := make(map[string]int, len(requests))
+	for n, req := range requests {
+		position[req.ID] = n
+	}
+
+	for n, req := range requests {
+		for _, id := range req.DependsOn {
+			parent[find(n)] = find(position[id])
+		}
+	}
+
+	// collect groups, in order of their first request
+	var groups [][]BatchRequest
+	groupOf := make(map[int]int)
+	for n, req := range requests {
+		root := find(n)
+		g, ok := groupOf[root]
+		if !ok {
+			g = len(groups)
+			groupOf[root] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], req)
+	}
+
+	// pack groups into batches
+	var chunks [][]BatchRequest
+	var chunk []BatchRequest
+	for _, group := range groups {
+		if len(group) > maxBatchSize {
+			return nil, fmt.Errorf("msgraph4go: %d dependent batch requests exceed the limit of %d",
+				len(group), maxBatchSize)
+		}
+
+		group, err := orderGroup(group)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(chunk)+len(group) > maxBatchSize {
+			chunks = append(chunks, chunk)
+			chunk = nil
+		}
+		chunk = append(chunk, group...)
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, nil
+}
+
+// orderGroup returns group ordered so that each request follows the
+// requests it depends on, otherwise keeping the order of the requests.
+func orderGroup(group []BatchRequest) ([]BatchRequest, error) {
+	byID := make(map[string]BatchRequest, len(group))
+	for _, req := range group {
+		byID[req.ID] = req
+	}
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[string]int, len(group))
+	ordered := make([]BatchRequest, 0, len(group))
+
+	var visit func(req BatchRequest) error
+	visit = func(req BatchRequest) error {
+		switch state[req.ID] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf("msgraph4go: batch request %q has a circular dependency", req.ID)
+		}
+
+		state[req.ID] = visiting
+		for _, id := range req.DependsOn {
+			if err := visit(byID[id]); err != nil {
+				return err
+			}
+		}
+		state[req.ID] = visited
+
+		ordered = append(ordered, req)
+		return nil
+	}
+
+	for _, req := range group {
+		if err := visit(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return ordered, nil
+}
+
+// hasHeader returns true if headers contains name, ignoring case.
+func hasHeader(headers map[string]string, name string) bool {
+	for k := range headers {
+		if http.CanonicalHeaderKey(k) == http.CanonicalHeaderKey(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// withHeader returns a copy of headers with name set to value.
+func withHeader(headers map[string]string, name string, value string) map[string]string {
+	copied := make(map[string]string, len(headers)+1)
+	for k, v := range headers {
+		copied[k] = v
+	}
+	copied[name] = value
+
+	return copied
+}
+
+// splitBatchURL returns the path and query parameters of the URL of a batch request.
+func splitBatchURL(urlString string) (path string, query url.Values) {
+	n := strings.Index(urlString, "?")
+	if n < 0 {
+		return urlString, nil
+	}
+
+	query, _ = url.ParseQuery(urlString[n+1:])
+
+	return urlString[:n], query
+}
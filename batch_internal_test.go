@@ -0,0 +1,277 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// batchIDs returns the IDs of the requests of each chunk.
+func batchIDs(chunks [][]BatchRequest) [][]string {
+	ids := make([][]string, len(chunks))
+	for n, chunk := range chunks {
+		for _, req := range chunk {
+			ids[n] = append(ids[n], req.ID)
+		}
+	}
+
+	return ids
+}
+
+// numberedRequests returns n requests with the IDs "1" to n.
+func numberedRequests(n int) []BatchRequest {
+	requests := make([]BatchRequest, n)
+	for i := range requests {
+		requests[i] = BatchRequest{ID: fmt.Sprint(i + 1), URL: "/me"}
+	}
+
+	return requests
+}
+
+func TestChunkBatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		requests  func() []BatchRequest
+		want      []int // the number of requests in each chunk
+		wantFirst []string
+	}{
+		{
+			name:     "single chunk",
+			requests: func() []BatchRequest { return numberedRequests(20) },
+			want:     []int{20},
+		},
+		{
+			name:     "split at limit",
+			requests: func() []BatchRequest { return numberedRequests(45) },
+			want:     []int{20, 20, 5},
+		},
+		{
+			// 19 independent requests, then a chain of 3 that doesn't fit in the first chunk
+			name: "dependent requests kept together",
+			requests: func() []BatchRequest {
+				requests := numberedRequests(22)
+				requests[20].DependsOn = []string{"20"}
+				requests[21].DependsOn = []string{"21"}
+				return requests
+			},
+			want:      []int{19, 3},
+			wantFirst: []string{"20", "21", "22"},
+		},
+		{
+			// requests 1 and 22 are related through a common dependency
+			name: "transitive group",
+			requests: func() []BatchRequest {
+				requests := numberedRequests(22)
+				requests[0].DependsOn = []string{"10"}
+				requests[21].DependsOn = []string{"10"}
+				return requests
+			},
+			want:      []int{20, 2},
+			wantFirst: []string{"1", "10", "22"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := chunkBatch(tt.requests())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sizes []int
+			for _, chunk := range chunks {
+				sizes = append(sizes, len(chunk))
+			}
+			if fmt.Sprint(sizes) != fmt.Sprint(tt.want) {
+				t.Errorf("chunk sizes = %v, want %v", sizes, tt.want)
+			}
+
+			// the related requests are in the same chunk
+			if tt.wantFirst != nil {
+				chunkOf := make(map[string]int)
+				for n, ids := range batchIDs(chunks) {
+					for _, id := range ids {
+						chunkOf[id] = n
+					}
+				}
+				for _, id := range tt.wantFirst[1:] {
+					if chunkOf[id] != chunkOf[tt.wantFirst[0]] {
+						t.Errorf("request %s in chunk %d, request %s in chunk %d",
+							id, chunkOf[id], tt.wantFirst[0], chunkOf[tt.wantFirst[0]])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestChunkBatchOrder(t *testing.T) {
+	// c depends on b, which depends on a, but they are listed in reverse
+	requests := []BatchRequest{
+		{ID: "c", DependsOn: []string{"b"}},
+		{ID: "x"},
+		{ID: "b", DependsOn: []string{"a"}},
+		{ID: "a"},
+	}
+
+	chunks, err := chunkBatch(requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := fmt.Sprint(batchIDs(chunks))
+	if want := "[[a b c x]]"; got != want {
+		t.Errorf("order = %s, want %s", got, want)
+	}
+}
+
+func TestChunkBatchErrors(t *testing.T) {
+	// a chain of 21 requests can't be split across batches
+	chain := numberedRequests(21)
+	for n := 1; n < len(chain); n++ {
+		chain[n].DependsOn = []string{chain[n-1].ID}
+	}
+	if _, err := chunkBatch(chain); err == nil || !strings.Contains(err.Error(), "exceed the limit") {
+		t.Errorf("chain of 21: err = %v, want limit error", err)
+	}
+
+	cycle := []BatchRequest{
+		{ID: "a", DependsOn: []string{"b"}},
+		{ID: "b", DependsOn: []string{"a"}},
+	}
+	if _, err := chunkBatch(cycle); err == nil || !strings.Contains(err.Error(), "circular") {
+		t.Errorf("cycle: err = %v, want circular dependency error", err)
+	}
+}
+
+func TestPrepareBatch(t *testing.T) {
+	requests := []BatchRequest{
+		{URL: "/me/messages"},
+		{ID: "update", Method: http.MethodPatch, URL: "/me/messages/1", Body: map[string]bool{"isRead": true}},
+		{Method: http.MethodPost, URL: "/me/events", Body: "{}", Headers: map[string]string{"content-type": "text/plain"}},
+		{URL: "/users?$count=true&$search=%22displayName:bill%22", DependsOn: []string{"update"}},
+		{URL: "/me/messages?$search=%22report%22"},
+	}
+
+	prepared, err := prepareBatch(requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if prepared[0].ID != "1" || prepared[0].Method != http.MethodGet {
+		t.Errorf("default ID and method = %q %q, want 1 GET", prepared[0].ID, prepared[0].Method)
+	}
+	if prepared[1].Headers["Content-Type"] != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", prepared[1].Headers["Content-Type"])
+	}
+	if len(prepared[2].Headers) != 1 || prepared[2].Headers["content-type"] != "text/plain" {
+		t.Errorf("headers = %v, want the Content-Type unchanged", prepared[2].Headers)
+	}
+	if prepared[3].Headers["ConsistencyLevel"] != "eventual" {
+		t.Errorf("ConsistencyLevel of directory query = %q, want eventual", prepared[3].Headers["ConsistencyLevel"])
+	}
+	if _, ok := prepared[4].Headers["ConsistencyLevel"]; ok {
+		t.Errorf("ConsistencyLevel of message search = %q, want none", prepared[4].Headers["ConsistencyLevel"])
+	}
+
+	// the requests aren't modified
+	if requests[0].ID != "" || requests[1].Headers != nil {
+		t.Errorf("requests modified: %+v", requests[:2])
+	}
+
+	for _, bad := range [][]BatchRequest{
+		{{ID: "a"}, {ID: "a"}},
+		{{ID: "a", DependsOn: []string{"b"}}},
+	} {
+		if _, err := prepareBatch(bad); err == nil {
+			t.Errorf("prepareBatch(%+v) = nil error, want error", bad)
+		}
+	}
+}
+
+func TestWithoutConditions(t *testing.T) {
+	ctx := context.Background()
+	if withoutConditions(ctx) != ctx {
+		t.Error("withoutConditions changed a context without conditions")
+	}
+
+	ctx = withoutConditions(IfNoneMatch(IfMatch(ctx, `"1"`), `"2"`))
+	if cond, _ := ctx.Value(conditionsKey{}).(conditions); cond != (conditions{}) {
+		t.Errorf("conditions = %+v, want none", cond)
+	}
+}
+
+func TestBatchResponseErr(t *testing.T) {
+	tests := []struct {
+		resp   BatchResponse
+		target error
+		code   string
+	}{
+		{BatchResponse{Status: http.StatusOK}, nil, ""},
+		{BatchResponse{Status: http.StatusNoContent}, nil, ""},
+		{
+			BatchResponse{
+				Status: http.StatusNotFound,
+				Body:   []byte(`{"error":{"code":"ErrorItemNotFound","message":"The specified object was not found."}}`),
+			},
+			ErrNotFound, "ErrorItemNotFound",
+		},
+		{
+			BatchResponse{Status: http.StatusTooManyRequests, Headers: map[string]string{"Retry-After": "7"}},
+			ErrThrottled, "429",
+		},
+		{BatchResponse{Status: http.StatusPreconditionFailed}, ErrPreconditionFailed, "412"},
+		{BatchResponse{Status: http.StatusConflict}, ErrConflict, "409"},
+		{BatchResponse{Status: http.StatusUnauthorized}, ErrUnauthorized, "401"},
+	}
+
+	for _, tt := range tests {
+		err := tt.resp.Err()
+		if tt.target == nil {
+			if err != nil {
+				t.Errorf("status %d: err = %v, want nil", tt.resp.Status, err)
+			}
+			continue
+		}
+
+		if !errors.Is(err, tt.target) {
+			t.Errorf("status %d: err = %v, want %v", tt.resp.Status, err, tt.target)
+		}
+
+		var graphErr *GraphErrorResponse
+		if !errors.As(err, &graphErr) || graphErr.ODataError.Code != tt.code {
+			t.Errorf("status %d: err = %#v, want code %s", tt.resp.Status, err, tt.code)
+		}
+
+		var message Message
+		if decodeErr := tt.resp.Decode(&message); !errors.Is(decodeErr, tt.target) {
+			t.Errorf("status %d: Decode = %v, want %v", tt.resp.Status, decodeErr, tt.target)
+		}
+	}
+
+	throttled := BatchResponse{Status: http.StatusTooManyRequests, Headers: map[string]string{"retry-after": "7"}}
+	var graphErr *GraphErrorResponse
+	if errors.As(throttled.Err(), &graphErr); graphErr.RetryAfter != 7*time.Second {
+		t.Errorf("RetryAfter = %v, want 7s", graphErr.RetryAfter)
+	}
+}
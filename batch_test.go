@@ -0,0 +1,84 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/bnixon67/msgraph4go"
+	"github.com/bnixon67/msgraph4go/graphtest"
+)
+
+func TestBatchDependsOnAndConditions(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	message := server.AddMessage("inbox", msgraph4go.Message{Subject: "Hello"})
+
+	// the update is listed first, but depends on the get
+	requests := []msgraph4go.BatchRequest{
+		{ID: "update", Method: http.MethodPatch, URL: "/me/messages/" + message.ID,
+			Body: map[string]string{"subject": "Updated"}, DependsOn: []string{"get"}},
+		{ID: "get", URL: "/me/messages/" + message.ID},
+	}
+	for n := 0; n < 20; n++ {
+		requests = append(requests, msgraph4go.BatchRequest{URL: "/me"})
+	}
+
+	// the conditions apply to a single request, not to the requests of a batch
+	ctx := msgraph4go.IfNoneMatch(msgraph4go.IfMatch(context.Background(), `"stale"`), `"stale"`)
+	responses, err := server.Client().BatchWithContext(ctx, requests)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(responses) != len(requests) {
+		t.Fatalf("got %d responses, want %d", len(responses), len(requests))
+	}
+	for n, resp := range responses {
+		if resp.ID != requests[n].ID && requests[n].ID != "" {
+			t.Errorf("response %d has ID %q, want %q", n, resp.ID, requests[n].ID)
+		}
+		if err := resp.Err(); err != nil {
+			t.Errorf("response %s: %v", resp.ID, err)
+		}
+	}
+
+	var batches, get, update int
+	for n, r := range server.Requests() {
+		switch {
+		case r.Path == "/$batch":
+			batches++
+			if r.Header.Get("If-Match") != "" || r.Header.Get("If-None-Match") != "" {
+				t.Errorf("batch request has conditions: %v", r.Header)
+			}
+		case r.Path == "/me/messages/"+message.ID && r.Method == http.MethodGet:
+			get = n
+		case r.Path == "/me/messages/"+message.ID && r.Method == http.MethodPatch:
+			update = n
+		}
+	}
+
+	if batches != 2 {
+		t.Errorf("got %d batch requests, want 2", batches)
+	}
+	if get == 0 || update == 0 || get > update {
+		t.Errorf("get is request %d and update is request %d, want the get first", get, update)
+	}
+}
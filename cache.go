@@ -0,0 +1,533 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// maxCacheEntrySize is the maximum size of a response body that is cached.
+const maxCacheEntrySize = 4 << 20
+
+// cacheStatusHeader is the response header that reports how the cache handled a request (RFC 9211).
+const cacheStatusHeader = "Cache-Status"
+
+// CachedResponse is a response stored in a Cache.
+type CachedResponse struct {
+	// Header is the header of the response.
+	Header http.Header `json:"header"`
+
+	// Body is the response body.
+	Body []byte `json:"body"`
+
+	// ETag is used to revalidate the response once it is stale, if any.
+	ETag string `json:"etag,omitempty"`
+
+	// StoredAt is when the response was stored or last revalidated.
+	StoredAt time.Time `json:"storedAt"`
+}
+
+// size returns the approximate size of r in bytes.
+func (r *CachedResponse) size() int64 {
+	size := len(r.Body) + len(r.ETag)
+	for k, values := range r.Header {
+		for _, v := range values {
+			size += len(k) + len(v)
+		}
+	}
+
+	return int64(size)
+}
+
+// Cache stores responses to GET requests, identified by a key, for the
+// response cache enabled by WithCache.
+//
+// Implementations must be safe for concurrent use. A Cache may discard
+// any response, e.g. to limit its size.
+type Cache interface {
+	// Get returns the response for key, if any.
+	Get(key string) (response *CachedResponse, ok bool)
+
+	// Set stores the response for key, replacing any existing response.
+	Set(key string, response *CachedResponse)
+
+	// Delete removes the response for key, if any.
+	Delete(key string)
+}
+
+// WithCache caches the responses to GET requests in cache, so repeated
+// reads of unchanged resources don't transfer the resource again.
+//
+// A cached response is used without a request for ttl after it is
+// stored. Once stale, the response is revalidated with If-None-Match
+// using its ETag, and is used again if the server responds 304 Not
+// Modified. A stale response without an ETag is discarded. A ttl of 0
+// revalidates every request.
+//
+// Only successful JSON responses are cached. Requests with an If-None-Match,
+// If-Match, or Range header aren't cached, and a successful PATCH, PUT,
+// POST, or DELETE request removes the cached response for its URL. The
+// Cache-Status response header reports whether a response was a hit.
+// Use NoCache to bypass the cache for a request.
+//
+// The key of a cached response is scoped to the user of the access token,
+// so clients of different users can share a Cache. For a client created
+// with NewWithHTTPClient, whose transport adds the Authorization header
+// after the cache, a Cache shouldn't be shared by clients of different users.
+func WithCache(cache Cache, ttl time.Duration) Option {
+	return func(o *options) {
+		o.cache = cache
+		o.cacheTTL = ttl
+	}
+}
+
+// cached returns base wrapped by the response cache, if any. source, if
+// not nil, is the source of the tokens used to authorize the requests.
+func (o *options) cached(base http.RoundTripper, source oauth2.TokenSource) http.RoundTripper {
+	if o.cache == nil {
+		return base
+	}
+
+	return &cacheTransport{base: base, cache: o.cache, ttl: o.cacheTTL, source: source}
+}
+
+// noCacheKey is the context key for requests that bypass the cache.
+type noCacheKey struct{}
+
+// NoCache returns a copy of ctx that makes requests using it bypass the
+// response cache. The response isn't read from the cache, but a cacheable
+// response replaces the cached response, so NoCache also refreshes the cache.
+func NoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// cacheTransport is a http.RoundTripper that caches responses to GET requests.
+type cacheTransport struct {
+	base   http.RoundTripper
+	cache  Cache
+	ttl    time.Duration
+	source oauth2.TokenSource
+}
+
+// RoundTrip executes a single HTTP transaction, using a cached response if possible.
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	scope, err := t.scope(req)
+	if err != nil {
+		return nil, err
+	}
+	key := cacheKey(req, scope)
+
+	if req.Method != http.MethodGet {
+		resp, err := t.base.RoundTrip(req)
+		if err == nil && req.Method != http.MethodHead && !codeIsError(resp.StatusCode) {
+			t.cache.Delete(key)
+		}
+		return resp, err
+	}
+
+	// the caller's conditions and ranges are sent as is
+	for _, name := range []string{"If-None-Match", "If-Match", "Range"} {
+		if req.Header.Get(name) != "" {
+			return t.base.RoundTrip(req)
+		}
+	}
+
+	var cached *CachedResponse
+	if bypass, _ := req.Context().Value(noCacheKey{}).(bool); !bypass {
+		cached, _ = t.cache.Get(key)
+	}
+
+	if cached != nil && time.Since(cached.StoredAt) < t.ttl {
+		return cached.response(req, "hit"), nil
+	}
+
+	// a stale response can only be used again if it can be revalidated
+	if cached != nil && cached.ETag == "" {
+		t.cache.Delete(key)
+		cached = nil
+	}
+
+	r := req
+	if cached != nil {
+		r = req.Clone(req.Context())
+		r.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+
+		revalidated := *cached
+		revalidated.StoredAt = time.Now()
+		t.cache.Set(key, &revalidated)
+
+		return revalidated.response(req, "fwd=stale; fwd-status=304"), nil
+	}
+
+	if resp.StatusCode != http.StatusOK || !storable(resp) {
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+			t.cache.Delete(key)
+		}
+		return resp, nil
+	}
+
+	// read the body to store it, unless it is too large
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCacheEntrySize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(body) > maxCacheEntrySize {
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		etag = ETagOf(json.RawMessage(body))
+	}
+
+	t.cache.Set(key, &CachedResponse{
+		Header:   resp.Header.Clone(),
+		Body:     body,
+		ETag:     etag,
+		StoredAt: time.Now(),
+	})
+
+	fwd := "uri-miss"
+	if cached != nil {
+		fwd = "stale"
+	} else if bypass, _ := req.Context().Value(noCacheKey{}).(bool); bypass {
+		fwd = "bypass"
+	}
+	resp.Header.Set(cacheStatusHeader, "msgraph4go; fwd="+fwd+"; stored")
+
+	return resp, nil
+}
+
+// response returns a response to req with the cached header and body.
+func (r *CachedResponse) response(req *http.Request, status string) *http.Response {
+	header := r.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Set(cacheStatusHeader, "msgraph4go; "+status)
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(r.Body)),
+		ContentLength: int64(len(r.Body)),
+		Request:       req,
+	}
+}
+
+// readCloser reads from a Reader and closes a Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// scope returns the identity of the user of req, which is the user of the
+// access token, or the Authorization header if the token isn't known.
+func (t *cacheTransport) scope(req *http.Request) (string, error) {
+	if authorization := req.Header.Get("Authorization"); authorization != "" || t.source == nil {
+		return tokenIdentity(strings.TrimPrefix(authorization, "Bearer ")), nil
+	}
+
+	token, err := t.source.Token()
+	if err != nil {
+		return "", err
+	}
+
+	return tokenIdentity(token.AccessToken), nil
+}
+
+// tokenIdentity returns the tenant and object ID of the user of
+// accessToken, which don't change when the token is refreshed, or the
+// access token itself if it isn't a JWT with those claims.
+func tokenIdentity(accessToken string) string {
+	if parts := strings.Split(accessToken, "."); len(parts) == 3 {
+		var claims struct {
+			TenantID string `json:"tid"`
+			ObjectID string `json:"oid"`
+		}
+		if decodeTokenPart(parts[1], &claims) == nil && claims.ObjectID != "" {
+			return claims.TenantID + "/" + claims.ObjectID
+		}
+	}
+
+	return accessToken
+}
+
+// cacheKey returns the key of the cached response for req, which is its
+// URL along with the request headers that change the response, and a
+// hash of scope, the identity of the user, so the key doesn't reveal it.
+func cacheKey(req *http.Request, scope string) string {
+	key := req.URL.String()
+	for _, name := range []string{"ConsistencyLevel", "Prefer", "Accept"} {
+		if value := req.Header.Get(name); value != "" {
+			key += "\n" + name + ": " + value
+		}
+	}
+
+	if scope != "" {
+		sum := sha256.Sum256([]byte(scope))
+		key += "\nAuthorization: " + hex.EncodeToString(sum[:])
+	}
+
+	return key
+}
+
+// storable returns true if the response can be stored, which requires a
+// JSON body and no Cache-Control: no-store.
+func storable(resp *http.Response) bool {
+	if strings.Contains(strings.ToLower(resp.Header.Get("Cache-Control")), "no-store") {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+// MemoryCache is a Cache that keeps responses in memory, discarding the
+// least recently used responses once it exceeds its maximum size.
+type MemoryCache struct {
+	maxSize int64
+
+	mu      sync.Mutex
+	size    int64
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently used
+}
+
+// memoryEntry is a response in a MemoryCache.
+type memoryEntry struct {
+	key      string
+	response *CachedResponse
+	size     int64
+}
+
+// NewMemoryCache returns an empty MemoryCache that holds up to maxSize
+// bytes of responses. A maxSize of 0 means there is no limit.
+func NewMemoryCache(maxSize int64) *MemoryCache {
+	return &MemoryCache{
+		maxSize: maxSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Get returns the response for key, if any.
+func (c *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+
+	response := *elem.Value.(*memoryEntry).response
+
+	return &response, true
+}
+
+// Set stores the response for key, replacing any existing response.
+func (c *MemoryCache) Set(key string, response *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(key)
+
+	entry := &memoryEntry{key: key, response: response, size: int64(len(key)) + response.size()}
+	if c.maxSize > 0 && entry.size > c.maxSize {
+		return
+	}
+
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += entry.size
+
+	for c.maxSize > 0 && c.size > c.maxSize {
+		c.remove(c.lru.Back().Value.(*memoryEntry).key)
+	}
+}
+
+// Delete removes the response for key, if any.
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.remove(key)
+}
+
+// remove removes the response for key. The caller must hold the lock.
+func (c *MemoryCache) remove(key string) {
+	elem, ok := c.entries[key]
+	if !ok {
+		return
+	}
+
+	c.lru.Remove(elem)
+	delete(c.entries, key)
+	c.size -= elem.Value.(*memoryEntry).size
+}
+
+// DiskCache is a Cache that keeps each response in a file in a directory,
+// which is only readable by the owner, discarding the least recently used
+// responses once the files exceed its maximum size.
+//
+// The files are replaced atomically, so the directory can be shared by
+// several processes, although the size is only enforced by each process
+// as it stores responses.
+type DiskCache struct {
+	dir     string
+	maxSize int64
+
+	mu sync.Mutex
+}
+
+// diskEntry is the contents of a file of a DiskCache.
+type diskEntry struct {
+	Key      string          `json:"key"`
+	Response *CachedResponse `json:"response"`
+}
+
+// NewDiskCache returns a DiskCache that keeps responses in dir, which is
+// created if needed, and holds up to maxSize bytes of files. A maxSize of
+// 0 means there is no limit.
+func NewDiskCache(dir string, maxSize int64) *DiskCache {
+	return &DiskCache{dir: dir, maxSize: maxSize}
+}
+
+// Get returns the response for key, if any.
+func (c *DiskCache) Get(key string) (*CachedResponse, bool) {
+	fileName := c.fileName(key)
+
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, false
+	}
+
+	var entry diskEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Key != key || entry.Response == nil {
+		return nil, false
+	}
+
+	// the modification time records when the response was last used
+	now := time.Now()
+	os.Chtimes(fileName, now, now)
+
+	return entry.Response, true
+}
+
+// Set stores the response for key, replacing any existing response.
+func (c *DiskCache) Set(key string, response *CachedResponse) {
+	data, err := json.Marshal(diskEntry{Key: key, Response: response})
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return
+	}
+
+	if writeFileAtomic(c.fileName(key), data) == nil {
+		c.evict()
+	}
+}
+
+// Delete removes the response for key, if any.
+func (c *DiskCache) Delete(key string) {
+	os.Remove(c.fileName(key))
+}
+
+// fileName returns the name of the file for key.
+func (c *DiskCache) fileName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// evict removes the least recently used files until the files don't
+// exceed the maximum size. The caller must hold the lock.
+func (c *DiskCache) evict() {
+	if c.maxSize <= 0 {
+		return
+	}
+
+	infos, err := ioutil.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+
+	var files []os.FileInfo
+	var size int64
+	for _, info := range infos {
+		if info.Mode().IsRegular() && strings.HasSuffix(info.Name(), ".json") {
+			files = append(files, info)
+			size += info.Size()
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	for _, info := range files {
+		if size <= c.maxSize {
+			break
+		}
+
+		if os.Remove(filepath.Join(c.dir, info.Name())) == nil {
+			size -= info.Size()
+		}
+	}
+}
@@ -0,0 +1,329 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// cacheBackend is a stand-in for the Graph API that counts requests and
+// responds with the body, ETag, and Cache-Control set by the test.
+type cacheBackend struct {
+	requests     []*http.Request
+	body         string
+	etag         string
+	cacheControl string
+}
+
+// RoundTrip responds 304 Not Modified if If-None-Match matches the ETag, otherwise with the body.
+func (b *cacheBackend) RoundTrip(req *http.Request) (*http.Response, error) {
+	b.requests = append(b.requests, req)
+
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "application/json")
+	if b.etag != "" {
+		w.Header().Set("ETag", b.etag)
+	}
+	if b.cacheControl != "" {
+		w.Header().Set("Cache-Control", b.cacheControl)
+	}
+
+	if b.etag != "" && req.Header.Get("If-None-Match") == b.etag {
+		w.WriteHeader(http.StatusNotModified)
+	} else {
+		w.WriteString(b.body)
+	}
+
+	return w.Result(), nil
+}
+
+// get executes a GET request for target using rt with the header, returning the body and Cache-Status.
+func get(t *testing.T, rt http.RoundTripper, target string, header http.Header) (body string, status string) {
+	t.Helper()
+
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := rt.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+
+	return string(data), resp.Header.Get(cacheStatusHeader)
+}
+
+// testJWT returns an unsigned JWT with the claims.
+func testJWT(claims map[string]string) string {
+	data, _ := json.Marshal(claims)
+
+	return "e30." + base64.RawURLEncoding.EncodeToString(data) + ".sig"
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	response := func(body string) *CachedResponse {
+		return &CachedResponse{Body: []byte(body)}
+	}
+
+	// each entry is 1 byte of key and 9 bytes of body
+	cache := NewMemoryCache(30)
+	cache.Set("a", response("123456789"))
+	cache.Set("b", response("123456789"))
+	cache.Set("c", response("123456789"))
+
+	// a is used, so b is the least recently used
+	if _, ok := cache.Get("a"); !ok {
+		t.Fatal("a not cached")
+	}
+	cache.Set("d", response("123456789"))
+
+	// the keys from the most to the least recently used, without using them
+	keys := func() string {
+		var keys []string
+		for elem := cache.lru.Front(); elem != nil; elem = elem.Next() {
+			keys = append(keys, elem.Value.(*memoryEntry).key)
+		}
+		return strings.Join(keys, " ")
+	}
+
+	if got := keys(); got != "d a c" {
+		t.Errorf("keys = %q, want %q", got, "d a c")
+	}
+
+	// replacing a response with a larger one evicts the least recently used
+	cache.Set("c", response("1234567890123456789"))
+	if got := keys(); got != "c d" || cache.size != 30 {
+		t.Errorf("keys = %q of size %d, want %q of size 30", got, cache.size, "c d")
+	}
+
+	// a response larger than the cache isn't stored
+	cache.Set("e", response(strings.Repeat("x", 30)))
+	if got := keys(); got != "c d" {
+		t.Errorf("keys = %q, want %q", got, "c d")
+	}
+
+	cache.Delete("c")
+	if got := keys(); got != "d" || cache.size != 10 {
+		t.Errorf("keys = %q of size %d, want %q of size 10", got, cache.size, "d")
+	}
+
+	// the cached response can't be changed through the returned copy
+	got, _ := cache.Get("d")
+	got.ETag = "changed"
+	if again, _ := cache.Get("d"); again.ETag != "" {
+		t.Error("cached response was changed")
+	}
+}
+
+func TestCacheRevalidation(t *testing.T) {
+	backend := &cacheBackend{body: `{"id":"1"}`, etag: `"v1"`}
+	cache := NewMemoryCache(0)
+	rt := &cacheTransport{base: backend, cache: cache, ttl: time.Hour}
+
+	if body, status := get(t, rt, "https://graph.microsoft.com/v1.0/me", nil); body != backend.body || !strings.Contains(status, "stored") {
+		t.Errorf("first request = %s %q", body, status)
+	}
+	if body, status := get(t, rt, "https://graph.microsoft.com/v1.0/me", nil); body != backend.body || status != "msgraph4go; hit" {
+		t.Errorf("fresh request = %s %q, want a hit", body, status)
+	}
+	if len(backend.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(backend.requests))
+	}
+
+	// a stale response is revalidated with its ETag
+	rt.ttl = 0
+	key := cacheKey(backend.requests[0], "")
+	stale, _ := cache.Get(key)
+
+	body, status := get(t, rt, "https://graph.microsoft.com/v1.0/me", nil)
+	if body != backend.body || !strings.Contains(status, "fwd-status=304") {
+		t.Errorf("revalidated request = %s %q", body, status)
+	}
+	if match := backend.requests[1].Header.Get("If-None-Match"); match != `"v1"` {
+		t.Errorf("If-None-Match = %q, want %q", match, `"v1"`)
+	}
+	if revalidated, _ := cache.Get(key); !revalidated.StoredAt.After(stale.StoredAt) {
+		t.Error("StoredAt not updated by revalidation")
+	}
+
+	// a changed resource replaces the cached response
+	backend.body, backend.etag = `{"id":"1","name":"changed"}`, `"v2"`
+	if body, status := get(t, rt, "https://graph.microsoft.com/v1.0/me", nil); body != backend.body || !strings.Contains(status, "fwd=stale; stored") {
+		t.Errorf("changed request = %s %q", body, status)
+	}
+	if cached, _ := cache.Get(key); cached.ETag != `"v2"` || string(cached.Body) != backend.body {
+		t.Errorf("cached = %s %s, want the changed response", cached.ETag, cached.Body)
+	}
+
+	// a stale response without an ETag is discarded rather than revalidated
+	backend.etag = ""
+	get(t, rt, "https://graph.microsoft.com/v1.0/me/drive", nil)
+	get(t, rt, "https://graph.microsoft.com/v1.0/me/drive", nil)
+	if match := backend.requests[len(backend.requests)-1].Header.Get("If-None-Match"); match != "" {
+		t.Errorf("If-None-Match = %q without an ETag", match)
+	}
+}
+
+func TestCacheNoStore(t *testing.T) {
+	backend := &cacheBackend{body: `{"id":"1"}`, etag: `"v1"`, cacheControl: "private, no-store"}
+	rt := &cacheTransport{base: backend, cache: NewMemoryCache(0), ttl: time.Hour}
+
+	for n := 0; n < 2; n++ {
+		if _, status := get(t, rt, "https://graph.microsoft.com/v1.0/me", nil); status != "" {
+			t.Errorf("Cache-Status = %q, want none", status)
+		}
+	}
+	if len(backend.requests) != 2 {
+		t.Errorf("got %d requests, want 2", len(backend.requests))
+	}
+	if match := backend.requests[1].Header.Get("If-None-Match"); match != "" {
+		t.Errorf("If-None-Match = %q for a response that wasn't stored", match)
+	}
+}
+
+func TestCacheAuthorizationScope(t *testing.T) {
+	backend := &cacheBackend{body: `{"id":"1"}`}
+	rt := &cacheTransport{base: backend, cache: NewMemoryCache(0), ttl: time.Hour}
+
+	ann := testJWT(map[string]string{"tid": "tenant", "oid": "ann"})
+	annRefreshed := testJWT(map[string]string{"tid": "tenant", "oid": "ann", "exp": "later"})
+	bob := testJWT(map[string]string{"tid": "tenant", "oid": "bob"})
+
+	requests := []struct {
+		authorization string
+		hit           bool
+	}{
+		{"Bearer " + ann, false},
+		{"Bearer " + ann, true},
+		{"Bearer " + annRefreshed, true}, // the same user after the token is refreshed
+		{"Bearer " + bob, false},
+		{"Bearer opaque", false},
+		{"Bearer other-opaque", false},
+		{"Bearer opaque", true},
+	}
+
+	for _, r := range requests {
+		_, status := get(t, rt, "https://graph.microsoft.com/v1.0/me", http.Header{"Authorization": {r.authorization}})
+		if hit := status == "msgraph4go; hit"; hit != r.hit {
+			t.Errorf("%s: Cache-Status = %q, want hit %v", r.authorization, status, r.hit)
+		}
+	}
+
+	// the token isn't part of the key
+	for _, key := range []string{cacheKey(backend.requests[0], ann), cacheKey(backend.requests[0], "opaque")} {
+		if strings.Contains(key, ann) || strings.Contains(key, "opaque") {
+			t.Errorf("key %q contains the token", key)
+		}
+	}
+
+	// without an Authorization header, the scope is the token of the source
+	sourced := &cacheTransport{base: backend, cache: rt.cache, ttl: time.Hour,
+		source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: bob})}
+	if _, status := get(t, sourced, "https://graph.microsoft.com/v1.0/me", nil); status != "msgraph4go; hit" {
+		t.Errorf("Cache-Status = %q, want the response cached for bob", status)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	cache := NewDiskCache(dir, 0)
+
+	response := &CachedResponse{
+		Header:   http.Header{"Content-Type": {"application/json"}},
+		Body:     []byte(`{"id":"1"}`),
+		ETag:     `"v1"`,
+		StoredAt: time.Now().Round(0),
+	}
+	cache.Set("key", response)
+
+	got, ok := NewDiskCache(dir, 0).Get("key")
+	if !ok || string(got.Body) != string(response.Body) || got.ETag != response.ETag || !got.StoredAt.Equal(response.StoredAt) {
+		t.Fatalf("Get = %+v, %v, want %+v", got, ok, response)
+	}
+
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("directory mode = %v, %v, want 0700", info.Mode().Perm(), err)
+	}
+
+	// a corrupted or truncated file is a miss, and is replaced by Set
+	for _, data := range []string{"", "not json", `{"key":"key"}`, `{"key":"other","response":{"body":"e30="}}`} {
+		if err := ioutil.WriteFile(cache.fileName("key"), []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if got, ok := cache.Get("key"); ok {
+			t.Errorf("Get of %q = %+v, want a miss", data, got)
+		}
+	}
+
+	cache.Set("key", response)
+	if _, ok := cache.Get("key"); !ok {
+		t.Error("response not replaced")
+	}
+
+	cache.Delete("key")
+	if _, ok := cache.Get("key"); ok {
+		t.Error("response not deleted")
+	}
+}
+
+func TestDiskCacheEviction(t *testing.T) {
+	dir := t.TempDir()
+
+	response := &CachedResponse{Body: []byte(strings.Repeat("x", 100))}
+	data, _ := json.Marshal(diskEntry{Key: "a", Response: response})
+
+	// room for two files
+	cache := NewDiskCache(dir, int64(2*len(data)+10))
+
+	cache.Set("a", response)
+	cache.Set("b", response)
+
+	// a is used after b, so b is the least recently used
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(cache.fileName("a"), old, old)
+	os.Chtimes(cache.fileName("b"), old.Add(time.Minute), old.Add(time.Minute))
+	cache.Get("a")
+
+	cache.Set("c", response)
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := cache.Get(key); ok != want {
+			t.Errorf("Get(%s) = %v, want %v", key, ok, want)
+		}
+	}
+}
@@ -17,48 +17,108 @@ limitations under the License.
 package msgraph4go
 
 import (
-	"encoding/json"
+	"context"
+	"io"
 	"net/url"
+	"time"
 )
 
 // ListMyCalendars gets all the user's calendars.
 func (c *MSGraphClient) ListMyCalendars(query url.Values) (response CalendarResponse, err error) {
+	return c.ListMyCalendarsWithContext(context.Background(), query)
+}
+
+// ListMyCalendarsWithContext is like ListMyCalendars, but uses ctx for the request.
+func (c *MSGraphClient) ListMyCalendarsWithContext(ctx context.Context, query url.Values) (response CalendarResponse, err error) {
 	var body []byte
 
-	body, err = c.Get("/me/calendars", query)
+	body, err = c.GetWithContext(ctx, "/me/calendars", query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
 
+// ListMyCalendarsPager returns a Pager for the Calendar items of the collection returned by ListMyCalendars.
+func (c *MSGraphClient) ListMyCalendarsPager(query url.Values) *Pager {
+	return c.NewPager("/me/calendars", query)
+}
+
 // GetMyDefaultCalendar gets the current users default calendar.
 func (c *MSGraphClient) GetMyDefaultCalendar(query url.Values) (response Calendar, err error) {
+	return c.GetMyDefaultCalendarWithContext(context.Background(), query)
+}
+
+// GetMyDefaultCalendarWithContext is like GetMyDefaultCalendar, but uses ctx for the request.
+func (c *MSGraphClient) GetMyDefaultCalendarWithContext(ctx context.Context, query url.Values) (response Calendar, err error) {
 	var body []byte
 
-	body, err = c.Get("/me/calendar", query)
+	body, err = c.GetWithContext(ctx, "/me/calendar", query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
 
 // ListMyCalendarGroups gets the curent user's calendar groups.
 func (c *MSGraphClient) ListMyCalendarGroups(query url.Values) (response CalendarGroupResponse, err error) {
+	return c.ListMyCalendarGroupsWithContext(context.Background(), query)
+}
+
+// ListMyCalendarGroupsWithContext is like ListMyCalendarGroups, but uses ctx for the request.
+func (c *MSGraphClient) ListMyCalendarGroupsWithContext(ctx context.Context, query url.Values) (response CalendarGroupResponse, err error) {
 	var body []byte
 
-	body, err = c.Get("/me/calendarGroups", query)
+	body, err = c.GetWithContext(ctx, "/me/calendarGroups", query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
+
+// ListMyCalendarGroupsPager returns a Pager for the CalendarGroup items of the collection returned by ListMyCalendarGroups.
+func (c *MSGraphClient) ListMyCalendarGroupsPager(query url.Values) *Pager {
+	return c.NewPager("/me/calendarGroups", query)
+}
+
+// CreateMyEvent creates the event specified in data, a JSON Event, in the current user's default calendar.
+func (c *MSGraphClient) CreateMyEvent(query url.Values, data io.Reader) (event Event, err error) {
+	return c.CreateMyEventWithContext(context.Background(), query, data)
+}
+
+// CreateMyEventWithContext is like CreateMyEvent, but uses ctx for the request.
+func (c *MSGraphClient) CreateMyEventWithContext(ctx context.Context, query url.Values, data io.Reader) (event Event, err error) {
+	var body []byte
+
+	body, err = c.PostWithContext(ctx, "/me/events", query, data)
+	if err != nil {
+		return event, err
+	}
+
+	err = decodeBody(body, &event)
+
+	return event, err
+}
+
+// MyCalendarViewDelta returns a Delta that tracks changes to the events
+// of the current user's default calendar between start and end.
+// Change.Item is an *Event.
+func (c *MSGraphClient) MyCalendarViewDelta(start time.Time, end time.Time, query url.Values, store CheckpointStore) *Delta {
+	viewQuery := url.Values{}
+	for k, v := range query {
+		viewQuery[k] = v
+	}
+	viewQuery.Set("startDateTime", start.UTC().Format(time.RFC3339))
+	viewQuery.Set("endDateTime", end.UTC().Format(time.RFC3339))
+
+	return c.NewDelta("/me/calendarView/delta", viewQuery, store, func() interface{} { return &Event{} })
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrCheckpointNotFound is returned by a CheckpointStore when there is no checkpoint for a key.
+var ErrCheckpointNotFound = errors.New("msgraph4go: checkpoint not found")
+
+// Checkpoint is the state of a delta query that is saved after each sync.
+type Checkpoint struct {
+	// DeltaLink is the @odata.deltaLink used to get the changes since the sync.
+	DeltaLink string `json:"deltaLink"`
+
+	// SyncedAt is when the sync completed.
+	SyncedAt time.Time `json:"syncedAt"`
+}
+
+// CheckpointStore saves the Checkpoint of delta queries, identified by a key.
+//
+// Implementations must be safe for concurrent use.
+type CheckpointStore interface {
+	// Load returns the checkpoint for key, or ErrCheckpointNotFound if there is none.
+	Load(key string) (*Checkpoint, error)
+
+	// Save stores the checkpoint for key, replacing any existing checkpoint.
+	Save(key string, checkpoint *Checkpoint) error
+
+	// Delete removes the checkpoint for key, if any.
+	Delete(key string) error
+}
+
+// MemoryCheckpointStore is a CheckpointStore that keeps checkpoints in memory.
+type MemoryCheckpointStore struct {
+	mu          sync.Mutex
+	checkpoints map[string]Checkpoint
+}
+
+// NewMemoryCheckpointStore returns an empty MemoryCheckpointStore.
+func NewMemoryCheckpointStore() *MemoryCheckpointStore {
+	return &MemoryCheckpointStore{checkpoints: make(map[string]Checkpoint)}
+}
+
+// Load returns the checkpoint for key, or ErrCheckpointNotFound if there is none.
+func (s *MemoryCheckpointStore) Load(key string) (*Checkpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	checkpoint, ok := s.checkpoints[key]
+	if !ok {
+		return nil, ErrCheckpointNotFound
+	}
+
+	return &checkpoint, nil
+}
+
+// Save stores the checkpoint for key, replacing any existing checkpoint.
+func (s *MemoryCheckpointStore) Save(key string, checkpoint *Checkpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.checkpoints[key] = *checkpoint
+
+	return nil
+}
+
+// Delete removes the checkpoint for key, if any.
+func (s *MemoryCheckpointStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.checkpoints, key)
+
+	return nil
+}
+
+// FileCheckpointStore is a CheckpointStore that keeps checkpoints in a JSON
+// file, which is only readable by the owner and can be shared by several processes.
+type FileCheckpointStore struct {
+	fileName string
+}
+
+// NewFileCheckpointStore returns a FileCheckpointStore that keeps checkpoints in fileName.
+func NewFileCheckpointStore(fileName string) *FileCheckpointStore {
+	return &FileCheckpointStore{fileName: fileName}
+}
+
+// Load returns the checkpoint for key, or ErrCheckpointNotFound if there is none.
+func (s *FileCheckpointStore) Load(key string) (*Checkpoint, error) {
+	checkpoints, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoint, ok := checkpoints[key]
+	if !ok {
+		return nil, ErrCheckpointNotFound
+	}
+
+	return &checkpoint, nil
+}
+
+// Save stores the checkpoint for key, replacing any existing checkpoint.
+func (s *FileCheckpointStore) Save(key string, checkpoint *Checkpoint) error {
+	return s.update(func(checkpoints map[string]Checkpoint) {
+		checkpoints[key] = *checkpoint
+	})
+}
+
+// Delete removes the checkpoint for key, if any.
+func (s *FileCheckpointStore) Delete(key string) error {
+	return s.update(func(checkpoints map[string]Checkpoint) {
+		delete(checkpoints, key)
+	})
+}
+
+// update locks the file and applies change to the checkpoints.
+func (s *FileCheckpointStore) update(change func(checkpoints map[string]Checkpoint)) error {
+	unlock, err := lockFile(s.fileName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	checkpoints, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	change(checkpoints)
+
+	data, err := json.Marshal(checkpoints)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(s.fileName, data)
+}
+
+// read returns the checkpoints in the file, which are empty if the file doesn't exist.
+func (s *FileCheckpointStore) read() (map[string]Checkpoint, error) {
+	checkpoints := make(map[string]Checkpoint)
+
+	data, err := ioutil.ReadFile(s.fileName)
+	if os.IsNotExist(err) {
+		return checkpoints, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &checkpoints)
+	if err != nil {
+		return nil, err
+	}
+
+	return checkpoints, nil
+}
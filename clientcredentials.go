@@ -0,0 +1,281 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+// ErrMeRequiresUser is returned for requests to /me, or /drive, by a client
+// without a signed in user, such as one created by NewClientCredentials.
+// Use AsUser to send these requests for a specific user instead.
+var ErrMeRequiresUser = errors.New("msgraph4go: /me requires a signed in user, use AsUser or /users/{id} with app-only authentication")
+
+// clientAssertionType is the type of a client assertion signed with a certificate.
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// ClientCredential authenticates an application using the client credentials grant.
+//
+// Use ClientSecret or ClientCertificate to create a ClientCredential.
+type ClientCredential interface {
+	// tokenSource returns a TokenSource that authenticates the application using conf.
+	tokenSource(ctx context.Context, conf clientcredentials.Config) oauth2.TokenSource
+}
+
+// secretCredential is a ClientCredential using a client secret.
+type secretCredential struct {
+	secret string
+}
+
+// ClientSecret returns a ClientCredential for a client secret of the application.
+func ClientSecret(secret string) ClientCredential {
+	return &secretCredential{secret: secret}
+}
+
+func (c *secretCredential) tokenSource(ctx context.Context, conf clientcredentials.Config) oauth2.TokenSource {
+	conf.ClientSecret = c.secret
+	return conf.TokenSource(ctx)
+}
+
+// certificateCredential is a ClientCredential using a certificate and private key.
+type certificateCredential struct {
+	thumbprint string
+	key        *rsa.PrivateKey
+}
+
+// ClientCertificate returns a ClientCredential for a certificate registered
+// for the application along with the RSA private key of the certificate.
+//
+// A client assertion, signed with the private key, is created for each token request.
+func ClientCertificate(cert *x509.Certificate, key crypto.PrivateKey) (ClientCredential, error) {
+	if cert == nil {
+		return nil, errors.New("msgraph4go: certificate is required")
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("msgraph4go: private key must be an RSA key")
+	}
+
+	// the key must match the certificate
+	pub, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok || pub.N.Cmp(rsaKey.N) != 0 || pub.E != rsaKey.E {
+		return nil, errors.New("msgraph4go: private key does not match the certificate")
+	}
+
+	// x5t is the base64url encoded SHA-1 thumbprint of the certificate
+	thumbprint := sha1.Sum(cert.Raw)
+
+	return &certificateCredential{
+		thumbprint: base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+		key:        rsaKey,
+	}, nil
+}
+
+func (c *certificateCredential) tokenSource(ctx context.Context, conf clientcredentials.Config) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, &assertionTokenSource{ctx: ctx, conf: conf, cred: c})
+}
+
+// assertionTokenSource is an oauth2.TokenSource that signs a new client assertion for each token request.
+type assertionTokenSource struct {
+	ctx  context.Context
+	conf clientcredentials.Config
+	cred *certificateCredential
+}
+
+// Token requests a new token using a new client assertion.
+func (s *assertionTokenSource) Token() (*oauth2.Token, error) {
+	assertion, err := s.cred.assertion(s.conf.ClientID, s.conf.TokenURL)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := s.conf
+	conf.EndpointParams = url.Values{
+		"client_assertion_type": {clientAssertionType},
+		"client_assertion":      {assertion},
+	}
+
+	return conf.Token(s.ctx)
+}
+
+// assertion returns a client assertion JWT for clientID, signed with the private key.
+//
+// See https://docs.microsoft.com/en-us/azure/active-directory/develop/active-directory-certificate-credentials
+func (c *certificateCredential) assertion(clientID string, audience string) (string, error) {
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+
+	header := map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"x5t": c.thumbprint,
+	}
+
+	claims := map[string]interface{}{
+		"aud": audience,
+		"iss": clientID,
+		"sub": clientID,
+		"jti": base64.RawURLEncoding.EncodeToString(jti),
+		"nbf": now.Unix(),
+		"iat": now.Unix(),
+		"exp": now.Add(10 * time.Minute).Unix(),
+	}
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJSON) + "." +
+		base64.RawURLEncoding.EncodeToString(claimsJSON)
+
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// NewClientCredentials creates a MSGraphClient for an application without
+// a signed in user, using the OAuth 2.0 client credentials grant.
+//
+// The application must be granted application permissions, and WithTenant
+// must be used to set the tenant ID or domain name. The token is requested
+// for the ".default" scope of the Graph API, so it includes all of the
+// application permissions granted to the application.
+//
+// Requests to /me fail with ErrMeRequiresUser. Use AsUser, or the
+// /users/{id} endpoints, to access the resources of a user.
+//
+// See https://docs.microsoft.com/en-us/graph/auth-v2-service for more information.
+func NewClientCredentials(ctx context.Context, clientID string, credential ClientCredential, opts ...Option) (*MSGraphClient, error) {
+	o := newOptions(opts)
+
+	switch strings.ToLower(o.tenant) {
+	case "", "common", "organizations", "consumers":
+		return nil, errors.New("msgraph4go: client credentials require a tenant, use WithTenant")
+	}
+
+	conf := clientcredentials.Config{
+		ClientID:  clientID,
+		TokenURL:  o.tokenURL(),
+		Scopes:    []string{strings.TrimSuffix(o.graphRoot, "/") + "/.default"},
+		AuthStyle: oauth2.AuthStyleInParams,
+	}
+
+	tokenSource := oauth2.ReuseTokenSource(nil, &authErrorTokenSource{
+		op:     "client credentials",
+		source: credential.tokenSource(ctx, conf),
+	})
+
+	// get a token now so configuration errors are reported immediately
+	_, err := tokenSource.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MSGraphClient{
+		httpClient: o.authorizedClient(ctx, tokenSource),
+		graphURL:   o.graphURL(),
+		appOnly:    true,
+	}, nil
+}
+
+// authErrorTokenSource returns the token endpoint errors of source as an AuthError.
+type authErrorTokenSource struct {
+	op     string
+	source oauth2.TokenSource
+}
+
+// Token returns a token from source.
+func (s *authErrorTokenSource) Token() (*oauth2.Token, error) {
+	token, err := s.source.Token()
+	if err != nil {
+		return nil, authError(s.op, err)
+	}
+
+	return token, nil
+}
+
+// AsUser returns a copy of the client that sends requests for /me, and
+// /drive, to the endpoints of user instead, which is a user ID or userPrincipalName.
+//
+// This allows the methods for the current user, such as ListMyMessages,
+// to be used by a client created with NewClientCredentials.
+func (c *MSGraphClient) AsUser(user string) *MSGraphClient {
+	client := *c
+	client.userPath = "/users/" + url.PathEscape(user)
+
+	return &client
+}
+
+// resolveMe rewrites a relative urlString for /me, or /drive, using the user set by AsUser.
+//
+// ErrMeRequiresUser is returned if there is no signed in user.
+func (c *MSGraphClient) resolveMe(urlString string) (string, error) {
+	path := urlString
+	if n := strings.IndexAny(path, "?#"); n >= 0 {
+		path = path[:n]
+	}
+
+	var rest string
+	switch {
+	case path == "/me" || strings.HasPrefix(path, "/me/"):
+		rest = strings.TrimPrefix(urlString, "/me")
+	case path == "/drive" || strings.HasPrefix(path, "/drive/"):
+		rest = urlString
+	default:
+		return urlString, nil
+	}
+
+	if c.userPath != "" {
+		return c.userPath + rest, nil
+	}
+
+	if c.appOnly {
+		return "", ErrMeRequiresUser
+	}
+
+	return urlString, nil
+}
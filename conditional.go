@@ -0,0 +1,259 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// conditionsKey is the context key for the conditions of a request.
+type conditionsKey struct{}
+
+// conditions are the ETags used for conditional requests.
+type conditions struct {
+	ifMatch     string
+	ifNoneMatch string
+}
+
+// IfMatch returns a copy of ctx that makes updates and deletes using it
+// conditional on the resource matching etag, e.g. the ETag of a DriveItem
+// or the @odata.etag of a Contact. ETagOf returns the ETag of a resource.
+//
+// If the resource has changed, the request fails with a
+// *PreconditionFailedError, which matches ErrPreconditionFailed, so
+// changes made by others aren't overwritten.
+//
+//	ctx = msgraph4go.IfMatch(ctx, msgraph4go.ETagOf(contact))
+//	contact, err = client.UpdateContactWithContext(ctx, nil, "me", contact.ID, data)
+//	if errors.Is(err, msgraph4go.ErrPreconditionFailed) {
+//		// reload the contact and try again
+//	}
+//
+// The If-Match header is only sent for PATCH, PUT, POST and DELETE requests.
+func IfMatch(ctx context.Context, etag string) context.Context {
+	cond, _ := ctx.Value(conditionsKey{}).(conditions)
+	cond.ifMatch = etag
+
+	return context.WithValue(ctx, conditionsKey{}, cond)
+}
+
+// IfNoneMatch returns a copy of ctx that makes GET requests using it
+// conditional on the resource not matching etag. If the resource hasn't
+// changed, the response is 304 Not Modified without a body.
+//
+// Do, GetStream, and GetIfModified report this without an error: the
+// NotModified field of the Response of Do or of the Stream of GetStream
+// is true. Other methods, which return the resource, fail with a
+// *NotModifiedError, which matches ErrNotModified.
+//
+// The If-None-Match header is only sent for GET and HEAD requests.
+func IfNoneMatch(ctx context.Context, etag string) context.Context {
+	cond, _ := ctx.Value(conditionsKey{}).(conditions)
+	cond.ifNoneMatch = etag
+
+	return context.WithValue(ctx, conditionsKey{}, cond)
+}
+
+// withoutConditions returns a copy of ctx without conditions, for requests
+// that shouldn't be conditional, such as a batch.
+func withoutConditions(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(conditionsKey{}).(conditions); !ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, conditionsKey{}, conditions{})
+}
+
+// setConditions sets the If-Match or If-None-Match header of req from its
+// context, unless the header is already set.
+func setConditions(req *http.Request) {
+	cond, ok := req.Context().Value(conditionsKey{}).(conditions)
+	if !ok {
+		return
+	}
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		if cond.ifNoneMatch != "" && req.Header.Get("If-None-Match") == "" {
+			req.Header.Set("If-None-Match", cond.ifNoneMatch)
+		}
+	default:
+		if cond.ifMatch != "" && req.Header.Get("If-Match") == "" {
+			req.Header.Set("If-Match", cond.ifMatch)
+		}
+	}
+}
+
+// GetIfModified executes a GET request for urlString, decoding the
+// response into v only if the resource no longer matches etag, e.g. the
+// ETag of a previous response. modified is false, without an error, if
+// the resource hasn't changed, so v can be kept.
+//
+//	modified, err := client.GetIfModified("/me/drive/items/"+item.ID, nil, item.ETag, &item)
+func (c *MSGraphClient) GetIfModified(urlString string, query url.Values, etag string, v interface{}) (modified bool, err error) {
+	return c.GetIfModifiedWithContext(context.Background(), urlString, query, etag, v)
+}
+
+// GetIfModifiedWithContext is like GetIfModified, but uses ctx for the request.
+func (c *MSGraphClient) GetIfModifiedWithContext(ctx context.Context, urlString string, query url.Values, etag string, v interface{}) (modified bool, err error) {
+	if etag != "" {
+		ctx = IfNoneMatch(ctx, etag)
+	}
+
+	resp, err := c.DoWithContext(ctx, http.MethodGet, urlString, query, nil, nil)
+	if err != nil {
+		return false, err
+	}
+	if resp.NotModified {
+		return false, nil
+	}
+
+	return true, resp.Decode(v)
+}
+
+// ETagOf returns the ETag of v, such as a DriveItem, Contact, or Calendar,
+// which is its @odata.etag, eTag, or changeKey. An empty string is
+// returned if v doesn't have an ETag.
+func ETagOf(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+
+	var tags struct {
+		ODataETag string `json:"@odata.etag"`
+		ETag      string `json:"eTag"`
+		ChangeKey string `json:"changeKey"`
+	}
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return ""
+	}
+
+	switch {
+	case tags.ODataETag != "":
+		return tags.ODataETag
+	case tags.ETag != "":
+		return tags.ETag
+	case tags.ChangeKey != "":
+		// the @odata.etag of Outlook items is the weak ETag of the changeKey
+		return `W/"` + tags.ChangeKey + `"`
+	}
+
+	return ""
+}
+
+// NotModifiedError is returned for a 304 Not Modified response to a
+// request using IfNoneMatch, by methods that return the resource, which
+// isn't included in the response.
+//
+// It matches ErrNotModified using errors.Is.
+type NotModifiedError struct {
+	// ETag is the ETag of the resource, if returned.
+	ETag string
+
+	// Header is the header of the response.
+	Header http.Header
+}
+
+// Error returns a string representation of the error.
+func (e *NotModifiedError) Error() string {
+	return "msgraph4go: 304 Not Modified"
+}
+
+// Is reports whether target is ErrNotModified.
+func (e *NotModifiedError) Is(target error) bool {
+	return target == ErrNotModified
+}
+
+// notModified returns a NotModifiedError for the header of a 304 Not Modified response.
+func notModified(header http.Header) *NotModifiedError {
+	return &NotModifiedError{ETag: header.Get("ETag"), Header: header}
+}
+
+// PreconditionFailedError is returned when a conditional request fails
+// with 412 Precondition Failed because the resource has changed.
+//
+// It matches ErrPreconditionFailed using errors.Is, and wraps the GraphErrorResponse.
+type PreconditionFailedError struct {
+	*GraphErrorResponse
+
+	// ETag is the current ETag of the resource, if known.
+	ETag string
+
+	// Current is the current version of the resource as JSON, if it could be retrieved.
+	Current json.RawMessage
+}
+
+// Error returns a string representation of the error.
+func (e *PreconditionFailedError) Error() string {
+	if e.ETag == "" {
+		return e.GraphErrorResponse.Error()
+	}
+
+	return fmt.Sprintf("%s (current etag %s)", e.GraphErrorResponse.Error(), e.ETag)
+}
+
+// Unwrap returns the GraphErrorResponse.
+func (e *PreconditionFailedError) Unwrap() error {
+	return e.GraphErrorResponse
+}
+
+// Decode unmarshals the current version of the resource into v, e.g. a *Contact.
+// v is left unchanged if the current version isn't known.
+func (e *PreconditionFailedError) Decode(v interface{}) error {
+	return decodeBody(e.Current, v)
+}
+
+// preconditionFailed returns a PreconditionFailedError for graphErr, the
+// response to a conditional request for u, getting the current version of
+// the resource unless the request was a GET.
+func (c *MSGraphClient) preconditionFailed(ctx context.Context, method string, u *url.URL, graphErr *GraphErrorResponse) *PreconditionFailedError {
+	err := &PreconditionFailedError{
+		GraphErrorResponse: graphErr,
+		ETag:               graphErr.Header.Get("ETag"),
+	}
+
+	if method == http.MethodGet || method == http.MethodHead {
+		return err
+	}
+
+	// the metadata of a drive item is used for a request for its content
+	current := *u
+	current.RawQuery = ""
+	for _, suffix := range []string{"/content", "/$value"} {
+		current.Path = strings.TrimSuffix(current.Path, suffix)
+	}
+
+	resp, getErr := c.DoWithContext(withoutConditions(ctx), http.MethodGet, current.String(), nil, nil, nil)
+	if getErr != nil {
+		return err
+	}
+
+	err.Current = resp.Body
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		err.ETag = etag
+	} else if etag := ETagOf(json.RawMessage(resp.Body)); etag != "" {
+		err.ETag = etag
+	}
+
+	return err
+}
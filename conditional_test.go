@@ -0,0 +1,166 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/bnixon67/msgraph4go"
+	"github.com/bnixon67/msgraph4go/graphtest"
+)
+
+// checkNotModified fails the test unless err is a *NotModifiedError with etag.
+func checkNotModified(t *testing.T, err error, etag string) {
+	t.Helper()
+
+	var notModified *msgraph4go.NotModifiedError
+	if !errors.Is(err, msgraph4go.ErrNotModified) || !errors.As(err, &notModified) {
+		t.Fatalf("error = %v, want %v", err, msgraph4go.ErrNotModified)
+	}
+	if notModified.ETag != etag {
+		t.Errorf("ETag = %q, want %q", notModified.ETag, etag)
+	}
+}
+
+func TestNotModifiedTypedHelpers(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	ctx := context.Background()
+
+	profile, err := client.GetMyProfile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := msgraph4go.ETagOf(profile)
+
+	_, err = client.GetMyProfileWithContext(msgraph4go.IfNoneMatch(ctx, etag), nil)
+	checkNotModified(t, err, etag)
+
+	item := server.AddFile("", "notes.txt", []byte("hello"))
+	drive, err := client.GetMyDrive(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := client.GetDriveItemByIDWithContext(msgraph4go.IfNoneMatch(ctx, item.ETag), drive.ID, item.ID, nil)
+	checkNotModified(t, err, item.ETag)
+	if got.ID != "" {
+		t.Errorf("GetDriveItemByID = %+v, want a zero DriveItem", got)
+	}
+
+	// a changed item is returned
+	if err := server.Update("/me/drive/items/"+item.ID, map[string]interface{}{"name": "renamed.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	got, err = client.GetDriveItemByIDWithContext(msgraph4go.IfNoneMatch(ctx, item.ETag), drive.ID, item.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "renamed.txt" {
+		t.Errorf("Name = %q, want renamed.txt", got.Name)
+	}
+}
+
+func TestNotModifiedDoAndStream(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	item := server.AddFile("", "notes.txt", []byte("hello"))
+	ctx := msgraph4go.IfNoneMatch(context.Background(), item.ETag)
+
+	resp, err := client.DoWithContext(ctx, http.MethodGet, "/me/drive/items/"+item.ID, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.NotModified {
+		t.Error("Response.NotModified = false, want true")
+	}
+
+	stream, err := client.GetStreamWithContext(ctx, "/me/drive/items/"+item.ID+"/content", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Close()
+	if !stream.NotModified {
+		t.Error("Stream.NotModified = false, want true")
+	}
+
+	var got msgraph4go.DriveItem
+	modified, err := client.GetIfModifiedWithContext(context.Background(), "/me/drive/items/"+item.ID, nil, item.ETag, &got)
+	if err != nil || modified {
+		t.Errorf("GetIfModified = %v, %v, want false, nil", modified, err)
+	}
+}
+
+func TestNotModifiedGetFile(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	item := server.AddFile("", "notes.txt", []byte("hello"))
+	fileName := filepath.Join(t.TempDir(), "notes.txt")
+
+	if err := client.GetFile("/me/drive/items/"+item.ID+"/content", fileName); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := msgraph4go.IfNoneMatch(context.Background(), item.ETag)
+	err := client.GetFileWithContext(ctx, "/me/drive/items/"+item.ID+"/content", fileName)
+	checkNotModified(t, err, item.ETag)
+
+	// the existing file is kept
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("file = %q, want %q", data, "hello")
+	}
+}
+
+func TestNotModifiedPager(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	server.AddMessage("inbox", msgraph4go.Message{Subject: "Hello"})
+	server.InjectFault(graphtest.Fault{Path: "/me/messages", StatusCode: http.StatusNotModified, Times: 1})
+
+	pager := server.Client().NewPager("/me/messages", nil)
+
+	var message msgraph4go.Message
+	if pager.Next(context.Background(), &message) {
+		t.Fatal("Next = true, want false for a 304 response")
+	}
+	if !errors.Is(pager.Err(), msgraph4go.ErrNotModified) {
+		t.Errorf("Err = %v, want %v", pager.Err(), msgraph4go.ErrNotModified)
+	}
+
+	server.InjectFault(graphtest.Fault{Path: "/me/messages", StatusCode: http.StatusNotModified, Times: 1})
+
+	_, err := server.Client().GetCollectionDecoder("/me/messages", nil)
+	if !errors.Is(err, msgraph4go.ErrNotModified) {
+		t.Errorf("GetCollectionDecoder error = %v, want %v", err, msgraph4go.ErrNotModified)
+	}
+}
@@ -17,7 +17,7 @@ limitations under the License.
 package msgraph4go
 
 import (
-	"encoding/json"
+	"context"
 	"io"
 	"net/url"
 )
@@ -26,45 +26,74 @@ import (
 //
 // user must be "me", userPrincipalName, or id
 func (c *MSGraphClient) ListContacts(query url.Values, user string) (response ContactResponse, err error) {
-	var body []byte
-
-	var url string
+	return c.ListContactsWithContext(context.Background(), query, user)
+}
 
-	if user == "me" {
-		url = "/me/contacts"
-	} else {
-		url = "/users/" + user + "/contacts"
-	}
+// ListContactsWithContext is like ListContacts, but uses ctx for the request.
+func (c *MSGraphClient) ListContactsWithContext(ctx context.Context, query url.Values, user string) (response ContactResponse, err error) {
+	var body []byte
 
-	body, err = c.Get(url, query)
+	body, err = c.GetWithContext(ctx, contactsURL(user), query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
 
+// ListContactsPager returns a Pager for the Contact items of the collection returned by ListContacts.
+func (c *MSGraphClient) ListContactsPager(query url.Values, user string) *Pager {
+	return c.NewPager(contactsURL(user), query)
+}
+
 // UpdateContact updates the properties of a contact object.
 //
 // user must be "me", userPrincipalName, or id
+//
+// Use IfMatch with the ETag of the contact to update it only if it hasn't changed.
 func (c *MSGraphClient) UpdateContact(query url.Values, user string, contactID string, data io.Reader) (contact Contact, err error) {
-	var body []byte
-	var url string
+	return c.UpdateContactWithContext(context.Background(), query, user, contactID, data)
+}
 
-	if user == "me" {
-		url = "/me/contacts/" + contactID
-	} else {
-		url = "/users/" + user + "/contacts/" + contactID
-	}
+// UpdateContactWithContext is like UpdateContact, but uses ctx for the request.
+func (c *MSGraphClient) UpdateContactWithContext(ctx context.Context, query url.Values, user string, contactID string, data io.Reader) (contact Contact, err error) {
+	var body []byte
 
-	body, err = c.Patch(url, query, data)
+	body, err = c.PatchWithContext(ctx, contactsURL(user)+"/"+contactID, query, data)
 	if err != nil {
 		return contact, err
 	}
 
-	err = json.Unmarshal(body, &contact)
+	err = decodeBody(body, &contact)
 
 	return contact, err
 }
+
+// DeleteContact deletes a contact object.
+//
+// user must be "me", userPrincipalName, or id
+func (c *MSGraphClient) DeleteContact(user string, contactID string) (err error) {
+	return c.DeleteContactWithContext(context.Background(), user, contactID)
+}
+
+// DeleteContactWithContext is like DeleteContact, but uses ctx for the request.
+func (c *MSGraphClient) DeleteContactWithContext(ctx context.Context, user string, contactID string) (err error) {
+	return c.DeleteWithContext(ctx, contactsURL(user)+"/"+contactID, nil)
+}
+
+// contactsURL returns the URL of the contacts of user, which must be "me", userPrincipalName, or id.
+func contactsURL(user string) string {
+	if user == "me" {
+		return "/me/contacts"
+	}
+
+	return "/users/" + user + "/contacts"
+}
+
+// ContactsDelta returns a Delta that tracks changes to the contacts of user.
+// Change.Item is a *Contact.
+func (c *MSGraphClient) ContactsDelta(user string, query url.Values, store CheckpointStore) *Delta {
+	return c.NewDelta(contactsURL(user)+"/delta", query, store, func() interface{} { return &Contact{} })
+}
@@ -0,0 +1,300 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ChangeType is the type of a Change returned by a delta query.
+type ChangeType int
+
+// Types of changes returned by a delta query.
+const (
+	// ChangeAdded is an item returned by the initial sync, or by a full
+	// sync when the delta link has expired.
+	ChangeAdded ChangeType = iota + 1
+
+	// ChangeUpdated is an item that was created or changed since the last sync.
+	ChangeUpdated
+
+	// ChangeRemoved is an item that was deleted, or is no longer in the scope of the query.
+	ChangeRemoved
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeAdded:
+		return "added"
+	case ChangeUpdated:
+		return "updated"
+	case ChangeRemoved:
+		return "removed"
+	}
+
+	return "unknown"
+}
+
+// Change is a change to an item returned by a delta query.
+type Change struct {
+	// Type is the type of change.
+	Type ChangeType
+
+	// ID is the ID of the item.
+	ID string
+
+	// RemovedReason is "deleted" or "changed", e.g. moved out of scope, for ChangeRemoved.
+	RemovedReason string
+
+	// Item is the decoded item, e.g. a *DriveItem for DriveItemDelta.
+	// For a removed item, usually only the ID is set.
+	Item interface{}
+
+	// Raw is the JSON of the item.
+	Raw json.RawMessage
+}
+
+// deltaPage is a page of a delta query response.
+type deltaPage struct {
+	ODataNextLink  string            `json:"@odata.nextLink,omitempty"`
+	ODataDeltaLink string            `json:"@odata.deltaLink,omitempty"`
+	Value          []json.RawMessage `json:"value"`
+}
+
+// deltaItem contains the properties that identify a removed item.
+type deltaItem struct {
+	ID string `json:"id"`
+
+	Removed *struct {
+		Reason string `json:"reason"`
+	} `json:"@removed,omitempty"`
+
+	// Deleted is set for a removed DriveItem instead of @removed.
+	Deleted *struct {
+		State string `json:"state"`
+	} `json:"deleted,omitempty"`
+}
+
+// Delta tracks changes to a collection using a delta query, saving the
+// @odata.deltaLink in a CheckpointStore so that each Sync only returns
+// the changes since the previous Sync, even across restarts.
+//
+// Delta example:
+//
+//	delta := client.DriveItemDelta(driveID, nil, msgraph4go.NewFileCheckpointStore(".delta.json"))
+//	err := delta.Sync(ctx, func(change msgraph4go.Change) error {
+//		item := change.Item.(*msgraph4go.DriveItem)
+//		fmt.Println(change.Type, item.Name)
+//		return nil
+//	})
+//
+// See https://docs.microsoft.com/en-us/graph/delta-query-overview for more information.
+type Delta struct {
+	c         *MSGraphClient
+	urlString string
+	query     url.Values
+	store     CheckpointStore
+	newItem   func() interface{}
+}
+
+// NewDelta returns a Delta for the delta query at urlString, e.g. "/users/delta".
+//
+// The checkpoint is saved in store, which defaults to a MemoryCheckpointStore
+// if nil. newItem returns a pointer to a new item, e.g. &User{}, which is
+// used for Change.Item. If newItem is nil, a map[string]interface{} is used.
+func (c *MSGraphClient) NewDelta(urlString string, query url.Values, store CheckpointStore, newItem func() interface{}) *Delta {
+	if store == nil {
+		store = NewMemoryCheckpointStore()
+	}
+
+	return &Delta{
+		c:         c,
+		urlString: urlString,
+		query:     query,
+		store:     store,
+		newItem:   newItem,
+	}
+}
+
+// Key returns the key of the checkpoint in the CheckpointStore, which
+// identifies the delta query, including the user set by AsUser.
+func (d *Delta) Key() (string, error) {
+	urlString, err := d.c.resolveMe(d.urlString)
+	if err != nil {
+		return "", err
+	}
+
+	key := d.c.graphURL + urlString
+	if len(d.query) > 0 {
+		key += "?" + d.query.Encode()
+	}
+
+	return key, nil
+}
+
+// Sync calls handle for each change since the previous Sync, or for every
+// item if there is no checkpoint, following @odata.nextLink until the
+// @odata.deltaLink is returned, which is then saved as the checkpoint.
+//
+// If handle returns an error, Sync stops and returns the error without
+// saving the checkpoint, so the changes are returned again by the next Sync.
+//
+// If the saved delta link has expired, which is a 410 Gone response, or a
+// 400 Bad Request with the code syncStateNotFound or resyncRequired, a full
+// sync is done, and every item is returned as ChangeAdded.
+func (d *Delta) Sync(ctx context.Context, handle func(change Change) error) error {
+	key, err := d.Key()
+	if err != nil {
+		return err
+	}
+
+	checkpoint, err := d.store.Load(key)
+	if err != nil && !errors.Is(err, ErrCheckpointNotFound) {
+		return err
+	}
+
+	initial := checkpoint == nil
+	nextURL, nextQuery := d.urlString, d.query
+	if !initial {
+		nextURL, nextQuery = checkpoint.DeltaLink, nil
+	}
+
+	for {
+		body, err := d.c.GetWithContext(ctx, nextURL, nextQuery)
+
+		// the delta link expired, so start again with a full sync
+		if err != nil && !initial && resyncRequired(err) {
+			err = d.store.Delete(key)
+			if err != nil {
+				return err
+			}
+
+			initial = true
+			nextURL, nextQuery = d.urlString, d.query
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		var page deltaPage
+		err = decodeBody(body, &page)
+		if err != nil {
+			return err
+		}
+
+		for _, raw := range page.Value {
+			change, err := d.change(raw, initial)
+			if err != nil {
+				return err
+			}
+
+			err = handle(change)
+			if err != nil {
+				return err
+			}
+		}
+
+		if page.ODataNextLink != "" {
+			nextURL, nextQuery = page.ODataNextLink, nil
+			continue
+		}
+
+		if page.ODataDeltaLink == "" {
+			return errors.New("msgraph4go: delta response has no @odata.nextLink or @odata.deltaLink")
+		}
+
+		return d.store.Save(key, &Checkpoint{DeltaLink: page.ODataDeltaLink, SyncedAt: time.Now()})
+	}
+}
+
+// resyncCodes are the lowercase error codes of a 400 Bad Request response
+// to a delta query whose delta link is no longer valid.
+var resyncCodes = map[string]bool{
+	"syncstatenotfound": true,
+	"resyncrequired":    true,
+}
+
+// resyncRequired returns true if err means that the delta link is no
+// longer valid, so a full sync is required.
+func resyncRequired(err error) bool {
+	var graphErr *GraphErrorResponse
+	if !errors.As(err, &graphErr) {
+		return false
+	}
+
+	switch graphErr.StatusCode {
+	case http.StatusGone:
+		return true
+	case http.StatusBadRequest:
+		return graphErr.ODataError != nil && resyncCodes[strings.ToLower(graphErr.ODataError.Code)]
+	}
+
+	return false
+}
+
+// Reset deletes the checkpoint, so the next Sync returns every item.
+func (d *Delta) Reset() error {
+	key, err := d.Key()
+	if err != nil {
+		return err
+	}
+
+	return d.store.Delete(key)
+}
+
+// change returns the Change for the JSON of an item.
+func (d *Delta) change(raw json.RawMessage, initial bool) (change Change, err error) {
+	var item deltaItem
+	err = json.Unmarshal(raw, &item)
+	if err != nil {
+		return change, err
+	}
+
+	change.ID = item.ID
+	change.Raw = raw
+
+	switch {
+	case item.Removed != nil:
+		change.Type = ChangeRemoved
+		change.RemovedReason = item.Removed.Reason
+	case item.Deleted != nil:
+		change.Type = ChangeRemoved
+		change.RemovedReason = "deleted"
+	case initial:
+		change.Type = ChangeAdded
+	default:
+		change.Type = ChangeUpdated
+	}
+
+	if d.newItem != nil {
+		change.Item = d.newItem()
+		err = json.Unmarshal(raw, change.Item)
+	} else {
+		var m map[string]interface{}
+		err = json.Unmarshal(raw, &m)
+		change.Item = m
+	}
+
+	return change, err
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bnixon67/msgraph4go"
+	"github.com/bnixon67/msgraph4go/graphtest"
+)
+
+// syncChanges returns the changes of a Sync of delta as "type name" or "type id", sorted.
+func syncChanges(t *testing.T, delta *msgraph4go.Delta) []string {
+	t.Helper()
+
+	var changes []string
+	err := delta.Sync(context.Background(), func(change msgraph4go.Change) error {
+		name := change.ID
+		if contact, ok := change.Item.(*msgraph4go.Contact); ok && contact.DisplayName != "" {
+			name = contact.DisplayName
+		}
+		changes = append(changes, change.Type.String()+" "+name)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(changes)
+	return changes
+}
+
+// checkChanges fails the test unless got and want are the same.
+func checkChanges(t *testing.T, got []string, want ...string) {
+	t.Helper()
+
+	if strings.Join(got, ", ") != strings.Join(want, ", ") {
+		t.Errorf("changes = %q, want %q", got, want)
+	}
+}
+
+func TestDeltaCheckpoint(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	ann := server.AddContact(msgraph4go.Contact{DisplayName: "Ann"})
+	bob := server.AddContact(msgraph4go.Contact{DisplayName: "Bob"})
+
+	fileName := filepath.Join(t.TempDir(), "delta.json")
+	delta := server.Client().ContactsDelta("me", nil, msgraph4go.NewFileCheckpointStore(fileName))
+
+	checkChanges(t, syncChanges(t, delta), "added Ann", "added Bob")
+
+	key, err := delta.Key()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := msgraph4go.NewFileCheckpointStore(fileName).Load(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.DeltaLink == "" || checkpoint.SyncedAt.IsZero() {
+		t.Errorf("checkpoint = %+v, want a delta link and time", checkpoint)
+	}
+
+	if err := server.Update("/me/contacts/"+ann.ID, map[string]string{"displayName": "Anne"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.Remove("/me/contacts/" + bob.ID); err != nil {
+		t.Fatal(err)
+	}
+	server.AddContact(msgraph4go.Contact{DisplayName: "Cat"})
+
+	// a new Delta using the same file continues from the checkpoint, as after a restart
+	restarted := server.Client().ContactsDelta("me", nil, msgraph4go.NewFileCheckpointStore(fileName))
+	checkChanges(t, syncChanges(t, restarted), "removed "+bob.ID, "updated Anne", "updated Cat")
+
+	// there are no changes since the last sync
+	checkChanges(t, syncChanges(t, restarted))
+
+	// a failed handler doesn't save the checkpoint, so the changes are returned again
+	server.AddContact(msgraph4go.Contact{DisplayName: "Dan"})
+	errHandler := errors.New("handler failed")
+	err = restarted.Sync(context.Background(), func(msgraph4go.Change) error { return errHandler })
+	if !errors.Is(err, errHandler) {
+		t.Errorf("Sync = %v, want %v", err, errHandler)
+	}
+	checkChanges(t, syncChanges(t, restarted), "updated Dan")
+
+	if err := restarted.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	checkChanges(t, syncChanges(t, restarted), "added Anne", "added Cat", "added Dan")
+}
+
+func TestDeltaResync(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	server.AddContact(msgraph4go.Contact{DisplayName: "Ann"})
+
+	delta := server.Client().ContactsDelta("me", nil, nil)
+	checkChanges(t, syncChanges(t, delta), "added Ann")
+
+	// the delta link is rejected with 410 Gone
+	server.AddContact(msgraph4go.Contact{DisplayName: "Bob"})
+	server.ExpireDeltaTokens()
+	checkChanges(t, syncChanges(t, delta), "added Ann", "added Bob")
+
+	// the delta link is rejected with 400 Bad Request and a resync code
+	for _, code := range []string{"syncStateNotFound", "resyncRequired"} {
+		server.InjectFault(graphtest.Fault{
+			Path:       "/me/contacts/delta",
+			StatusCode: http.StatusBadRequest,
+			Code:       code,
+			Message:    "The sync state is not valid.",
+			Times:      1,
+		})
+		checkChanges(t, syncChanges(t, delta), "added Ann", "added Bob")
+	}
+
+	// other errors are returned, and the checkpoint is kept
+	server.InjectFault(graphtest.Fault{
+		Path:       "/me/contacts/delta",
+		StatusCode: http.StatusBadRequest,
+		Code:       "BadRequest",
+		Message:    "Invalid request.",
+		Times:      1,
+	})
+	err := delta.Sync(context.Background(), func(msgraph4go.Change) error { return nil })
+	var graphErr *msgraph4go.GraphErrorResponse
+	if !errors.As(err, &graphErr) || graphErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Sync = %v, want 400 Bad Request", err)
+	}
+	checkChanges(t, syncChanges(t, delta))
+}
@@ -0,0 +1,257 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Errors returned by DeviceCodeLogin.
+var (
+	// ErrDeviceCodeExpired is returned if the user didn't sign in before the device code expired.
+	ErrDeviceCodeExpired = errors.New("msgraph4go: device code expired")
+
+	// ErrAuthorizationDeclined is returned if the user declined the authorization request.
+	ErrAuthorizationDeclined = errors.New("msgraph4go: authorization declined")
+)
+
+// grantTypeDeviceCode is the grant type used to poll the token endpoint.
+const grantTypeDeviceCode = "urn:ietf:params:oauth:grant-type:device_code"
+
+// DeviceCode is the response to a device authorization request.
+//
+// See https://docs.microsoft.com/en-us/azure/active-directory/develop/v2-oauth2-device-code
+type DeviceCode struct {
+	// DeviceCode is used to poll the token endpoint; it is not shown to the user.
+	DeviceCode string `json:"device_code"`
+
+	// UserCode is the code the user enters at VerificationURI.
+	UserCode string `json:"user_code"`
+
+	// VerificationURI is the URL the user visits to sign in.
+	VerificationURI string `json:"verification_uri"`
+
+	// ExpiresIn is the number of seconds before the codes expire.
+	ExpiresIn int `json:"expires_in"`
+
+	// Interval is the number of seconds to wait between polling requests.
+	Interval int `json:"interval"`
+
+	// Message is a localized message with instructions for the user.
+	Message string `json:"message"`
+}
+
+// deviceTokenResponse is a response from the token endpoint while polling.
+type deviceTokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	RefreshToken     string `json:"refresh_token"`
+	ExpiresIn        int    `json:"expires_in"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// DeviceCodeLogin returns a LoginFunc that uses the OAuth 2.0 device
+// authorization grant, which works without a browser on the client host.
+//
+// prompt, which is required, is called with the user code and verification
+// URI to show to the user, who can sign in using a browser on any device. The token endpoint
+// is polled until the user signs in, declines, or the code expires.
+//
+// The device code endpoint is derived from the token endpoint of the
+// oauth2.Config, so it follows WithAuthorityHost and WithTenant.
+func DeviceCodeLogin(prompt func(DeviceCode)) LoginFunc {
+	return deviceCodeLogin(prompt, sleepContext)
+}
+
+// deviceCodeLogin is DeviceCodeLogin using wait between polling requests.
+func deviceCodeLogin(prompt func(DeviceCode), wait func(ctx context.Context, d time.Duration) error) LoginFunc {
+	return func(ctx context.Context, conf *oauth2.Config, loginHint string) (*oauth2.Token, error) {
+		// the user can't sign in without the user code
+		if prompt == nil {
+			return nil, errors.New("msgraph4go: device code login requires a prompt")
+		}
+
+		code, err := requestDeviceCode(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+
+		prompt(code)
+
+		return pollDeviceToken(ctx, conf, code, wait)
+	}
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// deviceCodeURL returns the device code endpoint that corresponds to the token endpoint of conf.
+func deviceCodeURL(conf *oauth2.Config) string {
+	return strings.TrimSuffix(conf.Endpoint.TokenURL, "/token") + "/devicecode"
+}
+
+// contextClient returns the HTTP client set by the oauth2.HTTPClient context value, if any.
+func contextClient(ctx context.Context) *http.Client {
+	if client, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && client != nil {
+		return client
+	}
+
+	return http.DefaultClient
+}
+
+// postForm posts values to urlString, returning the response status code and body.
+func postForm(ctx context.Context, urlString string, values url.Values) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, urlString, strings.NewReader(values.Encode()))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := contextClient(ctx).Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+
+	return resp.StatusCode, body, err
+}
+
+// requestDeviceCode starts the device authorization grant.
+func requestDeviceCode(ctx context.Context, conf *oauth2.Config) (code DeviceCode, err error) {
+	values := url.Values{
+		"client_id": {conf.ClientID},
+		"scope":     {strings.Join(conf.Scopes, " ")},
+	}
+
+	status, body, err := postForm(ctx, deviceCodeURL(conf), values)
+	if err != nil {
+		return code, err
+	}
+
+	if status != http.StatusOK {
+		var resp deviceTokenResponse
+		json.Unmarshal(body, &resp)
+		return code, fmt.Errorf("msgraph4go: device code request failed: %d %s %s",
+			status, resp.Error, resp.ErrorDescription)
+	}
+
+	err = json.Unmarshal(body, &code)
+	if err != nil {
+		return code, err
+	}
+
+	if code.DeviceCode == "" {
+		return code, errors.New("msgraph4go: device code missing from response")
+	}
+
+	return code, nil
+}
+
+// pollDeviceToken polls the token endpoint until the user completes or
+// declines the sign in, using wait between requests.
+func pollDeviceToken(ctx context.Context, conf *oauth2.Config, code DeviceCode, wait func(ctx context.Context, d time.Duration) error) (*oauth2.Token, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	deadline := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	values := url.Values{
+		"grant_type":  {grantTypeDeviceCode},
+		"client_id":   {conf.ClientID},
+		"device_code": {code.DeviceCode},
+	}
+
+	for {
+		if err := wait(ctx, interval); err != nil {
+			return nil, err
+		}
+
+		if code.ExpiresIn > 0 && time.Now().After(deadline) {
+			return nil, ErrDeviceCodeExpired
+		}
+
+		_, body, err := postForm(ctx, conf.Endpoint.TokenURL, values)
+		if err != nil {
+			return nil, err
+		}
+
+		var resp deviceTokenResponse
+		err = json.Unmarshal(body, &resp)
+		if err != nil {
+			return nil, err
+		}
+
+		switch resp.Error {
+		case "":
+			if resp.AccessToken == "" {
+				return nil, errors.New("msgraph4go: access token missing from response")
+			}
+
+			token := &oauth2.Token{
+				AccessToken:  resp.AccessToken,
+				TokenType:    resp.TokenType,
+				RefreshToken: resp.RefreshToken,
+			}
+			if resp.ExpiresIn > 0 {
+				token.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+			}
+
+			return token, nil
+
+		case "authorization_pending":
+			// the user hasn't finished signing in, so keep polling
+
+		case "slow_down":
+			interval += 5 * time.Second
+
+		case "expired_token", "code_expired":
+			return nil, ErrDeviceCodeExpired
+
+		case "authorization_declined", "access_denied":
+			return nil, ErrAuthorizationDeclined
+
+		default:
+			return nil, &AuthError{Op: "device code login", Code: resp.Error, Description: resp.ErrorDescription}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// newDeviceCodeServer returns a stand-in for the device code and token
+// endpoints, which returns responses from the token endpoint in order.
+func newDeviceCodeServer(t *testing.T, responses ...map[string]interface{}) *httptest.Server {
+	t.Helper()
+
+	var mu sync.Mutex
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/tenant/oauth2/v2.0/devicecode":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"device_code":      "device-code",
+				"user_code":        "ABCD-EFGH",
+				"verification_uri": "https://microsoft.com/devicelogin",
+				"expires_in":       900,
+				"interval":         5,
+				"message":          "To sign in, use a web browser.",
+			})
+
+		case "/tenant/oauth2/v2.0/token":
+			if r.PostForm.Get("grant_type") != grantTypeDeviceCode || r.PostForm.Get("device_code") != "device-code" {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(map[string]string{"error": "invalid_request"})
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if len(responses) == 0 {
+				t.Error("too many token requests")
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+
+			if _, ok := responses[0]["error"]; ok {
+				w.WriteHeader(http.StatusBadRequest)
+			}
+			json.NewEncoder(w).Encode(responses[0])
+			responses = responses[1:]
+
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestDeviceCodeLogin(t *testing.T) {
+	pending := map[string]interface{}{"error": "authorization_pending"}
+	slowDown := map[string]interface{}{"error": "slow_down"}
+	success := map[string]interface{}{
+		"access_token":  "access-token",
+		"refresh_token": "refresh-token",
+		"token_type":    "Bearer",
+		"expires_in":    3600,
+	}
+
+	tests := []struct {
+		name      string
+		responses []map[string]interface{}
+		err       error
+		waits     []time.Duration
+	}{
+		{
+			name:      "pending then success",
+			responses: []map[string]interface{}{pending, pending, success},
+			waits:     []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:      "slow down",
+			responses: []map[string]interface{}{slowDown, pending, slowDown, success},
+			waits:     []time.Duration{5 * time.Second, 10 * time.Second, 10 * time.Second, 15 * time.Second},
+		},
+		{
+			name:      "expired token",
+			responses: []map[string]interface{}{pending, {"error": "expired_token"}},
+			err:       ErrDeviceCodeExpired,
+			waits:     []time.Duration{5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:      "code expired",
+			responses: []map[string]interface{}{{"error": "code_expired"}},
+			err:       ErrDeviceCodeExpired,
+			waits:     []time.Duration{5 * time.Second},
+		},
+		{
+			name:      "authorization declined",
+			responses: []map[string]interface{}{{"error": "authorization_declined"}},
+			err:       ErrAuthorizationDeclined,
+			waits:     []time.Duration{5 * time.Second},
+		},
+		{
+			name:      "access denied",
+			responses: []map[string]interface{}{pending, {"error": "access_denied"}},
+			err:       ErrAuthorizationDeclined,
+			waits:     []time.Duration{5 * time.Second, 5 * time.Second},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newDeviceCodeServer(t, tt.responses...)
+
+			conf := &oauth2.Config{
+				ClientID: "client",
+				Scopes:   []string{"User.Read", "offline_access"},
+				Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/tenant/oauth2/v2.0/token"},
+			}
+
+			var prompted DeviceCode
+			var waits []time.Duration
+			wait := func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				return nil
+			}
+
+			ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
+			token, err := deviceCodeLogin(func(code DeviceCode) { prompted = code }, wait)(ctx, conf, "")
+
+			if prompted.UserCode != "ABCD-EFGH" {
+				t.Errorf("prompt called with %+v", prompted)
+			}
+			if !reflect.DeepEqual(waits, tt.waits) {
+				t.Errorf("waits = %v, want %v", waits, tt.waits)
+			}
+
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("error = %v, want %v", err, tt.err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if token.AccessToken != "access-token" || token.RefreshToken != "refresh-token" || token.Expiry.IsZero() {
+				t.Errorf("token = %+v", token)
+			}
+		})
+	}
+}
+
+func TestDeviceCodeLoginOtherError(t *testing.T) {
+	server := newDeviceCodeServer(t, map[string]interface{}{
+		"error":             "invalid_grant",
+		"error_description": "AADSTS70000: The provided value for the device_code is invalid.",
+	})
+
+	conf := &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/tenant/oauth2/v2.0/token"},
+	}
+
+	noWait := func(ctx context.Context, d time.Duration) error { return nil }
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient, server.Client())
+
+	_, err := deviceCodeLogin(func(DeviceCode) {}, noWait)(ctx, conf, "")
+
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.Code != "invalid_grant" {
+		t.Errorf("error = %v, want AuthError invalid_grant", err)
+	}
+}
+
+func TestDeviceCodeLoginCanceled(t *testing.T) {
+	server := newDeviceCodeServer(t)
+
+	conf := &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{TokenURL: server.URL + "/tenant/oauth2/v2.0/token"},
+	}
+
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), oauth2.HTTPClient, server.Client()))
+	cancel()
+
+	_, err := pollDeviceToken(ctx, conf, DeviceCode{DeviceCode: "device-code", Interval: 5}, sleepContext)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDeviceCodeLoginNilPrompt(t *testing.T) {
+	conf := &oauth2.Config{
+		ClientID: "client",
+		Endpoint: oauth2.Endpoint{TokenURL: "http://127.0.0.1:0/tenant/oauth2/v2.0/token"},
+	}
+
+	_, err := DeviceCodeLogin(nil)(context.Background(), conf, "")
+	if err == nil {
+		t.Error("DeviceCodeLogin(nil) succeeded, want error")
+	}
+}
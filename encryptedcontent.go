@@ -0,0 +1,142 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// ErrDataSignatureMismatch is returned when the signature of encrypted
+// resource data is not valid, so the data may have been tampered with.
+var ErrDataSignatureMismatch = errors.New("msgraph4go: data signature mismatch")
+
+// EncryptedContent is the encrypted resource data of a change notification
+// for a subscription with IncludeResourceData set.
+//
+// See https://docs.microsoft.com/en-us/graph/webhooks-with-resource-data for more information.
+type EncryptedContent struct {
+	// Data is the base64 encoded resource data, encrypted with the data key.
+	Data string `json:"data"`
+
+	// DataSignature is the base64 encoded HMAC-SHA256 of Data, using the data key.
+	DataSignature string `json:"dataSignature"`
+
+	// DataKey is the base64 encoded symmetric data key, encrypted with the
+	// public key of the EncryptionCertificate of the subscription.
+	DataKey string `json:"dataKey"`
+
+	// EncryptionCertificateID is the EncryptionCertificateID of the subscription.
+	EncryptionCertificateID string `json:"encryptionCertificateId"`
+
+	// EncryptionCertificateThumbprint is the SHA-1 thumbprint of the certificate, in hex.
+	EncryptionCertificateThumbprint string `json:"encryptionCertificateThumbprint"`
+}
+
+// Decrypt returns the decrypted resource data, which is JSON.
+//
+// The data key is decrypted using key, the private key of the encryption
+// certificate, and is used to verify the signature of the data before
+// decrypting it. ErrDataSignatureMismatch is returned if the signature is not valid.
+func (e *EncryptedContent) Decrypt(key *rsa.PrivateKey) ([]byte, error) {
+	if key == nil {
+		return nil, errors.New("msgraph4go: private key is required")
+	}
+
+	encryptedKey, err := base64.StdEncoding.DecodeString(e.DataKey)
+	if err != nil {
+		return nil, fmt.Errorf("msgraph4go: decoding data key: %w", err)
+	}
+
+	data, err := base64.StdEncoding.DecodeString(e.Data)
+	if err != nil {
+		return nil, fmt.Errorf("msgraph4go: decoding data: %w", err)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(e.DataSignature)
+	if err != nil {
+		return nil, fmt.Errorf("msgraph4go: decoding data signature: %w", err)
+	}
+
+	// the data key is encrypted using RSA with OAEP padding
+	dataKey, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, encryptedKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("msgraph4go: decrypting data key: %w", err)
+	}
+
+	// verify the signature before decrypting
+	mac := hmac.New(sha256.New, dataKey)
+	mac.Write(data)
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return nil, ErrDataSignatureMismatch
+	}
+
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("msgraph4go: decrypting data: %w", err)
+	}
+
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("msgraph4go: decrypting data: invalid length")
+	}
+
+	// the data is encrypted using AES-CBC, with the first 16 bytes of the data key as the IV
+	plain := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, dataKey[:aes.BlockSize]).CryptBlocks(plain, data)
+
+	return pkcs7Unpad(plain, aes.BlockSize)
+}
+
+// pkcs7Unpad removes the PKCS7 padding from data.
+func pkcs7Unpad(data []byte, blockSize int) ([]byte, error) {
+	invalid := errors.New("msgraph4go: decrypting data: invalid padding")
+
+	if len(data) == 0 {
+		return nil, invalid
+	}
+
+	n := int(data[len(data)-1])
+	if n == 0 || n > blockSize || n > len(data) {
+		return nil, invalid
+	}
+
+	if !bytes.Equal(data[len(data)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, invalid
+	}
+
+	return data[:len(data)-n], nil
+}
+
+// SetEncryptionCertificate sets the EncryptionCertificate and EncryptionCertificateID
+// of the subscription, so notifications include resource data encrypted
+// with the public key of cert. The id is returned as the EncryptionCertificateID
+// of each notification, to identify the private key used to decrypt the data.
+func (s *Subscription) SetEncryptionCertificate(cert *x509.Certificate, id string) {
+	s.IncludeResourceData = true
+	s.EncryptionCertificate = base64.StdEncoding.EncodeToString(cert.Raw)
+	s.EncryptionCertificateID = id
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+// encryptContent encrypts data the way Microsoft Graph encrypts the
+// resource data of a change notification for the public key of key.
+func encryptContent(t *testing.T, key *rsa.PrivateKey, data []byte) *EncryptedContent {
+	t.Helper()
+
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		t.Fatal(err)
+	}
+
+	n := aes.BlockSize - len(data)%aes.BlockSize
+	padded := append(append([]byte{}, data...), bytes.Repeat([]byte{byte(n)}, n)...)
+
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, dataKey[:aes.BlockSize]).CryptBlocks(encrypted, padded)
+
+	mac := hmac.New(sha256.New, dataKey)
+	mac.Write(encrypted)
+
+	encryptedKey, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, &key.PublicKey, dataKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &EncryptedContent{
+		Data:                    base64.StdEncoding.EncodeToString(encrypted),
+		DataSignature:           base64.StdEncoding.EncodeToString(mac.Sum(nil)),
+		DataKey:                 base64.StdEncoding.EncodeToString(encryptedKey),
+		EncryptionCertificateID: "cert",
+	}
+}
+
+// tamper returns s, a base64 encoded value, with the first byte changed.
+func tamper(t *testing.T, s string) string {
+	t.Helper()
+
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[0] ^= 1
+
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func TestEncryptedContentDecrypt(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte(`{"id":"1","subject":"Hello"}`)
+
+	got, err := encryptContent(t, key, want).Decrypt(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Decrypt = %s, want %s", got, want)
+	}
+
+	// a multiple of the block size is padded with a full block
+	want = bytes.Repeat([]byte("a"), 2*aes.BlockSize)
+	got, err = encryptContent(t, key, want).Decrypt(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Decrypt = %s, want %s", got, want)
+	}
+}
+
+func TestEncryptedContentDecryptTampered(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte(`{"id":"1"}`)
+
+	t.Run("data", func(t *testing.T) {
+		content := encryptContent(t, key, data)
+		content.Data = tamper(t, content.Data)
+
+		if _, err := content.Decrypt(key); !errors.Is(err, ErrDataSignatureMismatch) {
+			t.Errorf("Decrypt error = %v, want %v", err, ErrDataSignatureMismatch)
+		}
+	})
+
+	t.Run("dataSignature", func(t *testing.T) {
+		content := encryptContent(t, key, data)
+		content.DataSignature = tamper(t, content.DataSignature)
+
+		if _, err := content.Decrypt(key); !errors.Is(err, ErrDataSignatureMismatch) {
+			t.Errorf("Decrypt error = %v, want %v", err, ErrDataSignatureMismatch)
+		}
+	})
+
+	t.Run("dataKey", func(t *testing.T) {
+		content := encryptContent(t, key, data)
+		content.DataKey = tamper(t, content.DataKey)
+
+		if _, err := content.Decrypt(key); err == nil {
+			t.Error("Decrypt succeeded with a tampered data key")
+		}
+	})
+
+	t.Run("wrong key", func(t *testing.T) {
+		if _, err := encryptContent(t, key, data).Decrypt(otherKey); err == nil {
+			t.Error("Decrypt succeeded with the wrong private key")
+		}
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		if _, err := encryptContent(t, key, data).Decrypt(nil); err == nil {
+			t.Error("Decrypt succeeded without a private key")
+		}
+	})
+}
@@ -16,10 +16,57 @@ limitations under the License.
 
 package msgraph4go
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinel errors that match a GraphErrorResponse with the corresponding
+// HTTP status code using errors.Is, e.g. errors.Is(err, ErrNotFound).
+var (
+	// ErrUnauthorized matches a 401 Unauthorized response.
+	ErrUnauthorized = errors.New("msgraph4go: unauthorized")
+
+	// ErrNotFound matches a 404 Not Found response.
+	ErrNotFound = errors.New("msgraph4go: not found")
+
+	// ErrConflict matches a 409 Conflict response.
+	ErrConflict = errors.New("msgraph4go: conflict")
+
+	// ErrPreconditionFailed matches a 412 Precondition Failed response, e.g. an ETag mismatch.
+	ErrPreconditionFailed = errors.New("msgraph4go: precondition failed")
+
+	// ErrThrottled matches a 429 Too Many Requests response.
+	ErrThrottled = errors.New("msgraph4go: throttled")
+
+	// ErrNotModified matches a NotModifiedError, the 304 Not Modified
+	// response to a request using IfNoneMatch.
+	ErrNotModified = errors.New("msgraph4go: not modified")
+)
+
 // InnerError are additional error objects that may be more specific than the top level error.
 type InnerError struct {
-	RequestId string `json:"request-id,omitempty"`
-	Date      string `json:"date,omitempty"`
+	// Code is a more specific error code, if any.
+	Code string `json:"code,omitempty"`
+
+	RequestId       string `json:"request-id,omitempty"`
+	ClientRequestId string `json:"client-request-id,omitempty"`
+	Date            string `json:"date,omitempty"`
+
+	// Optional. A nested error that is more specific than this error.
+	InnerError *InnerError `json:"innerError,omitempty"`
+}
+
+// ErrorDetail is an additional error about a specific target, such as a property of the request.
+type ErrorDetail struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+	Target  string `json:"target,omitempty"`
 }
 
 // ODataError contains information about the Error
@@ -31,33 +78,136 @@ type ODataError struct {
 	// This should not be displayed to the user directly.
 	Message string `json:"message,omitempty"`
 
+	// Optional. The target of the error, e.g. a property of the request.
+	Target string `json:"target,omitempty"`
+
+	// Optional. Additional errors, e.g. one for each invalid property.
+	Details []ErrorDetail `json:"details,omitempty"`
+
 	// Optional. Additional error objects that may be more specific than the top level error.
 	InnerError *InnerError `json:"innerError,omitempty"`
 }
 
-// GraphErrorResponse contains a single property named error.
+// GraphErrorResponse contains a single property named error, along with
+// details of the HTTP response that returned it.
+//
+// Use errors.Is with ErrNotFound, ErrThrottled, ErrUnauthorized,
+// ErrConflict, or ErrPreconditionFailed to check for common errors.
+// A 304 Not Modified response is a *NotModifiedError instead.
 type GraphErrorResponse struct {
 	ODataError *ODataError `json:"error,omitempty"`
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int `json:"-"`
+
+	// Header is the header of the response.
+	Header http.Header `json:"-"`
+
+	// RequestID identifies the request for Microsoft support.
+	RequestID string `json:"-"`
+
+	// ClientRequestID is the client-request-id sent with the request, if any.
+	ClientRequestID string `json:"-"`
+
+	// RetryAfter is how long to wait before retrying, from the Retry-After header, if any.
+	RetryAfter time.Duration `json:"-"`
+
+	// Body is the response body if it isn't a Graph error, e.g. a HTML page from a proxy.
+	Body []byte `json:"-"`
 }
 
 // Error return a string representation of the error
 func (e *GraphErrorResponse) Error() string {
-	return VarToJsonString(e.ODataError)
-}
+	var b strings.Builder
 
-// codeIsError return true if the code is a error Status Code per
-// https://docs.microsoft.com/en-us/graph/errors?context=graph%2Fapi%2F1.0&view=graph-rest-1.0
-func codeIsError(code int) bool {
-	// Microsoft Graph error responses and resource types
-	// https://docs.microsoft.com/en-us/graph/errors
-	var errorCodes = []int{400, 401, 403, 404, 405, 406, 409, 410,
-		411, 412, 413, 415, 416, 422, 423, 429, 500, 501, 503,
-		504, 507, 509}
-
-	for _, n := range errorCodes {
-		if code == n {
-			return true
+	b.WriteString("msgraph4go: ")
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, "%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	if e.Body != nil {
+		// the body isn't a Graph error, so show the start of it
+		body := strings.TrimSpace(string(e.Body))
+		if len(body) > 200 {
+			body = body[:200] + "..."
+		}
+		if body != "" {
+			fmt.Fprintf(&b, ": %s", body)
 		}
+	} else if e.ODataError != nil {
+		if e.ODataError.Code != "" {
+			fmt.Fprintf(&b, ": %s", e.ODataError.Code)
+		}
+		if e.ODataError.Message != "" {
+			fmt.Fprintf(&b, ": %s", e.ODataError.Message)
+		}
+	}
+	if e.RequestID != "" {
+		fmt.Fprintf(&b, " (request-id %s)", e.RequestID)
+	}
+
+	return b.String()
+}
+
+// Is reports whether the status code of e matches a sentinel error such as ErrNotFound.
+func (e *GraphErrorResponse) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrPreconditionFailed:
+		return e.StatusCode == http.StatusPreconditionFailed
+	case ErrThrottled:
+		return e.StatusCode == http.StatusTooManyRequests
 	}
+
 	return false
 }
+
+// newGraphError returns a GraphErrorResponse for a response with statusCode, header, and body.
+//
+// If body isn't a Graph error, then an error is synthesized from the status code.
+func newGraphError(statusCode int, header http.Header, body []byte) *GraphErrorResponse {
+	if header == nil {
+		header = http.Header{}
+	}
+
+	resError := &GraphErrorResponse{
+		StatusCode:      statusCode,
+		Header:          header,
+		RequestID:       header.Get("request-id"),
+		ClientRequestID: header.Get("client-request-id"),
+	}
+
+	if err := json.Unmarshal(body, resError); err != nil || resError.ODataError == nil {
+		resError.Body = body
+		resError.ODataError = &ODataError{
+			Code:    strconv.Itoa(statusCode),
+			Message: http.StatusText(statusCode),
+		}
+	}
+
+	// use the innermost request IDs if they aren't in the header
+	for inner := resError.ODataError.InnerError; inner != nil; inner = inner.InnerError {
+		if resError.RequestID == "" {
+			resError.RequestID = inner.RequestId
+		}
+		if resError.ClientRequestID == "" {
+			resError.ClientRequestID = inner.ClientRequestId
+		}
+	}
+
+	if delay, ok := parseRetryAfter(header.Get("Retry-After")); ok {
+		resError.RetryAfter = delay
+	}
+
+	return resError
+}
+
+// codeIsError return true if the code is a error Status Code, which is any
+// code that isn't 2xx. See https://docs.microsoft.com/en-us/graph/errors
+func codeIsError(code int) bool {
+	return code < 200 || code > 299
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGraphErrorIs(t *testing.T) {
+	sentinels := []error{ErrUnauthorized, ErrNotFound, ErrConflict, ErrPreconditionFailed, ErrThrottled, ErrNotModified}
+
+	tests := []struct {
+		err  error
+		want error // the only sentinel that matches, if any
+	}{
+		{newGraphError(http.StatusUnauthorized, nil, nil), ErrUnauthorized},
+		{newGraphError(http.StatusNotFound, nil, nil), ErrNotFound},
+		{newGraphError(http.StatusConflict, nil, nil), ErrConflict},
+		{newGraphError(http.StatusPreconditionFailed, nil, nil), ErrPreconditionFailed},
+		{newGraphError(http.StatusTooManyRequests, nil, nil), ErrThrottled},
+		{newGraphError(http.StatusBadRequest, nil, nil), nil},
+		{newGraphError(http.StatusServiceUnavailable, nil, nil), nil},
+		{&PreconditionFailedError{GraphErrorResponse: newGraphError(http.StatusPreconditionFailed, nil, nil)}, ErrPreconditionFailed},
+		{notModified(http.Header{}), ErrNotModified},
+		{errors.New("msgraph4go: not found"), nil},
+	}
+
+	for _, tt := range tests {
+		// the error is matched through any number of wrappers
+		wrapped := []error{
+			tt.err,
+			fmt.Errorf("get message: %w", tt.err),
+			fmt.Errorf("sync: %w", fmt.Errorf("get message: %w", tt.err)),
+		}
+
+		for _, err := range wrapped {
+			for _, sentinel := range sentinels {
+				if got := errors.Is(err, sentinel); got != (sentinel == tt.want) {
+					t.Errorf("errors.Is(%v, %v) = %v, want %v", err, sentinel, got, !got)
+				}
+			}
+		}
+	}
+}
+
+func TestGraphErrorAs(t *testing.T) {
+	graphErr := newGraphError(http.StatusPreconditionFailed, nil, nil)
+	err := fmt.Errorf("update contact: %w", &PreconditionFailedError{GraphErrorResponse: graphErr, ETag: `W/"2"`})
+
+	var precondition *PreconditionFailedError
+	if !errors.As(err, &precondition) || precondition.ETag != `W/"2"` {
+		t.Errorf("errors.As(%v, *PreconditionFailedError) = %+v", err, precondition)
+	}
+
+	var got *GraphErrorResponse
+	if !errors.As(err, &got) || got != graphErr {
+		t.Errorf("errors.As(%v, *GraphErrorResponse) = %+v, want %+v", err, got, graphErr)
+	}
+	if !strings.HasSuffix(err.Error(), `(current etag W/"2")`) {
+		t.Errorf("Error = %q, want the current ETag", err)
+	}
+}
+
+func TestNewGraphError(t *testing.T) {
+	header := http.Header{"Retry-After": {"30"}, "Client-Request-Id": {"client-id"}}
+	body := []byte(`{"error":{"code":"TooManyRequests","message":"Too many requests.",
+		"innerError":{"code":"throttled","innerError":{"request-id":"inner-id","date":"2021-03-01T12:00:00"}}}}`)
+
+	err := newGraphError(http.StatusTooManyRequests, header, body)
+	if err.ODataError.Code != "TooManyRequests" || err.ODataError.InnerError.Code != "throttled" {
+		t.Errorf("ODataError = %+v", err.ODataError)
+	}
+	if err.RequestID != "inner-id" || err.ClientRequestID != "client-id" {
+		t.Errorf("request IDs = %q %q, want inner-id client-id", err.RequestID, err.ClientRequestID)
+	}
+	if err.RetryAfter != 30*time.Second {
+		t.Errorf("RetryAfter = %v, want 30s", err.RetryAfter)
+	}
+	if err.Body != nil {
+		t.Errorf("Body = %q, want nil for a Graph error", err.Body)
+	}
+	if want := "msgraph4go: 429 Too Many Requests: TooManyRequests: Too many requests. (request-id inner-id)"; err.Error() != want {
+		t.Errorf("Error = %q, want %q", err.Error(), want)
+	}
+
+	// a body that isn't a Graph error, e.g. from a proxy
+	proxy := []byte("<html>" + strings.Repeat("x", 300) + "</html>")
+	err = newGraphError(http.StatusBadGateway, nil, proxy)
+	if err.ODataError.Code != "502" || err.ODataError.Message != "Bad Gateway" || string(err.Body) != string(proxy) {
+		t.Errorf("error = %+v", err)
+	}
+	if msg := err.Error(); !strings.HasPrefix(msg, "msgraph4go: 502 Bad Gateway: <html>xxx") || !strings.HasSuffix(msg, "...") {
+		t.Errorf("Error = %q, want the start of the body", msg)
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/bnixon67/msgraph4go"
+)
+
+func main() {
+	// Get Microsoft Application (client) ID
+	// The ID is not in the source code to avoid someone reusing the ID
+	clientID, present := os.LookupEnv("MSCLIENTID")
+	if !present {
+		log.Fatal("Must set MSCLIENTID")
+	}
+
+	msGraphClient := msgraph4go.New(".token.json", clientID, []string{"User.Read", "Files.Read"})
+
+	// get the profile and drive of the current user in a single request
+	responses, err := msGraphClient.Batch([]msgraph4go.BatchRequest{
+		{ID: "profile", Method: "GET", URL: "/me"},
+		{ID: "drive", Method: "GET", URL: "/me/drive"},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var user msgraph4go.User
+	err = responses[0].Decode(&user)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(msgraph4go.VarToJsonString(user))
+
+	var drive msgraph4go.Drive
+	err = responses[1].Decode(&drive)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(msgraph4go.VarToJsonString(drive))
+}
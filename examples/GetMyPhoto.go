@@ -18,6 +18,7 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 
@@ -41,16 +42,23 @@ func main() {
 
 	fmt.Println(msgraph4go.VarToJsonString(photoInfo))
 
-	photo, err := msGraphClient.GetMyPhoto(nil)
+	photo, err := msGraphClient.GetMyPhotoStream(nil)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer photo.Close()
 
 	fileName := "photo"
-	err = msgraph4go.WriteContentToFile(photo, fileName)
+	file, err := os.Create(fileName)
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer file.Close()
 
-	fmt.Printf("Photo saved to file named \"%s\"\n", fileName)
+	_, err = io.Copy(file, photo)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Printf("Photo (%s) saved to file named \"%s\"\n", photo.ContentType, fileName)
 }
@@ -17,10 +17,10 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
-	"net/url"
 	"os"
 	"strings"
 
@@ -67,43 +67,21 @@ func main() {
 
 	msGraphClient := msgraph4go.New(tokenFile, clientID, scopes)
 
-	// nextLink will contain the link to the next set of items, if any
-	var nextLink *url.URL
-
-	query := url.Values{}
+	// pager follows @odata.nextLink to get all of the contacts
+	pager := msGraphClient.ListContactsPager(nil, user)
 
 	n := 1
 
-	// loop until no more results
-	for {
-		contacts, err := msGraphClient.ListContacts(query, user)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		for _, contact := range contacts.Value {
-			fmt.Printf("Contact %d %s\n", n, contact.ID)
-			fmt.Printf("GivenName = %s Surname = %s\n",
-				contact.GivenName, contact.Surname)
-			fmt.Printf("DisplayName = %s\n", contact.DisplayName)
-			fmt.Println()
-			n++
-		}
-
-		// check if additional results
-		if contacts.ODataNextLink == "" {
-			// if ODataNextLink is empty, then no more items
-			break
-		} else {
-			// parse nextLink for query parameters
-			nextLink, err = url.Parse(contacts.ODataNextLink)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			// set query parameters for nextLink
-			query = nextLink.Query()
-		}
-
+	var contact msgraph4go.Contact
+	for pager.Next(context.Background(), &contact) {
+		fmt.Printf("Contact %d %s\n", n, contact.ID)
+		fmt.Printf("GivenName = %s Surname = %s\n",
+			contact.GivenName, contact.Surname)
+		fmt.Printf("DisplayName = %s\n", contact.DisplayName)
+		fmt.Println()
+		n++
+	}
+	if err := pager.Err(); err != nil {
+		log.Fatal(err)
 	}
 }
@@ -18,6 +18,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/csv"
 	"fmt"
 	"log"
@@ -26,7 +27,6 @@ import (
 	"path"
 	"strconv"
 	"strings"
-	"time"
 
 	"github.com/bnixon67/msgraph4go"
 )
@@ -60,54 +60,32 @@ func list(c *msgraph4go.MSGraphClient, path string) (err error) {
 	//query.Set("$top", "5")
 	query.Set("$orderby", "Name")
 
-	// nextLink will contain the link to the next set of driveItems, if any
-	var nextLink *url.URL
+	// pager follows @odata.nextLink to get all of the driveItems
+	pager := c.ListDriveItemChildrenByPathPager("me", path, query)
 
 	fileType := ""
 
-	// loop until no more results
-	for {
-		// get drive items
-		driveItems, err := c.ListDriveItemChildrenByPath("me", path, query)
-		if err != nil {
-			return err
+	// loop thru and display each driveItem
+	var item msgraph4go.DriveItem
+	for pager.Next(context.Background(), &item) {
+		if item.File != nil {
+			fileType = ""
 		}
-
-		// loop thru and display each driveItem
-		for _, item := range driveItems.Value {
-			t, _ := time.Parse(time.RFC3339, item.LastModifiedDateTime)
-			if item.File != nil {
-				fileType = ""
-			}
-			if item.Folder != nil {
-				fileType = "/"
-			}
-			if item.Package != nil {
-				fileType = "*"
-			}
-			fmt.Printf("%s %16s %s%s\n",
-				t.Local().Format("01/02/2006 03:04 PM"),
-				CommaFormat(item.Size),
-				item.Name,
-				fileType,
-			)
+		if item.Folder != nil {
+			fileType = "/"
 		}
-
-		if driveItems.ODataNextLink == "" {
-			// if ODataNextLink is empty, then no more items
-			break
-		} else {
-			// parse nextLink for query parameters
-			nextLink, err = url.Parse(driveItems.ODataNextLink)
-			if err != nil {
-				return err
-			}
-
-			// set query parameters for nextLink
-			query = nextLink.Query()
+		if item.Package != nil {
+			fileType = "*"
 		}
+		fmt.Printf("%s %16s %s%s\n",
+			item.LastModifiedDateTime.Time().Local().Format("01/02/2006 03:04 PM"),
+			CommaFormat(item.Size),
+			item.Name,
+			fileType,
+		)
 	}
-	return err
+
+	return pager.Err()
 }
 
 func help() {
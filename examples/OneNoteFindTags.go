@@ -17,10 +17,10 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log"
-	"net/url"
 	"os"
 	"strings"
 	"sync"
@@ -78,104 +78,78 @@ func main() {
 
 	msGraphClient := msgraph4go.New(".token.json", clientID, []string{"User.Read"})
 
-	var query url.Values
-	var nextLink *url.URL
+	query := msgraph4go.NewQuery().
+		// total number of pages
+		Count(true).
+		// sort by page title
+		OrderBy("title").
+		// exand parentNotebook to get displayName
+		Expand("parentNotebook", msgraph4go.NewQuery().Select("displayName")).
+		Expand("parentSection", msgraph4go.NewQuery().Select("displayName"))
 
 	// WaitGroup to fetch multiple pages
 	var wg sync.WaitGroup
 
 	// list of page may be returned by multiple queries
-	// @odata.nextLink has the link to next set of pages
-	for {
-		// ----- List Pages
-
-		// first query (no nextLink)
-		if nextLink == nil {
-			query = url.Values{}
-
-			// total number of pages
-			query.Set("$count", "true")
-
-			// sort by page title
-			//			query.Set("$orderby", "parentSection/displayName,title")
-			query.Set("$orderby", "title")
-
-			// exand parentNotebook to get displayName
-			query.Set("$expand", "parentNotebook,parentSection")
-
-			// filter on just one Notebook
-			//query.Set("$filter",
-			//	"parentNotebook/displayName eq 'UMB Notes'")
-		} else {
-			// set query value based on nextLink
-			query = nextLink.Query()
-		}
-
-		// get pages
-		pagesResponse, err := msGraphClient.ListPages(query)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// get nextLink (if any)
-		nextLink, _ = url.Parse(pagesResponse.ODataNextLink)
-
-		// loop thru each page
-		for _, page := range pagesResponse.Value {
-
-			// increase WaitGroup counter
-			wg.Add(1)
-
-			// run goroutine to get page content and find tags
-			go func(page msgraph4go.Page) {
-				// ensure we decrease WaitGroup
-				defer wg.Done()
-
-				/*
-					fmt.Printf("Checking  %s/%s/%s\n",
-						page.ParentNotebook.DisplayName,
-						page.ParentSection.DisplayName,
-						page.Title)
-				*/
-
-				// ----- Get Page Content
-				content, err := msGraphClient.GetPageContent(page.ID, nil)
-				if err != nil {
-					fmt.Printf("ERROR  %s/%s/%s\n",
-						page.ParentNotebook.DisplayName,
-						page.ParentSection.DisplayName,
-						page.Title)
-					log.Fatal(err)
-				}
+	// the pager follows @odata.nextLink to get the next set of pages
+	pager := msGraphClient.ListPagesPager(query.Values())
+
+	// filter on just one Notebook, which is done locally if Graph rejects the filter
+	//pager.Filter(msgraph4go.Eq("parentNotebook/displayName", "UMB Notes"))
+
+	// loop thru each page
+	var page msgraph4go.Page
+	for pager.Next(context.Background(), &page) {
+
+		// increase WaitGroup counter
+		wg.Add(1)
+
+		// run goroutine to get page content and find tags
+		go func(page msgraph4go.Page) {
+			// ensure we decrease WaitGroup
+			defer wg.Done()
+
+			/*
+				fmt.Printf("Checking  %s/%s/%s\n",
+					page.ParentNotebook.DisplayName,
+					page.ParentSection.DisplayName,
+					page.Title)
+			*/
+
+			// ----- Get Page Content
+			content, err := msGraphClient.GetPageContent(page.ID, nil)
+			if err != nil {
+				fmt.Printf("ERROR  %s/%s/%s\n",
+					page.ParentNotebook.DisplayName,
+					page.ParentSection.DisplayName,
+					page.Title)
+				log.Fatal(err)
+			}
 
-				// find to-do tags in the page content
-				v := find_tag(strings.NewReader(content), "to-do")
-
-				// at least one to-do tag found
-				if len(v) > 0 {
-					fmt.Printf("----- %3d %s/%s/%s\n",
-						len(v),
-						page.ParentNotebook.DisplayName,
-						page.ParentSection.DisplayName,
-						page.Title)
-					for n, v := range v {
-						fmt.Printf("%3d\t%v\n", n, v)
-					}
-					fmt.Println()
+			// find to-do tags in the page content
+			v := find_tag(strings.NewReader(content), "to-do")
+
+			// at least one to-do tag found
+			if len(v) > 0 {
+				fmt.Printf("----- %3d %s/%s/%s\n",
+					len(v),
+					page.ParentNotebook.DisplayName,
+					page.ParentSection.DisplayName,
+					page.Title)
+				for n, v := range v {
+					fmt.Printf("%3d\t%v\n", n, v)
 				}
-			}(page)
-
-			// ----- Write Page Content
-			//writeContent(page.Id+".html", content)
-		}
-
-		// Wait for all page requests complete
-		wg.Wait()
-
-		// nextLink is empty, so exit loop
-		if pagesResponse.ODataNextLink == "" {
-			break
-		}
+				fmt.Println()
+			}
+		}(page)
 
+		// ----- Write Page Content
+		//writeContent(page.Id+".html", content)
 	}
+	if err := pager.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	// Wait for all page requests complete
+	wg.Wait()
 }
@@ -17,10 +17,10 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
-	"net/url"
 	"os"
 	"strings"
 
@@ -67,54 +67,30 @@ func main() {
 
 	msGraphClient := msgraph4go.New(tokenFile, clientID, scopes)
 
-	// nextLink will contain the link to the next set of items, if any
-	var nextLink *url.URL
+	// get all of the contacts before updating any of them
+	var contacts []msgraph4go.Contact
+	err := msGraphClient.ListContactsPager(nil, user).All(context.Background(), &contacts)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	query := url.Values{}
+	for n, contact := range contacts {
+		fmt.Printf("Updating contact %d\n", n+1)
+		//fmt.Printf("\tID: %s\n", contact.ID)
+		fmt.Printf("\tSurname: %s GivenName: %s\n",
+			contact.Surname, contact.GivenName)
+		fmt.Printf("\tOld DisplayName: %s\n", contact.DisplayName)
 
-	n := 1
+		updateStr := fmt.Sprintf(`{ "displayName": "%s, %s" }`,
+			contact.Surname, contact.GivenName)
+		reader := strings.NewReader(updateStr)
 
-	// loop until no more results
-	for {
-		contacts, err := msGraphClient.ListContacts(query, user)
+		updatedContact, err := msGraphClient.UpdateContact(
+			nil, user, contact.ID, reader)
 		if err != nil {
 			log.Fatal(err)
 		}
-
-		for _, contact := range contacts.Value {
-			fmt.Printf("Updating contact %d\n", n)
-			//fmt.Printf("\tID: %s\n", contact.ID)
-			fmt.Printf("\tSurname: %s GivenName: %s\n",
-				contact.Surname, contact.GivenName)
-			fmt.Printf("\tOld DisplayName: %s\n", contact.DisplayName)
-			n++
-
-			updateStr := fmt.Sprintf(`{ "displayName": "%s, %s" }`,
-				contact.Surname, contact.GivenName)
-			reader := strings.NewReader(updateStr)
-
-			updatedContact, err := msGraphClient.UpdateContact(
-				nil, user, contact.ID, reader)
-			if err != nil {
-				log.Fatal(err)
-			}
-			fmt.Printf("\tNew DisplayName: %s\n", updatedContact.DisplayName)
-			fmt.Println()
-		}
-
-		// check if additional items
-		if contacts.ODataNextLink == "" {
-			// if ODataNextLink is empty, then no more items
-			break
-		} else {
-			// parse nextLink for query parameters
-			nextLink, err = url.Parse(contacts.ODataNextLink)
-			if err != nil {
-				log.Fatal(err)
-			}
-
-			// set query parameters for nextLink
-			query = nextLink.Query()
-		}
+		fmt.Printf("\tNew DisplayName: %s\n", updatedContact.DisplayName)
+		fmt.Println()
 	}
 }
@@ -17,54 +17,81 @@ limitations under the License.
 package msgraph4go
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 )
 
 // GetMyDrive returns the current user's OneDrive
 func (c *MSGraphClient) GetMyDrive(query url.Values) (drive Drive, err error) {
+	return c.GetMyDriveWithContext(context.Background(), query)
+}
+
+// GetMyDriveWithContext is like GetMyDrive, but uses ctx for the request.
+func (c *MSGraphClient) GetMyDriveWithContext(ctx context.Context, query url.Values) (drive Drive, err error) {
 	var body []byte
-	body, err = c.Get("/me/drive", query)
+	body, err = c.GetWithContext(ctx, "/me/drive", query)
 	if err != nil {
 		return drive, err
 	}
 
-	err = json.Unmarshal(body, &drive)
+	err = decodeBody(body, &drive)
 
 	return drive, err
 }
 
 // ListMyDrives retrieve a list of Drives available for the current user
 func (c *MSGraphClient) ListMyDrives(query url.Values) (drives DriveResponse, err error) {
+	return c.ListMyDrivesWithContext(context.Background(), query)
+}
+
+// ListMyDrivesWithContext is like ListMyDrives, but uses ctx for the request.
+func (c *MSGraphClient) ListMyDrivesWithContext(ctx context.Context, query url.Values) (drives DriveResponse, err error) {
 	var body []byte
-	body, err = c.Get("/me/drives", query)
+	body, err = c.GetWithContext(ctx, "/me/drives", query)
 	if err != nil {
 		return drives, err
 	}
 
-	err = json.Unmarshal(body, &drives)
+	err = decodeBody(body, &drives)
 
 	return drives, err
 }
 
+// ListMyDrivesPager returns a Pager for the Drive items of the collection returned by ListMyDrives.
+func (c *MSGraphClient) ListMyDrivesPager(query url.Values) *Pager {
+	return c.NewPager("/me/drives", query)
+}
+
 // ListRecentFiles is a collection of DriveItems that have been recently used by the signed in user.
 //
 // This collection includes items that are in the user's drive as well as
 // items they have access to from other drives.
 func (c *MSGraphClient) ListRecentFiles(query url.Values) (driveItems DriveItemResponse, err error) {
+	return c.ListRecentFilesWithContext(context.Background(), query)
+}
+
+// ListRecentFilesWithContext is like ListRecentFiles, but uses ctx for the request.
+func (c *MSGraphClient) ListRecentFilesWithContext(ctx context.Context, query url.Values) (driveItems DriveItemResponse, err error) {
 	var body []byte
-	body, err = c.Get("/drive/recent", query)
+	body, err = c.GetWithContext(ctx, "/drive/recent", query)
 	if err != nil {
 		return driveItems, err
 	}
 
-	err = json.Unmarshal(body, &driveItems)
+	err = decodeBody(body, &driveItems)
 
 	return driveItems, err
 }
 
+// ListRecentFilesPager returns a Pager for the DriveItem items of the collection returned by ListRecentFiles.
+func (c *MSGraphClient) ListRecentFilesPager(query url.Values) *Pager {
+	return c.NewPager("/drive/recent", query)
+}
+
 // ListDriveItemChildrenByID return a collection of DriveItems in the children relationship
 // of a DriveItem.
 //
@@ -74,34 +101,54 @@ func (c *MSGraphClient) ListRecentFiles(query url.Values) (driveItems DriveItemR
 //
 // DriveItems with a non-null folder or package facet can have one or more child DriveItems.
 func (c *MSGraphClient) ListDriveItemChildrenByID(driveID string, itemID string, query url.Values) (driveItems DriveItemResponse, err error) {
+	return c.ListDriveItemChildrenByIDWithContext(context.Background(), driveID, itemID, query)
+}
+
+// ListDriveItemChildrenByIDWithContext is like ListDriveItemChildrenByID, but uses ctx for the request.
+func (c *MSGraphClient) ListDriveItemChildrenByIDWithContext(ctx context.Context, driveID string, itemID string, query url.Values) (driveItems DriveItemResponse, err error) {
 	var body []byte
-	body, err = c.Get("/drives/"+driveID+"/items/"+itemID+"/children", query)
+	body, err = c.GetWithContext(ctx, "/drives/"+driveID+"/items/"+itemID+"/children", query)
 	if err != nil {
 		return driveItems, err
 	}
 
-	err = json.Unmarshal(body, &driveItems)
+	err = decodeBody(body, &driveItems)
 
 	return driveItems, err
 }
 
+// ListDriveItemChildrenByIDPager returns a Pager for the DriveItem items of the collection returned by ListDriveItemChildrenByID.
+func (c *MSGraphClient) ListDriveItemChildrenByIDPager(driveID string, itemID string, query url.Values) *Pager {
+	return c.NewPager("/drives/"+driveID+"/items/"+itemID+"/children", query)
+}
+
 // ListDriveItemPermissionsByID returns a collection of Permission objects.
 //
 // driveID should be a valid driveID or could be "me"
 //
 // itemID should be a valid itemID or could be "root"
 func (c *MSGraphClient) ListDriveItemPermissionsByID(driveID string, itemID string, query url.Values) (permissions PermissionsResponse, err error) {
+	return c.ListDriveItemPermissionsByIDWithContext(context.Background(), driveID, itemID, query)
+}
+
+// ListDriveItemPermissionsByIDWithContext is like ListDriveItemPermissionsByID, but uses ctx for the request.
+func (c *MSGraphClient) ListDriveItemPermissionsByIDWithContext(ctx context.Context, driveID string, itemID string, query url.Values) (permissions PermissionsResponse, err error) {
 	var body []byte
-	body, err = c.Get("/drives/"+driveID+"/items/"+itemID+"/permissions", query)
+	body, err = c.GetWithContext(ctx, "/drives/"+driveID+"/items/"+itemID+"/permissions", query)
 	if err != nil {
 		return permissions, err
 	}
 
-	err = json.Unmarshal(body, &permissions)
+	err = decodeBody(body, &permissions)
 
 	return permissions, err
 }
 
+// ListDriveItemPermissionsByIDPager returns a Pager for the Permission items of the collection returned by ListDriveItemPermissionsByID.
+func (c *MSGraphClient) ListDriveItemPermissionsByIDPager(driveID string, itemID string, query url.Values) *Pager {
+	return c.NewPager("/drives/"+driveID+"/items/"+itemID+"/permissions", query)
+}
+
 // GetDriveItemPermission returns a collection of Permission objects.
 //
 // driveID should be a valid driveID or could be "me"
@@ -110,14 +157,18 @@ func (c *MSGraphClient) ListDriveItemPermissionsByID(driveID string, itemID stri
 //
 // permID should be a valid permission ID
 func (c *MSGraphClient) GetDriveItemPermission(driveID string, itemID string, permID string, query url.Values) (permission Permission, err error) {
+	return c.GetDriveItemPermissionWithContext(context.Background(), driveID, itemID, permID, query)
+}
+
+// GetDriveItemPermissionWithContext is like GetDriveItemPermission, but uses ctx for the request.
+func (c *MSGraphClient) GetDriveItemPermissionWithContext(ctx context.Context, driveID string, itemID string, permID string, query url.Values) (permission Permission, err error) {
 	var body []byte
-	fmt.Println("/drives/" + driveID + "/items/" + itemID + "/permissions/" + permID)
-	body, err = c.Get("/drives/"+driveID+"/items/"+itemID+"/permissions/"+permID, query)
+	body, err = c.GetWithContext(ctx, "/drives/"+driveID+"/items/"+itemID+"/permissions/"+permID, query)
 	if err != nil {
 		return permission, err
 	}
 
-	err = json.Unmarshal(body, &permission)
+	err = decodeBody(body, &permission)
 
 	return permission, err
 }
@@ -133,17 +184,27 @@ func (c *MSGraphClient) GetDriveItemPermission(driveID string, itemID string, pe
 // Previous versions of a document may be retained for a finite period of
 // time depending on admin settings which may be unique per user or location.
 func (c *MSGraphClient) ListDriveItemVersions(driveID string, itemID string, query url.Values) (driveItemVersionResponse DriveItemVersionResponse, err error) {
+	return c.ListDriveItemVersionsWithContext(context.Background(), driveID, itemID, query)
+}
+
+// ListDriveItemVersionsWithContext is like ListDriveItemVersions, but uses ctx for the request.
+func (c *MSGraphClient) ListDriveItemVersionsWithContext(ctx context.Context, driveID string, itemID string, query url.Values) (driveItemVersionResponse DriveItemVersionResponse, err error) {
 	var body []byte
-	body, err = c.Get("/drives/"+driveID+"/items/"+itemID+"/versions", query)
+	body, err = c.GetWithContext(ctx, "/drives/"+driveID+"/items/"+itemID+"/versions", query)
 	if err != nil {
 		return driveItemVersionResponse, err
 	}
 
-	err = json.Unmarshal(body, &driveItemVersionResponse)
+	err = decodeBody(body, &driveItemVersionResponse)
 
 	return driveItemVersionResponse, err
 }
 
+// ListDriveItemVersionsPager returns a Pager for the DriveItemVersion items of the collection returned by ListDriveItemVersions.
+func (c *MSGraphClient) ListDriveItemVersionsPager(driveID string, itemID string, query url.Values) *Pager {
+	return c.NewPager("/drives/"+driveID+"/items/"+itemID+"/versions", query)
+}
+
 // ListDriveItemChildrenByPath return a collection of DriveItems in the children relationship
 // of a DriveItem.
 //
@@ -153,38 +214,46 @@ func (c *MSGraphClient) ListDriveItemVersions(driveID string, itemID string, que
 //
 // DriveItems with a non-null folder or package facet can have one or more child DriveItems.
 func (c *MSGraphClient) ListDriveItemChildrenByPath(driveID string, path string, query url.Values) (driveItems DriveItemResponse, err error) {
-	var body []byte
-	var url string
+	return c.ListDriveItemChildrenByPathWithContext(context.Background(), driveID, path, query)
+}
 
-	if path == "" || path == "/" {
-		url = "/drives/" + driveID + "/items/root/children"
-	} else {
-		url = "/drives/" + driveID + "/root:/" + path + ":/children"
-	}
+// ListDriveItemChildrenByPathWithContext is like ListDriveItemChildrenByPath, but uses ctx for the request.
+func (c *MSGraphClient) ListDriveItemChildrenByPathWithContext(ctx context.Context, driveID string, path string, query url.Values) (driveItems DriveItemResponse, err error) {
+	var body []byte
 
-	body, err = c.Get(url, query)
+	body, err = c.GetWithContext(ctx, childrenByPathURL(driveID, path), query)
 	if err != nil {
 		return driveItems, err
 	}
 
-	err = json.Unmarshal(body, &driveItems)
+	err = decodeBody(body, &driveItems)
 
 	return driveItems, err
 }
 
+// ListDriveItemChildrenByPathPager returns a Pager for the DriveItem items of the collection returned by ListDriveItemChildrenByPath.
+func (c *MSGraphClient) ListDriveItemChildrenByPathPager(driveID string, path string, query url.Values) *Pager {
+	return c.NewPager(childrenByPathURL(driveID, path), query)
+}
+
 // GetDriveItemByID return a DriveItem
 //
 // driveID should be a valid driveID or could be "me"
 //
 // itemID should be a valid itemID or could be "root"
 func (c *MSGraphClient) GetDriveItemByID(driveID string, itemID string, query url.Values) (driveItem DriveItem, err error) {
+	return c.GetDriveItemByIDWithContext(context.Background(), driveID, itemID, query)
+}
+
+// GetDriveItemByIDWithContext is like GetDriveItemByID, but uses ctx for the request.
+func (c *MSGraphClient) GetDriveItemByIDWithContext(ctx context.Context, driveID string, itemID string, query url.Values) (driveItem DriveItem, err error) {
 	var body []byte
-	body, err = c.Get("/drives/"+driveID+"/items/"+itemID, query)
+	body, err = c.GetWithContext(ctx, "/drives/"+driveID+"/items/"+itemID, query)
 	if err != nil {
 		return driveItem, err
 	}
 
-	err = json.Unmarshal(body, &driveItem)
+	err = decodeBody(body, &driveItem)
 
 	return driveItem, err
 }
@@ -195,6 +264,11 @@ func (c *MSGraphClient) GetDriveItemByID(driveID string, itemID string, query ur
 //
 // itemID should be a valid itemID or could be "root"
 func (c *MSGraphClient) GetDriveItemByPath(driveID string, path string, query url.Values) (driveItem DriveItem, err error) {
+	return c.GetDriveItemByPathWithContext(context.Background(), driveID, path, query)
+}
+
+// GetDriveItemByPathWithContext is like GetDriveItemByPath, but uses ctx for the request.
+func (c *MSGraphClient) GetDriveItemByPathWithContext(ctx context.Context, driveID string, path string, query url.Values) (driveItem DriveItem, err error) {
 	var body []byte
 	var url string
 
@@ -204,12 +278,12 @@ func (c *MSGraphClient) GetDriveItemByPath(driveID string, path string, query ur
 		url = "/drives/" + driveID + "/root:/" + path + ":"
 	}
 
-	body, err = c.Get(url, query)
+	body, err = c.GetWithContext(ctx, url, query)
 	if err != nil {
 		return driveItem, err
 	}
 
-	err = json.Unmarshal(body, &driveItem)
+	err = decodeBody(body, &driveItem)
 
 	return driveItem, err
 }
@@ -218,17 +292,118 @@ func (c *MSGraphClient) GetDriveItemByPath(driveID string, path string, query ur
 // contents of a new file or update the contents of an existing file in a
 // single API call. This method only supports files up to 4MB in size.
 func (c *MSGraphClient) UploadNewFile(query url.Values, driveID string, parentID string, fileName string, data io.Reader) (driveItem DriveItem, err error) {
+	return c.UploadNewFileWithContext(context.Background(), query, driveID, parentID, fileName, data)
+}
+
+// UploadNewFileWithContext is like UploadNewFile, but uses ctx for the request.
+func (c *MSGraphClient) UploadNewFileWithContext(ctx context.Context, query url.Values, driveID string, parentID string, fileName string, data io.Reader) (driveItem DriveItem, err error) {
 	var body []byte
 	var url string
 
 	url = "/drives/" + driveID + "/items/" + parentID + ":/" + fileName + ":/content"
 
-	body, err = c.Put(url, query, data)
+	body, err = c.PutWithContext(ctx, url, query, data)
+	if err != nil {
+		return driveItem, err
+	}
+
+	err = decodeBody(body, &driveItem)
+
+	return driveItem, err
+}
+
+// UpdateDriveItem updates the metadata of the DriveItem with itemID, such
+// as its name or parentReference to move it.
+//
+// Use IfMatch with the ETag of the DriveItem to update it only if it hasn't changed.
+func (c *MSGraphClient) UpdateDriveItem(query url.Values, driveID string, itemID string, data io.Reader) (driveItem DriveItem, err error) {
+	return c.UpdateDriveItemWithContext(context.Background(), query, driveID, itemID, data)
+}
+
+// UpdateDriveItemWithContext is like UpdateDriveItem, but uses ctx for the request.
+func (c *MSGraphClient) UpdateDriveItemWithContext(ctx context.Context, query url.Values, driveID string, itemID string, data io.Reader) (driveItem DriveItem, err error) {
+	body, err := c.PatchWithContext(ctx, "/drives/"+driveID+"/items/"+itemID, query, data)
 	if err != nil {
 		return driveItem, err
 	}
 
-	err = json.Unmarshal(body, &driveItem)
+	err = decodeBody(body, &driveItem)
 
 	return driveItem, err
 }
+
+// UpdateFileContent replaces the contents of the existing file with itemID.
+// This method only supports files up to 4MB in size.
+//
+// Use IfMatch with the ETag of the DriveItem to replace the contents only
+// if the file hasn't changed.
+func (c *MSGraphClient) UpdateFileContent(query url.Values, driveID string, itemID string, data io.Reader) (driveItem DriveItem, err error) {
+	return c.UpdateFileContentWithContext(context.Background(), query, driveID, itemID, data)
+}
+
+// UpdateFileContentWithContext is like UpdateFileContent, but uses ctx for the request.
+func (c *MSGraphClient) UpdateFileContentWithContext(ctx context.Context, query url.Values, driveID string, itemID string, data io.Reader) (driveItem DriveItem, err error) {
+	body, err := c.PutWithContext(ctx, "/drives/"+driveID+"/items/"+itemID+"/content", query, data)
+	if err != nil {
+		return driveItem, err
+	}
+
+	err = decodeBody(body, &driveItem)
+
+	return driveItem, err
+}
+
+// DeleteDriveItem deletes the DriveItem with itemID, moving it to the recycle bin.
+func (c *MSGraphClient) DeleteDriveItem(driveID string, itemID string) (err error) {
+	return c.DeleteDriveItemWithContext(context.Background(), driveID, itemID)
+}
+
+// DeleteDriveItemWithContext is like DeleteDriveItem, but uses ctx for the request.
+func (c *MSGraphClient) DeleteDriveItemWithContext(ctx context.Context, driveID string, itemID string) (err error) {
+	return c.DeleteWithContext(ctx, "/drives/"+driveID+"/items/"+itemID, nil)
+}
+
+// CopyDriveItem copies the DriveItem with itemID to the folder identified
+// by parentReference. If name is not empty, it is used as the name of the copy.
+//
+// The copy is done asynchronously, so the URL of a monitor that reports
+// the progress of the copy is returned.
+func (c *MSGraphClient) CopyDriveItem(driveID string, itemID string, parentReference ItemReference, name string) (monitorURL string, err error) {
+	return c.CopyDriveItemWithContext(context.Background(), driveID, itemID, parentReference, name)
+}
+
+// CopyDriveItemWithContext is like CopyDriveItem, but uses ctx for the request.
+func (c *MSGraphClient) CopyDriveItemWithContext(ctx context.Context, driveID string, itemID string, parentReference ItemReference, name string) (monitorURL string, err error) {
+	request := struct {
+		ParentReference ItemReference `json:"parentReference"`
+		Name            string        `json:"name,omitempty"`
+	}{parentReference, name}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.DoWithContext(ctx, http.MethodPost, "/drives/"+driveID+"/items/"+itemID+"/copy", nil,
+		http.Header{"Content-Type": {"application/json"}}, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Location, nil
+}
+
+// childrenByPathURL returns the URL of the children of the DriveItem at path.
+func childrenByPathURL(driveID string, path string) string {
+	if path == "" || path == "/" {
+		return "/drives/" + driveID + "/items/root/children"
+	}
+
+	return "/drives/" + driveID + "/root:/" + path + ":/children"
+}
+
+// DriveItemDelta returns a Delta that tracks changes to the DriveItems of driveID.
+// Change.Item is a *DriveItem.
+func (c *MSGraphClient) DriveItemDelta(driveID string, query url.Values, store CheckpointStore) *Delta {
+	return c.NewDelta("/drives/"+driveID+"/root/delta", query, store, func() interface{} { return &DriveItem{} })
+}
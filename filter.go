@@ -0,0 +1,589 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Expr is a $filter expression that can be rendered in the syntax of the
+// Graph API, using String, or evaluated locally against an item, using Match.
+//
+// Create an Expr using functions such as Eq, And, and Any, e.g.
+//
+//	expr := msgraph4go.And(
+//		msgraph4go.Eq("isRead", false),
+//		msgraph4go.Any("toRecipients", "r", msgraph4go.Eq("r/emailAddress/address", address)),
+//	)
+//
+// Match evaluates the expression against a typed struct, such as a
+// Message, using the names in its json tags, or against a map decoded from JSON.
+// String comparisons ignore case, like most properties of the Graph API.
+type Expr interface {
+	// String returns the expression in the syntax of the Graph API.
+	String() string
+
+	// Match returns true if item, such as a *Message, matches the expression.
+	Match(item interface{}) (bool, error)
+
+	// eval evaluates the expression with the lambda variables in env.
+	eval(root reflect.Value, env map[string]reflect.Value) (interface{}, error)
+}
+
+// compareExpr compares a property with a value.
+type compareExpr struct {
+	op       string
+	property string
+	value    interface{}
+}
+
+// Eq returns an expression that is true if property equals value.
+func Eq(property string, value interface{}) Expr {
+	return &compareExpr{op: "eq", property: property, value: value}
+}
+
+// Ne returns an expression that is true if property doesn't equal value.
+func Ne(property string, value interface{}) Expr {
+	return &compareExpr{op: "ne", property: property, value: value}
+}
+
+// Gt returns an expression that is true if property is greater than value.
+func Gt(property string, value interface{}) Expr {
+	return &compareExpr{op: "gt", property: property, value: value}
+}
+
+// Ge returns an expression that is true if property is greater than or equal to value.
+func Ge(property string, value interface{}) Expr {
+	return &compareExpr{op: "ge", property: property, value: value}
+}
+
+// Lt returns an expression that is true if property is less than value.
+func Lt(property string, value interface{}) Expr {
+	return &compareExpr{op: "lt", property: property, value: value}
+}
+
+// Le returns an expression that is true if property is less than or equal to value.
+func Le(property string, value interface{}) Expr {
+	return &compareExpr{op: "le", property: property, value: value}
+}
+
+func (e *compareExpr) String() string {
+	return e.property + " " + e.op + " " + Literal(e.value)
+}
+
+func (e *compareExpr) Match(item interface{}) (bool, error) {
+	return match(e, item)
+}
+
+func (e *compareExpr) eval(root reflect.Value, env map[string]reflect.Value) (interface{}, error) {
+	left, err := resolveProperty(root, env, e.property)
+	if err != nil {
+		return nil, err
+	}
+
+	cmp, ok := compareValues(left, e.value)
+	if !ok {
+		// values that can't be compared are only not equal
+		return e.op == "ne", nil
+	}
+
+	switch e.op {
+	case "eq":
+		return cmp == 0, nil
+	case "ne":
+		return cmp != 0, nil
+	case "gt":
+		return cmp > 0, nil
+	case "ge":
+		return cmp >= 0, nil
+	case "lt":
+		return cmp < 0, nil
+	case "le":
+		return cmp <= 0, nil
+	}
+
+	return nil, fmt.Errorf("msgraph4go: unknown filter operator %q", e.op)
+}
+
+// logicalExpr combines expressions using and or or.
+type logicalExpr struct {
+	op    string
+	exprs []Expr
+}
+
+// And returns an expression that is true if all of exprs are true.
+func And(exprs ...Expr) Expr {
+	return &logicalExpr{op: "and", exprs: exprs}
+}
+
+// Or returns an expression that is true if any of exprs are true.
+func Or(exprs ...Expr) Expr {
+	return &logicalExpr{op: "or", exprs: exprs}
+}
+
+func (e *logicalExpr) String() string {
+	parts := make([]string, len(e.exprs))
+	for n, expr := range e.exprs {
+		parts[n] = expr.String()
+		if _, ok := expr.(*logicalExpr); ok {
+			parts[n] = "(" + parts[n] + ")"
+		}
+	}
+
+	return strings.Join(parts, " "+e.op+" ")
+}
+
+func (e *logicalExpr) Match(item interface{}) (bool, error) {
+	return match(e, item)
+}
+
+func (e *logicalExpr) eval(root reflect.Value, env map[string]reflect.Value) (interface{}, error) {
+	for _, expr := range e.exprs {
+		result, err := evalBool(expr, root, env)
+		if err != nil {
+			return nil, err
+		}
+
+		// stop at the first false for and, or the first true for or
+		if result == (e.op == "or") {
+			return result, nil
+		}
+	}
+
+	return e.op == "and", nil
+}
+
+// notExpr negates an expression.
+type notExpr struct {
+	expr Expr
+}
+
+// Not returns an expression that is true if expr is false.
+func Not(expr Expr) Expr {
+	return &notExpr{expr: expr}
+}
+
+func (e *notExpr) String() string {
+	return "not(" + e.expr.String() + ")"
+}
+
+func (e *notExpr) Match(item interface{}) (bool, error) {
+	return match(e, item)
+}
+
+func (e *notExpr) eval(root reflect.Value, env map[string]reflect.Value) (interface{}, error) {
+	result, err := evalBool(e.expr, root, env)
+	return !result, err
+}
+
+// stringFuncExpr calls a string function such as startswith.
+type stringFuncExpr struct {
+	name     string
+	property string
+	value    string
+}
+
+// StartsWith returns an expression that is true if property starts with value.
+func StartsWith(property string, value string) Expr {
+	return &stringFuncExpr{name: "startswith", property: property, value: value}
+}
+
+// EndsWith returns an expression that is true if property ends with value.
+func EndsWith(property string, value string) Expr {
+	return &stringFuncExpr{name: "endswith", property: property, value: value}
+}
+
+// Contains returns an expression that is true if property contains value.
+func Contains(property string, value string) Expr {
+	return &stringFuncExpr{name: "contains", property: property, value: value}
+}
+
+func (e *stringFuncExpr) String() string {
+	return e.name + "(" + e.property + "," + Literal(e.value) + ")"
+}
+
+func (e *stringFuncExpr) Match(item interface{}) (bool, error) {
+	return match(e, item)
+}
+
+func (e *stringFuncExpr) eval(root reflect.Value, env map[string]reflect.Value) (interface{}, error) {
+	v, err := resolveProperty(root, env, e.property)
+	if err != nil {
+		return nil, err
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return false, nil
+	}
+
+	s, value := strings.ToLower(s), strings.ToLower(e.value)
+	switch e.name {
+	case "startswith":
+		return strings.HasPrefix(s, value), nil
+	case "endswith":
+		return strings.HasSuffix(s, value), nil
+	default:
+		return strings.Contains(s, value), nil
+	}
+}
+
+// lambdaExpr applies an expression to the items of a collection.
+type lambdaExpr struct {
+	op         string
+	collection string
+	variable   string
+	expr       Expr
+}
+
+// Any returns an expression that is true if expr is true for any item of
+// the collection property, with variable referring to the item, e.g.
+// Any("toRecipients", "r", Eq("r/emailAddress/address", address)).
+// If expr is nil, the expression is true if the collection is not empty.
+func Any(collection string, variable string, expr Expr) Expr {
+	return &lambdaExpr{op: "any", collection: collection, variable: variable, expr: expr}
+}
+
+// All returns an expression that is true if expr is true for all items of
+// the collection property, with variable referring to the item.
+func All(collection string, variable string, expr Expr) Expr {
+	return &lambdaExpr{op: "all", collection: collection, variable: variable, expr: expr}
+}
+
+func (e *lambdaExpr) String() string {
+	if e.expr == nil {
+		return e.collection + "/" + e.op + "()"
+	}
+
+	return e.collection + "/" + e.op + "(" + e.variable + ": " + e.expr.String() + ")"
+}
+
+func (e *lambdaExpr) Match(item interface{}) (bool, error) {
+	return match(e, item)
+}
+
+func (e *lambdaExpr) eval(root reflect.Value, env map[string]reflect.Value) (interface{}, error) {
+	v, err := resolveValue(root, env, e.collection)
+	if err != nil {
+		return nil, err
+	}
+
+	v = indirect(v)
+	if !v.IsValid() {
+		return e.op == "all", nil
+	}
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("msgraph4go: %s is not a collection", e.collection)
+	}
+
+	if e.expr == nil {
+		return e.op == "all" || v.Len() > 0, nil
+	}
+
+	// the lambda variable refers to each item in turn
+	inner := make(map[string]reflect.Value, len(env)+1)
+	for k, val := range env {
+		inner[k] = val
+	}
+
+	for n := 0; n < v.Len(); n++ {
+		inner[e.variable] = v.Index(n)
+
+		result, err := evalBool(e.expr, root, inner)
+		if err != nil {
+			return nil, err
+		}
+
+		if result == (e.op == "any") {
+			return result, nil
+		}
+	}
+
+	return e.op == "all", nil
+}
+
+// match evaluates expr for item.
+func match(expr Expr, item interface{}) (bool, error) {
+	return evalBool(expr, reflect.ValueOf(item), nil)
+}
+
+// evalBool evaluates expr, which must result in a bool.
+func evalBool(expr Expr, root reflect.Value, env map[string]reflect.Value) (bool, error) {
+	result, err := expr.eval(root, env)
+	if err != nil {
+		return false, err
+	}
+
+	b, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("msgraph4go: %s is not a boolean expression", expr)
+	}
+
+	return b, nil
+}
+
+// indirect follows pointers and interfaces, returning an invalid Value for nil.
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+
+	return v
+}
+
+// resolveValue returns the value of property, a path such as
+// "parentNotebook/displayName", starting from a lambda variable or root.
+func resolveValue(root reflect.Value, env map[string]reflect.Value, property string) (reflect.Value, error) {
+	names := strings.Split(property, "/")
+
+	v := root
+	if variable, ok := env[names[0]]; ok {
+		v = variable
+		names = names[1:]
+	}
+
+	for _, name := range names {
+		v = indirect(v)
+		if !v.IsValid() {
+			return v, nil
+		}
+
+		switch v.Kind() {
+		case reflect.Struct:
+			v = fieldByJSONName(v, name)
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, fmt.Errorf("msgraph4go: can't resolve %s", property)
+			}
+			v = mapIndexByName(v, name)
+		default:
+			return reflect.Value{}, fmt.Errorf("msgraph4go: can't resolve %s", property)
+		}
+	}
+
+	return v, nil
+}
+
+// resolveProperty returns the value of property as a string, float64, bool, or nil.
+func resolveProperty(root reflect.Value, env map[string]reflect.Value, property string) (interface{}, error) {
+	v, err := resolveValue(root, env, property)
+	if err != nil {
+		return nil, err
+	}
+
+	v = indirect(v)
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Map:
+		// a nil collection is null, as it is once marshaled to JSON
+		if v.IsNil() {
+			return nil, nil
+		}
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return v.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	}
+
+	switch t := v.Interface().(type) {
+	case time.Time:
+		return t, nil
+	case Timestamp:
+		return time.Time(t), nil
+	}
+
+	return v.Interface(), nil
+}
+
+// fieldByJSONName returns the field of the struct v with the json name,
+// ignoring case, including the fields of embedded structs.
+func fieldByJSONName(v reflect.Value, name string) reflect.Value {
+	t := v.Type()
+
+	for n := 0; n < t.NumField(); n++ {
+		field := t.Field(n)
+
+		tag := strings.Split(field.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+
+		if field.Anonymous && tag == "" {
+			embedded := indirect(v.Field(n))
+			if embedded.IsValid() && embedded.Kind() == reflect.Struct {
+				if f := fieldByJSONName(embedded, name); f.IsValid() {
+					return f
+				}
+			}
+			continue
+		}
+
+		if tag == "" {
+			tag = field.Name
+		}
+		if strings.EqualFold(tag, name) {
+			return v.Field(n)
+		}
+	}
+
+	return reflect.Value{}
+}
+
+// mapIndexByName returns the element of the map v with the key name,
+// ignoring case like fieldByJSONName, so that a map and a struct match alike.
+func mapIndexByName(v reflect.Value, name string) reflect.Value {
+	key := reflect.ValueOf(name).Convert(v.Type().Key())
+	if elem := v.MapIndex(key); elem.IsValid() {
+		return elem
+	}
+
+	iter := v.MapRange()
+	for iter.Next() {
+		if strings.EqualFold(iter.Key().String(), name) {
+			return iter.Value()
+		}
+	}
+
+	return reflect.Value{}
+}
+
+// compareValues compares the value of a property with a filter value,
+// returning -1, 0, or 1, and false if the values can't be compared.
+func compareValues(left interface{}, right interface{}) (int, bool) {
+	if r, ok := right.(*Timestamp); ok && r == nil {
+		right = nil
+	}
+
+	if left == nil || right == nil {
+		if left == nil && right == nil {
+			return 0, true
+		}
+		return 0, false
+	}
+
+	switch r := right.(type) {
+	case Timestamp:
+		return compareValues(left, time.Time(r))
+	case *Timestamp:
+		return compareValues(left, r.Time())
+	case time.Time:
+		l, ok := left.(time.Time)
+		if !ok {
+			s, isString := left.(string)
+			if !isString {
+				return 0, false
+			}
+			var err error
+			l, err = ParseTime(s)
+			if err != nil {
+				return 0, false
+			}
+		}
+		switch {
+		case l.Before(r):
+			return -1, true
+		case l.After(r):
+			return 1, true
+		}
+		return 0, true
+
+	case bool:
+		l, ok := left.(bool)
+		if !ok {
+			return 0, false
+		}
+		if l == r {
+			return 0, true
+		}
+		return 1, true
+
+	case string:
+		// a date and time property may be compared with a string in the Graph API format
+		if _, ok := left.(time.Time); ok {
+			t, err := ParseTime(r)
+			if err != nil {
+				return 0, false
+			}
+			return compareValues(left, t)
+		}
+
+		l, ok := left.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(strings.ToLower(l), strings.ToLower(r)), true
+	}
+
+	// compare other strings, such as a fmt.Stringer, as a string
+	if s, ok := right.(fmt.Stringer); ok {
+		return compareValues(left, s.String())
+	}
+	if rv := reflect.ValueOf(right); rv.Kind() == reflect.String {
+		return compareValues(left, rv.String())
+	}
+
+	// compare numbers as float64
+	l, lok := left.(float64)
+	r, rok := toFloat(right)
+	if !lok || !rok {
+		return 0, false
+	}
+
+	switch {
+	case l < r:
+		return -1, true
+	case l > r:
+		return 1, true
+	}
+	return 0, true
+}
+
+// toFloat returns a number as a float64.
+func toFloat(v interface{}) (float64, bool) {
+	if n, ok := v.(json.Number); ok {
+		f, err := n.Float64()
+		return f, err == nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,171 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// testMessage returns a message, and the same message decoded from JSON as a map.
+func testMessage(t *testing.T) (*Message, map[string]interface{}) {
+	t.Helper()
+
+	message := &Message{
+		Subject:          "Quarterly Report",
+		Importance:       "high",
+		IsRead:           false,
+		ReceivedDateTime: NewTimestamp(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)),
+		From:             Recipient{EmailAddress: &EmailAddress{Address: "megan@contoso.com"}},
+		ToRecipients: []Recipient{
+			{EmailAddress: &EmailAddress{Address: "adele@contoso.com"}},
+			{EmailAddress: &EmailAddress{Address: "alex@contoso.com"}},
+		},
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+
+	return message, m
+}
+
+func TestExprMatch(t *testing.T) {
+	received := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		expr Expr
+		want bool
+	}{
+		{"eq string ignores case", Eq("subject", "quarterly report"), true},
+		{"ne string", Ne("subject", "Other"), true},
+		{"eq enum", Eq("importance", "High"), true},
+		{"eq bool", Eq("isRead", false), true},
+		{"ne bool", Ne("isRead", false), false},
+		{"gt string", Gt("subject", "P"), true},
+		{"lt string", Lt("subject", "P"), false},
+
+		{"eq time", Eq("receivedDateTime", received), true},
+		{"gt time", Gt("receivedDateTime", received.Add(-time.Second)), true},
+		{"ge time", Ge("receivedDateTime", received), true},
+		{"lt time", Lt("receivedDateTime", received), false},
+		{"le time", Le("receivedDateTime", received), true},
+		{"gt Timestamp", Gt("receivedDateTime", *NewTimestamp(received.Add(-time.Hour))), true},
+		{"lt *Timestamp", Lt("receivedDateTime", NewTimestamp(received.Add(time.Hour))), true},
+		{"eq time string", Eq("receivedDateTime", "2021-03-01T12:00:00Z"), true},
+		{"gt time string", Gt("receivedDateTime", "2021-03-01T13:00:00Z"), false},
+
+		{"nested property", Eq("from/emailAddress/address", "MEGAN@contoso.com"), true},
+		{"missing property eq nil", Eq("categories", nil), true},
+		{"missing property ne nil", Ne("categories", nil), false},
+		{"nil property eq nil Timestamp", Eq("sentDateTime", (*Timestamp)(nil)), true},
+		{"nil property gt time", Gt("sentDateTime", received), false},
+		{"nil property ne time", Ne("sentDateTime", received), true},
+		{"property eq nil", Eq("subject", nil), false},
+
+		{"type mismatch eq", Eq("subject", 5), false},
+		{"type mismatch ne", Ne("subject", 5), true},
+		{"type mismatch gt", Gt("isRead", "true"), false},
+		{"invalid time string", Eq("receivedDateTime", "yesterday"), false},
+
+		{"and", And(Eq("isRead", false), Eq("importance", "high")), true},
+		{"and false", And(Eq("isRead", true), Eq("importance", "high")), false},
+		{"or", Or(Eq("isRead", true), Eq("importance", "high")), true},
+		{"not", Not(Eq("isRead", true)), true},
+
+		{"startswith", StartsWith("subject", "quarterly"), true},
+		{"endswith", EndsWith("subject", "REPORT"), true},
+		{"contains", Contains("subject", "ly rep"), true},
+		{"contains non-string", Contains("isRead", "f"), false},
+
+		{"any", Any("toRecipients", "r", Eq("r/emailAddress/address", "alex@contoso.com")), true},
+		{"any none", Any("toRecipients", "r", Eq("r/emailAddress/address", "nestor@contoso.com")), false},
+		{"any empty", Any("toRecipients", "", nil), true},
+		{"all", All("toRecipients", "r", EndsWith("r/emailAddress/address", "@contoso.com")), true},
+		{"all missing", All("ccRecipients", "r", Eq("r/emailAddress/address", "x")), true},
+		{"any missing", Any("ccRecipients", "r", Eq("r/emailAddress/address", "x")), false},
+	}
+
+	// a date and time in a map is a string, which is compared as a string
+	typedOnly := map[string]bool{"eq time string": true, "gt time string": true}
+
+	message, m := testMessage(t)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.expr.Match(message)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("%s Match(*Message) = %v, want %v", tt.expr, got, tt.want)
+			}
+
+			if typedOnly[tt.name] {
+				return
+			}
+
+			got, err = tt.expr.Match(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("%s Match(map) = %v, want %v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExprErrors(t *testing.T) {
+	message, _ := testMessage(t)
+
+	for _, expr := range []Expr{
+		Any("subject", "s", Eq("s", "x")),
+		And(Eq("isRead", false), &compareExpr{op: "xx", property: "subject", value: "x"}),
+		Eq("subject/length", 5),
+	} {
+		if _, err := expr.Match(message); err == nil {
+			t.Errorf("%s Match = nil error, want error", expr)
+		}
+	}
+}
+
+func TestExprString(t *testing.T) {
+	tests := []struct {
+		expr Expr
+		want string
+	}{
+		{Eq("isRead", false), "isRead eq false"},
+		{Gt("receivedDateTime", time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)), "receivedDateTime gt 2021-03-01T12:00:00Z"},
+		{And(Eq("subject", "it's"), Not(StartsWith("subject", "RE:"))), "subject eq 'it''s' and not(startswith(subject,'RE:'))"},
+		{Any("toRecipients", "r", Eq("r/emailAddress/address", "a@b.com")), "toRecipients/any(r: r/emailAddress/address eq 'a@b.com')"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.expr.String(); got != tt.want {
+			t.Errorf("String = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,7 @@ module github.com/bnixon67/msgraph4go
 go 1.16
 
 require (
+	golang.org/x/crypto v0.0.0-20210322153248-0c34fe9e7dc2
 	golang.org/x/net v0.0.0-20210326220855-61e056675ecf
 	golang.org/x/oauth2 v0.0.0-20210323180902-22b0adad7558
 )
@@ -0,0 +1,144 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graphtest
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Fault is an error response returned by the Server instead of handling a request.
+type Fault struct {
+	// Method is the method of the requests that fail, or all methods if empty.
+	Method string
+
+	// Path is the prefix of the paths of the requests that fail, without
+	// the API version, e.g. "/me/messages", or all requests if empty.
+	Path string
+
+	// StatusCode is the status code of the response, e.g. 503.
+	StatusCode int
+
+	// Code and Message are the code and message of the error response.
+	// If empty, they are based on the StatusCode.
+	Code    string
+	Message string
+
+	// RetryAfter, if set, is returned in the Retry-After header, rounded up to seconds.
+	RetryAfter time.Duration
+
+	// Times is the number of requests that fail, after which the fault is
+	// removed. A value of 0 means every request fails until ClearFaults is called.
+	Times int
+}
+
+// InjectFault adds a fault, so matching requests fail with the error response of fault.
+//
+// Faults are matched in the order they were added.
+func (s *Server) InjectFault(fault Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &fault)
+}
+
+// Throttle makes the next times requests to paths starting with path fail
+// with 429 Too Many Requests, with a Retry-After header of retryAfter.
+func (s *Server) Throttle(path string, times int, retryAfter time.Duration) {
+	s.InjectFault(Fault{
+		Path:       path,
+		StatusCode: http.StatusTooManyRequests,
+		RetryAfter: retryAfter,
+		Times:      times,
+	})
+}
+
+// ServerError makes the next times requests to paths starting with path
+// fail with the 5xx statusCode, e.g. 503 Service Unavailable.
+func (s *Server) ServerError(path string, statusCode int, times int) {
+	s.InjectFault(Fault{
+		Path:       path,
+		StatusCode: statusCode,
+		Times:      times,
+	})
+}
+
+// ClearFaults removes all faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// matchFault returns the first fault matching the request, if any, removing it once used up.
+func (s *Server) matchFault(method string, path string) *Fault {
+	for n, fault := range s.faults {
+		if fault.Method != "" && !strings.EqualFold(fault.Method, method) {
+			continue
+		}
+		if !strings.HasPrefix(path, fault.Path) {
+			continue
+		}
+
+		if fault.Times > 0 {
+			fault.Times--
+			if fault.Times == 0 {
+				s.faults = append(s.faults[:n:n], s.faults[n+1:]...)
+			}
+		}
+
+		matched := *fault
+		return &matched
+	}
+
+	return nil
+}
+
+// faultCodes are the error codes returned for the status codes of faults.
+var faultCodes = map[int]string{
+	http.StatusTooManyRequests:     "TooManyRequests",
+	http.StatusInternalServerError: "generalException",
+	http.StatusBadGateway:          "BadGateway",
+	http.StatusServiceUnavailable:  "serviceNotAvailable",
+	http.StatusGatewayTimeout:      "GatewayTimeout",
+}
+
+// write writes the error response of the fault.
+func (f *Fault) write(w http.ResponseWriter, r *http.Request) {
+	code := f.Code
+	if code == "" {
+		code = faultCodes[f.StatusCode]
+	}
+	if code == "" {
+		code = strings.ReplaceAll(http.StatusText(f.StatusCode), " ", "")
+	}
+
+	message := f.Message
+	if message == "" {
+		message = http.StatusText(f.StatusCode) + "."
+	}
+
+	if f.RetryAfter > 0 || f.StatusCode == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(f.RetryAfter.Seconds()))))
+	}
+
+	writeError(w, r, &graphError{f.StatusCode, code, message})
+}
@@ -0,0 +1,386 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graphtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF to get the size of a photo
+	_ "image/jpeg" // register JPEG to get the size of a photo
+	_ "image/png"  // register PNG to get the size of a photo
+	"net/http"
+
+	"github.com/bnixon67/msgraph4go"
+)
+
+// The Add methods add resources for the signed in user, and panic if the
+// resource can't be added, e.g. because the parent doesn't exist. Each
+// returns the resource as returned by the server, including its ID.
+
+// Me returns the signed in user.
+func (s *Server) Me() msgraph4go.User {
+	var user msgraph4go.User
+	s.mustGet("/me", &user)
+
+	return user
+}
+
+// AddUser adds a user, who has a drive, the well-known mail folders, and a default calendar.
+func (s *Server) AddUser(user msgraph4go.User) msgraph4go.User {
+	var added msgraph4go.User
+	s.mustGet(s.mustSeed("/users", user), &added)
+
+	return added
+}
+
+// AddDriveItem adds item as a child of the drive item parentID in the drive
+// of the signed in user. Use "" or "root" for the root folder.
+//
+// The item is a file with content, unless content is nil, in which case
+// it is a folder if item.Folder is nil.
+func (s *Server) AddDriveItem(parentID string, item msgraph4go.DriveItem, content []byte) msgraph4go.DriveItem {
+	if parentID == "" {
+		parentID = "root"
+	}
+
+	data, err := toMap(item)
+	if err != nil {
+		panic(err)
+	}
+
+	if content != nil {
+		data["file"] = map[string]interface{}{"mimeType": mimeType(item, content)}
+		data["size"] = float64(len(content))
+	} else if data["file"] == nil && data["folder"] == nil {
+		data["folder"] = map[string]interface{}{}
+	}
+
+	path := s.mustSeed("/me/drive/items/"+parentID+"/children", data)
+
+	if content != nil {
+		s.mu.Lock()
+		o := s.objects[path]
+		o.content = append([]byte(nil), content...)
+		o.contentType = mimeType(item, content)
+		s.mu.Unlock()
+	}
+
+	var added msgraph4go.DriveItem
+	s.mustGet(path, &added)
+
+	return added
+}
+
+// AddFolder adds a folder named name to the drive item parentID of the drive of the signed in user.
+func (s *Server) AddFolder(parentID string, name string) msgraph4go.DriveItem {
+	return s.AddDriveItem(parentID, msgraph4go.DriveItem{Name: name}, nil)
+}
+
+// AddFile adds a file named name to the drive item parentID of the drive of the signed in user.
+func (s *Server) AddFile(parentID string, name string, content []byte) msgraph4go.DriveItem {
+	if content == nil {
+		content = []byte{}
+	}
+
+	return s.AddDriveItem(parentID, msgraph4go.DriveItem{Name: name}, content)
+}
+
+// mimeType returns the MIME type of the item, or detects it from content.
+func mimeType(item msgraph4go.DriveItem, content []byte) string {
+	if item.File != nil && item.File.MimeType != "" {
+		return item.File.MimeType
+	}
+
+	return http.DetectContentType(content)
+}
+
+// AddMailFolder adds a mail folder for the signed in user, returning its ID.
+func (s *Server) AddMailFolder(displayName string) string {
+	path := s.mustSeed("/me/mailFolders", map[string]interface{}{
+		"displayName":      displayName,
+		"childFolderCount": 0.0,
+	})
+
+	_, id := splitPath(path)
+
+	return id
+}
+
+// AddMessage adds message to folder, which is the ID of a mail folder or a
+// well-known name, e.g. "inbox".
+func (s *Server) AddMessage(folder string, message msgraph4go.Message) msgraph4go.Message {
+	data, err := toMap(message)
+	if err != nil {
+		panic(err)
+	}
+
+	if _, ok := data["receivedDateTime"]; !ok {
+		data["receivedDateTime"] = now()
+	}
+
+	var added msgraph4go.Message
+	s.mustGet(s.mustSeed("/me/mailFolders/"+folder+"/messages", data), &added)
+
+	return added
+}
+
+// AddCalendar adds a calendar for the signed in user.
+func (s *Server) AddCalendar(calendar msgraph4go.Calendar) msgraph4go.Calendar {
+	var added msgraph4go.Calendar
+	s.mustGet(s.mustSeed("/me/calendars", calendar), &added)
+
+	return added
+}
+
+// AddEvent adds event to the calendar calendarID, or the default calendar if calendarID is "".
+func (s *Server) AddEvent(calendarID string, event msgraph4go.Event) msgraph4go.Event {
+	collection := "/me/events"
+	if calendarID != "" {
+		collection = "/me/calendars/" + calendarID + "/events"
+	}
+
+	var added msgraph4go.Event
+	s.mustGet(s.mustSeed(collection, event), &added)
+
+	return added
+}
+
+// AddContact adds a contact for the signed in user.
+func (s *Server) AddContact(contact msgraph4go.Contact) msgraph4go.Contact {
+	var added msgraph4go.Contact
+	s.mustGet(s.mustSeed("/me/contacts", contact), &added)
+
+	return added
+}
+
+// AddNotebook adds a OneNote notebook for the signed in user.
+func (s *Server) AddNotebook(notebook msgraph4go.Notebook) msgraph4go.Notebook {
+	var added msgraph4go.Notebook
+	s.mustGet(s.mustSeed("/me/onenote/notebooks", notebook), &added)
+
+	return added
+}
+
+// AddSection adds a section to the OneNote notebook notebookID.
+func (s *Server) AddSection(notebookID string, section msgraph4go.Section) msgraph4go.Section {
+	var added msgraph4go.Section
+	s.mustGet(s.mustSeed("/me/onenote/notebooks/"+notebookID+"/sections", section), &added)
+
+	return added
+}
+
+// AddPage adds a page, with the HTML content, to the OneNote section sectionID.
+func (s *Server) AddPage(sectionID string, page msgraph4go.Page, content string) msgraph4go.Page {
+	path := s.mustSeed("/me/onenote/sections/"+sectionID+"/pages", page)
+
+	s.mu.Lock()
+	o := s.objects[path]
+	o.content = []byte(content)
+	o.contentType = "text/html"
+	s.mu.Unlock()
+
+	var added msgraph4go.Page
+	s.mustGet(path, &added)
+
+	return added
+}
+
+// SetPhoto sets the profile photo of the signed in user.
+func (s *Server) SetPhoto(contentType string, data []byte) {
+	photo := map[string]interface{}{
+		"id":                      "default",
+		"@odata.mediaContentType": contentType,
+	}
+
+	if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		photo["width"] = float64(config.Width)
+		photo["height"] = float64(config.Height)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path := "/users/" + s.meID + "/photo"
+	if o, ok := s.objects[path]; ok {
+		o.data = photo
+		s.touch(o)
+	} else {
+		s.put(path, photo)
+	}
+
+	s.objects[path].content = append([]byte(nil), data...)
+	s.objects[path].contentType = contentType
+}
+
+// Seed adds item, such as a msgraph4go.Permission or a map[string]interface{},
+// to the collection at path, e.g. "/subscriptions" or
+// "/me/drive/items/{item-id}/permissions", returning the ID of the item.
+// An ID is generated unless item has an "id". A path that isn't a known
+// collection, e.g. "/me/todo/lists", becomes a new collection.
+func (s *Server) Seed(path string, item interface{}) (string, error) {
+	data, err := toMap(item)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collection, gerr := s.resolve(path, false)
+	if gerr != nil {
+		return "", gerr
+	}
+	if !s.isCollection(collection) {
+		if _, ok := s.objects[collection]; ok {
+			return "", fmt.Errorf("graphtest: %s is not a collection", path)
+		}
+		// any other path can be used as a new collection
+		s.collections[collection] = nil
+	}
+
+	created, gerr := s.create(collection, data)
+	if gerr != nil {
+		return "", gerr
+	}
+
+	_, id := splitPath(created)
+
+	return id, nil
+}
+
+// mustSeed is like Seed, but returns the path of the new item and panics on error.
+func (s *Server) mustSeed(path string, item interface{}) string {
+	data, err := toMap(item)
+	if err != nil {
+		panic(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	collection, gerr := s.resolve(path, false)
+	if gerr == nil && !s.isCollection(collection) {
+		gerr = notFound(path)
+	}
+	if gerr != nil {
+		panic(fmt.Sprintf("graphtest: adding to %s: %v", path, gerr))
+	}
+
+	created, gerr := s.create(collection, data)
+	if gerr != nil {
+		panic(fmt.Sprintf("graphtest: adding to %s: %v", path, gerr))
+	}
+
+	return created
+}
+
+// Get decodes the resource at path, e.g. "/me/messages/{id}", into v, as it would be returned by the server.
+func (s *Server) Get(path string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, gerr := s.resolve(path, false)
+	if gerr != nil {
+		return gerr
+	}
+
+	o, ok := s.objects[path]
+	if !ok {
+		return notFound(path)
+	}
+
+	data, err := json.Marshal(s.render(path, o))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+// mustGet is like Get, but panics on error.
+func (s *Server) mustGet(path string, v interface{}) {
+	if err := s.Get(path, v); err != nil {
+		panic(err)
+	}
+}
+
+// Update changes the resource at path, e.g. to simulate a change made by
+// another client. The changes, such as a map[string]interface{}, are
+// merged into the resource, and properties set to nil are removed.
+// The ETag of the resource changes, and it is returned by the next delta query.
+func (s *Server) Update(path string, changes interface{}) error {
+	data, err := json.Marshal(changes)
+	if err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, gerr := s.resolve(path, false)
+	if gerr != nil {
+		return gerr
+	}
+
+	if gerr := s.update(path, m); gerr != nil {
+		return gerr
+	}
+
+	return nil
+}
+
+// Remove deletes the resource at path, along with the children of a drive item.
+func (s *Server) Remove(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	path, gerr := s.resolve(path, false)
+	if gerr != nil {
+		return gerr
+	}
+
+	if gerr := s.remove(path); gerr != nil {
+		return gerr
+	}
+
+	return nil
+}
+
+// ExpireDeltaTokens makes all existing delta tokens invalid, so the next
+// delta query using one fails with 410 Gone and must start a full resync.
+func (s *Server) ExpireDeltaTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	s.minDelta = s.seq
+}
+
+// SentMail returns the bodies of the requests to send mail, in order.
+func (s *Server) SentMail() []json.RawMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]json.RawMessage(nil), s.sentMail...)
+}
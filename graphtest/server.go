@@ -0,0 +1,939 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package graphtest provides an in-memory fake of the Microsoft Graph API,
+// so code that uses msgraph4go can be tested without a tenant.
+//
+// A Server is a stateful in-process HTTP server that stores the signed in
+// user, drives and drive items, mail folders and messages, calendars and
+// events, contacts, OneNote notebooks, sections, and pages, and any other
+// resource added using Seed. Resources can be created, read, updated, and
+// deleted, and collections are returned as OData collection responses
+// with @odata.nextLink, delta links, and ETags. Errors are returned as
+// Graph API error responses, and faults such as throttling can be injected.
+//
+// Test example:
+//
+//	server := graphtest.NewServer()
+//	defer server.Close()
+//
+//	server.AddMessage("inbox", msgraph4go.Message{Subject: "Hello"})
+//
+//	client := server.Client()
+//	messages, err := client.ListMyMessages(nil)
+//
+// The $filter and $search query options aren't supported, and requests
+// using them fail with 501 Not Implemented. A Pager with a Filter evaluates
+// the filter locally instead.
+package graphtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bnixon67/msgraph4go"
+)
+
+// defaultPageSize is the default maximum number of items in a page of a collection.
+const defaultPageSize = 100
+
+// Server is a fake Graph API server. Use NewServer to create a Server.
+type Server struct {
+	// URL is the root endpoint of the server, for use with msgraph4go.WithGraphEndpoint.
+	URL string
+
+	// PageSize is the maximum number of items in a page of a collection,
+	// unless $top requests fewer. It should be set before sending requests.
+	PageSize int
+
+	server *httptest.Server
+
+	mu          sync.Mutex
+	objects     map[string]*object
+	collections map[string][]string
+	removed     map[string][]tombstone
+	seq         int
+	minDelta    int
+	meID        string
+	drives      map[string]string            // user ID to drive ID
+	roots       map[string]string            // drive ID to root item ID
+	folders     map[string]map[string]string // user ID to well-known mail folder names
+	calendars   map[string]string            // user ID to default calendar ID
+	monitors    map[string]string            // monitor ID to copied item ID
+	faults      []*Fault
+	requests    []Request
+	sentMail    []json.RawMessage
+}
+
+// Request is a request received by the Server.
+type Request struct {
+	Method string
+
+	// Path is the path of the request, without the API version, e.g. "/me/messages".
+	Path string
+
+	Query  url.Values
+	Header http.Header
+	Body   []byte
+}
+
+// NewServer starts and returns a new Server with a signed in user, who
+// has a drive, the well-known mail folders, and a default calendar.
+// The caller should call Close when finished, to shut it down.
+func NewServer() *Server {
+	s := &Server{
+		PageSize:    defaultPageSize,
+		objects:     make(map[string]*object),
+		collections: make(map[string][]string),
+		removed:     make(map[string][]tombstone),
+		drives:      make(map[string]string),
+		roots:       make(map[string]string),
+		folders:     make(map[string]map[string]string),
+		calendars:   make(map[string]string),
+		monitors:    make(map[string]string),
+	}
+
+	s.collections["/users"] = nil
+	s.collections["/subscriptions"] = nil
+
+	s.meID = newID()
+	s.create("/users", map[string]interface{}{
+		"id":                s.meID,
+		"displayName":       "Adele Vance",
+		"givenName":         "Adele",
+		"surname":           "Vance",
+		"mail":              "AdeleV@contoso.onmicrosoft.com",
+		"userPrincipalName": "AdeleV@contoso.onmicrosoft.com",
+	})
+
+	s.server = httptest.NewServer(s)
+	s.URL = s.server.URL
+
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+// Client returns a MSGraphClient that sends requests to the server, with
+// short retry delays. The opts are applied after those set by Client.
+func (s *Server) Client(opts ...msgraph4go.Option) *msgraph4go.MSGraphClient {
+	opts = append([]msgraph4go.Option{
+		msgraph4go.WithGraphEndpoint(s.URL),
+		msgraph4go.WithRetryPolicy(msgraph4go.RetryPolicy{
+			MaxAttempts: 5,
+			MaxElapsed:  10 * time.Second,
+			BaseDelay:   time.Millisecond,
+			MaxDelay:    10 * time.Millisecond,
+		}),
+	}, opts...)
+
+	return msgraph4go.NewWithHTTPClient(s.server.Client(), opts...)
+}
+
+// Requests returns the requests received by the server, in order.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]Request(nil), s.requests...)
+}
+
+// provisionUser creates the collections, drive, mail folders, and default calendar of a new user.
+func (s *Server) provisionUser(userID string) {
+	user := "/users/" + userID
+
+	for _, name := range []string{"messages", "mailFolders", "events", "calendars",
+		"calendarGroups", "contacts", "drives", "onenote/notebooks", "onenote/sections", "onenote/pages"} {
+		s.collections[user+"/"+name] = nil
+	}
+
+	// drive and root folder
+	driveID := "b!" + strings.ToLower(newID())
+	rootID := newID()
+	s.drives[userID] = driveID
+	s.roots[driveID] = rootID
+
+	s.put("/drives/"+driveID, map[string]interface{}{
+		"id":        driveID,
+		"driveType": "business",
+		"name":      "OneDrive",
+		"owner":     map[string]interface{}{"user": map[string]interface{}{"id": userID}},
+		"quota":     map[string]interface{}{"total": 1099511627776.0, "used": 0.0, "state": "normal"},
+	}, user+"/drives")
+
+	root := map[string]interface{}{
+		"id":              rootID,
+		"name":            "root",
+		"root":            map[string]interface{}{},
+		"folder":          map[string]interface{}{},
+		"parentReference": map[string]interface{}{"driveId": driveID},
+	}
+	stamp(root)
+	s.put("/drives/"+driveID+"/items/"+rootID, root, "/drives/"+driveID+"/items")
+
+	// well-known mail folders
+	s.folders[userID] = make(map[string]string)
+	for _, folder := range []struct{ name, displayName string }{
+		{"inbox", "Inbox"},
+		{"drafts", "Drafts"},
+		{"sentitems", "Sent Items"},
+		{"deleteditems", "Deleted Items"},
+		{"archive", "Archive"},
+		{"junkemail", "Junk Email"},
+	} {
+		path, _ := s.create(user+"/mailFolders", map[string]interface{}{
+			"displayName":      folder.displayName,
+			"childFolderCount": 0.0,
+		})
+		_, s.folders[userID][folder.name] = splitPath(path)
+	}
+
+	// default calendar in the default calendar group
+	s.create(user+"/calendarGroups", map[string]interface{}{"name": "My Calendars"})
+	path, _ := s.create(user+"/calendars", map[string]interface{}{
+		"name":              "Calendar",
+		"color":             "auto",
+		"isDefaultCalendar": true,
+		"canEdit":           true,
+		"canShare":          true,
+	})
+	_, s.calendars[userID] = splitPath(path)
+}
+
+// ServeHTTP handles a request to the fake Graph API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+
+	w.Header().Set("request-id", newUUID())
+	if id := r.Header.Get("client-request-id"); id != "" {
+		w.Header().Set("client-request-id", id)
+	}
+
+	// the monitor URL of a copy isn't under the API version
+	if strings.HasPrefix(r.URL.Path, "/monitor/") {
+		s.serveMonitor(w, r)
+		return
+	}
+
+	version, path := splitVersion(r.URL.Path)
+
+	s.mu.Lock()
+	s.requests = append(s.requests, Request{
+		Method: r.Method,
+		Path:   path,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+		Body:   body,
+	})
+	fault := s.matchFault(r.Method, path)
+	s.mu.Unlock()
+
+	if fault != nil {
+		fault.write(w, r)
+		return
+	}
+
+	if version == "" {
+		writeError(w, r, &graphError{http.StatusNotFound, "BadRequest", "Invalid version."})
+		return
+	}
+
+	// the requests of a batch are handled separately, so the lock isn't held
+	if path == "/$batch" && r.Method == http.MethodPost {
+		s.serveBatch(w, r, version, body)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.route(w, r, path, body)
+}
+
+// splitVersion splits the API version from the path of a request.
+func splitVersion(path string) (version string, rest string) {
+	for _, v := range []string{msgraph4go.VersionV1, msgraph4go.VersionBeta} {
+		if path == "/"+v || strings.HasPrefix(path, "/"+v+"/") {
+			return v, strings.TrimPrefix(path, "/"+v)
+		}
+	}
+
+	return "", path
+}
+
+// route handles a request for path, which is without the API version.
+func (s *Server) route(w http.ResponseWriter, r *http.Request, path string, body []byte) {
+	upload := r.Method == http.MethodPut && strings.HasSuffix(path, "/content")
+
+	path, err := s.resolve(path, upload)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	parent, last := splitPath(path)
+
+	switch {
+	case last == "delta":
+		s.serveDelta(w, r, parent)
+	case (last == "content" || last == "$value") && s.objects[parent] != nil:
+		s.serveContent(w, r, parent, body)
+	case last == "copy" && r.Method == http.MethodPost && isDriveItem(parent):
+		s.serveCopy(w, r, parent, body)
+	case last == "sendMail" && r.Method == http.MethodPost:
+		s.serveSendMail(w, r, parent, body)
+	case last == "recent" && strings.HasPrefix(parent, "/drives/"):
+		s.serveRecent(w, r, parent)
+	case s.objects[path] != nil:
+		s.serveObject(w, r, path, body)
+	case s.isCollection(path):
+		s.serveCollection(w, r, path, body)
+	default:
+		writeError(w, r, notFound(path))
+	}
+}
+
+// serveObject handles a request for a single object.
+func (s *Server) serveObject(w http.ResponseWriter, r *http.Request, path string, body []byte) {
+	o := s.objects[path]
+
+	switch r.Method {
+	case http.MethodGet:
+		if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, o.etag()) {
+			w.Header().Set("ETag", o.etag())
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("ETag", o.etag())
+		writeJSON(w, http.StatusOK, s.withContext(r, s.selected(r, s.render(path, o))))
+
+	case http.MethodPatch:
+		if !s.checkIfMatch(w, r, o) {
+			return
+		}
+
+		var changes map[string]interface{}
+		if err := json.Unmarshal(body, &changes); err != nil {
+			writeError(w, r, &graphError{http.StatusBadRequest, "BadRequest", "Invalid JSON: " + err.Error()})
+			return
+		}
+
+		if err := s.update(path, changes); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		w.Header().Set("ETag", o.etag())
+		writeJSON(w, http.StatusOK, s.withContext(r, s.render(path, o)))
+
+	case http.MethodDelete:
+		if !s.checkIfMatch(w, r, o) {
+			return
+		}
+
+		s.remove(path)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, r, &graphError{http.StatusMethodNotAllowed, "BadRequest", "The method is not allowed."})
+	}
+}
+
+// serveCollection handles a request to list or create the items of a collection.
+func (s *Server) serveCollection(w http.ResponseWriter, r *http.Request, path string, body []byte) {
+	switch r.Method {
+	case http.MethodGet:
+		s.serveList(w, r, s.collections[path])
+
+	case http.MethodPost:
+		var data map[string]interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			writeError(w, r, &graphError{http.StatusBadRequest, "BadRequest", "Invalid JSON: " + err.Error()})
+			return
+		}
+
+		created, err := s.create(path, prune(data).(map[string]interface{}))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		o := s.objects[created]
+		w.Header().Set("ETag", o.etag())
+		w.Header().Set("Location", s.URL+"/"+msgraph4go.VersionV1+created)
+		writeJSON(w, http.StatusCreated, s.withContext(r, s.render(created, o)))
+
+	default:
+		writeError(w, r, &graphError{http.StatusMethodNotAllowed, "BadRequest", "The method is not allowed."})
+	}
+}
+
+// serveList returns a page of the objects at paths.
+func (s *Server) serveList(w http.ResponseWriter, r *http.Request, paths []string) {
+	query := r.URL.Query()
+
+	for _, option := range []string{"$filter", "$search"} {
+		if query.Get(option) != "" {
+			writeError(w, r, &graphError{http.StatusNotImplemented, "notSupported",
+				option + " is not supported by graphtest."})
+			return
+		}
+	}
+
+	items := make([]map[string]interface{}, 0, len(paths))
+	for _, path := range paths {
+		items = append(items, s.render(path, s.objects[path]))
+	}
+
+	if option := query.Get("$orderby"); option != "" {
+		orderBy(items, option)
+	}
+
+	skip, err := queryInt(query, "$skip")
+	if err == nil && query.Get("$skiptoken") != "" {
+		skip, err = strconv.Atoi(query.Get("$skiptoken"))
+	}
+	if err != nil || skip < 0 {
+		writeError(w, r, &graphError{http.StatusBadRequest, "BadRequest", "Invalid $skip or $skiptoken."})
+		return
+	}
+
+	page, next, err := s.page(query, items, skip)
+	if err != nil {
+		writeError(w, r, &graphError{http.StatusBadRequest, "BadRequest", "Invalid $top."})
+		return
+	}
+
+	response := map[string]interface{}{"value": s.selectAll(r, page)}
+
+	if query.Get("$count") == "true" {
+		response["@odata.count"] = len(items)
+	}
+
+	if next >= 0 {
+		nextQuery := copyQuery(query)
+		nextQuery.Del("$skip")
+		nextQuery.Set("$skiptoken", strconv.Itoa(next))
+		response["@odata.nextLink"] = s.URL + r.URL.Path + "?" + nextQuery.Encode()
+	}
+
+	writeJSON(w, http.StatusOK, s.withContext(r, response))
+}
+
+// page returns the items of the page starting at skip, and the start of
+// the next page, or -1 if this is the last page.
+func (s *Server) page(query url.Values, items []map[string]interface{}, skip int) ([]map[string]interface{}, int, error) {
+	size := s.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+
+	top, err := queryInt(query, "$top")
+	if err != nil || top < 0 {
+		return nil, 0, errInvalidQuery
+	}
+	if top > 0 && top < size {
+		size = top
+	}
+
+	if skip > len(items) {
+		skip = len(items)
+	}
+
+	end := skip + size
+	if end >= len(items) {
+		return items[skip:], -1, nil
+	}
+
+	return items[skip:end], end, nil
+}
+
+// errInvalidQuery is returned for an invalid query option.
+var errInvalidQuery = &graphError{http.StatusBadRequest, "BadRequest", "Invalid query option."}
+
+// queryInt returns the integer value of the query option name, or 0 if not set.
+func queryInt(query url.Values, name string) (int, error) {
+	value := query.Get(name)
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.Atoi(value)
+}
+
+// copyQuery returns a copy of query.
+func copyQuery(query url.Values) url.Values {
+	copied := make(url.Values, len(query))
+	for k, v := range query {
+		copied[k] = append([]string(nil), v...)
+	}
+
+	return copied
+}
+
+// selected returns data with only the properties selected by the request, if any.
+func (s *Server) selected(r *http.Request, data map[string]interface{}) map[string]interface{} {
+	if option := r.URL.Query().Get("$select"); option != "" {
+		return selectProperties(data, option)
+	}
+
+	return data
+}
+
+// selectAll applies selected to each of items.
+func (s *Server) selectAll(r *http.Request, items []map[string]interface{}) []map[string]interface{} {
+	selected := make([]map[string]interface{}, len(items))
+	for n, item := range items {
+		selected[n] = s.selected(r, item)
+	}
+
+	return selected
+}
+
+// withContext adds the @odata.context of the request to data.
+func (s *Server) withContext(r *http.Request, data map[string]interface{}) map[string]interface{} {
+	version, path := splitVersion(r.URL.Path)
+	data["@odata.context"] = s.URL + "/" + version + "/$metadata#" + strings.TrimPrefix(path, "/")
+
+	return data
+}
+
+// serveDelta handles a delta query for the collection at path.
+//
+// The items changed since the $deltatoken are returned, or all items if
+// there is no $deltatoken, followed by a @odata.deltaLink for the next query.
+func (s *Server) serveDelta(w http.ResponseWriter, r *http.Request, path string) {
+	if r.Method != http.MethodGet {
+		writeError(w, r, &graphError{http.StatusMethodNotAllowed, "BadRequest", "The method is not allowed."})
+		return
+	}
+
+	// a delta query of the root of a drive includes all items of the drive
+	drive := driveOf(path)
+	if isDriveItem(path) && strings.HasSuffix(path, "/"+s.roots[drive]) {
+		path = "/drives/" + drive + "/items"
+	}
+
+	if !s.isCollection(path) {
+		writeError(w, r, notFound(path))
+		return
+	}
+
+	query := r.URL.Query()
+
+	// $skiptoken is "since.start.offset", where start is the sequence of the first page
+	since, start, offset := 0, s.seq, 0
+	if token := query.Get("$skiptoken"); token != "" {
+		parts := strings.Split(token, ".")
+		var err1, err2, err3 error
+		if len(parts) == 3 {
+			since, err1 = strconv.Atoi(parts[0])
+			start, err2 = strconv.Atoi(parts[1])
+			offset, err3 = strconv.Atoi(parts[2])
+		}
+		if len(parts) != 3 || err1 != nil || err2 != nil || err3 != nil {
+			writeError(w, r, &graphError{http.StatusBadRequest, "BadRequest", "Invalid $skiptoken."})
+			return
+		}
+	} else if token := query.Get("$deltatoken"); token != "" {
+		var err error
+		since, err = strconv.Atoi(token)
+		if err != nil || since < s.minDelta || since > s.seq {
+			writeError(w, r, &graphError{http.StatusGone, "resyncRequired",
+				"Resync required. Replace any local items with the server's version, and then retry the delta query."})
+			return
+		}
+	}
+
+	type change struct {
+		seq  int
+		item map[string]interface{}
+	}
+
+	var changes []change
+	for _, member := range s.collections[path] {
+		o := s.objects[member]
+		if o.seq > since {
+			changes = append(changes, change{o.seq, s.selected(r, s.render(member, o))})
+		}
+	}
+
+	// removed items are only reported after the initial sync
+	if since > 0 {
+		for _, t := range s.removed[path] {
+			if t.seq <= since {
+				continue
+			}
+			item := map[string]interface{}{"id": t.id}
+			if t.drive {
+				item["deleted"] = map[string]interface{}{"state": "deleted"}
+			} else {
+				item["@removed"] = map[string]interface{}{"reason": "deleted"}
+			}
+			changes = append(changes, change{t.seq, item})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].seq < changes[j].seq })
+
+	items := make([]map[string]interface{}, len(changes))
+	for n, c := range changes {
+		items[n] = c.item
+	}
+
+	page, next, err := s.page(query, items, offset)
+	if err != nil {
+		writeError(w, r, err.(*graphError))
+		return
+	}
+
+	linkQuery := copyQuery(query)
+	linkQuery.Del("$skiptoken")
+	linkQuery.Del("$deltatoken")
+
+	response := map[string]interface{}{"value": page}
+	if next >= 0 {
+		linkQuery.Set("$skiptoken", strconv.Itoa(since)+"."+strconv.Itoa(start)+"."+strconv.Itoa(next))
+		response["@odata.nextLink"] = s.URL + r.URL.Path + "?" + linkQuery.Encode()
+	} else {
+		linkQuery.Set("$deltatoken", strconv.Itoa(start))
+		response["@odata.deltaLink"] = s.URL + r.URL.Path + "?" + linkQuery.Encode()
+	}
+
+	writeJSON(w, http.StatusOK, s.withContext(r, response))
+}
+
+// serveContent handles a request for the content of the object at path,
+// such as a file or a photo.
+func (s *Server) serveContent(w http.ResponseWriter, r *http.Request, path string, body []byte) {
+	o := s.objects[path]
+
+	switch r.Method {
+	case http.MethodGet:
+		if o.content == nil {
+			writeError(w, r, notFound(path))
+			return
+		}
+
+		if match := r.Header.Get("If-None-Match"); match != "" && etagMatches(match, o.etag()) {
+			w.Header().Set("ETag", o.etag())
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", o.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(o.content)))
+		w.Header().Set("ETag", o.etag())
+		w.WriteHeader(http.StatusOK)
+		w.Write(o.content)
+
+	case http.MethodPut:
+		if !s.checkIfMatch(w, r, o) {
+			return
+		}
+
+		contentType := r.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = http.DetectContentType(body)
+		}
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+
+		created := o.version == 1 && o.content == nil
+		o.content = append([]byte(nil), body...)
+		o.contentType = contentType
+		if isDriveItem(path) {
+			o.data["size"] = float64(len(body))
+			o.data["file"] = map[string]interface{}{"mimeType": mediaType}
+		}
+		s.touch(o)
+
+		status := http.StatusOK
+		if created {
+			status = http.StatusCreated
+		}
+
+		w.Header().Set("ETag", o.etag())
+		writeJSON(w, status, s.withContext(r, s.render(path, o)))
+
+	default:
+		writeError(w, r, &graphError{http.StatusMethodNotAllowed, "BadRequest", "The method is not allowed."})
+	}
+}
+
+// serveCopy copies the drive item at path, returning the URL of a monitor for the copy.
+func (s *Server) serveCopy(w http.ResponseWriter, r *http.Request, path string, body []byte) {
+	var request struct {
+		ParentReference struct {
+			DriveID string `json:"driveId"`
+			ID      string `json:"id"`
+		} `json:"parentReference"`
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil {
+		writeError(w, r, &graphError{http.StatusBadRequest, "invalidRequest", "Invalid JSON: " + err.Error()})
+		return
+	}
+
+	drive := request.ParentReference.DriveID
+	if drive == "" {
+		drive = driveOf(path)
+	}
+	parent := "/drives/" + drive + "/items/" + request.ParentReference.ID
+	if request.ParentReference.ID == "" {
+		ref, _ := s.objects[path].data["parentReference"].(map[string]interface{})
+		id, _ := ref["id"].(string)
+		parent = "/drives/" + drive + "/items/" + id
+	}
+
+	if _, ok := s.objects[parent]; !ok {
+		writeError(w, r, notFound(parent))
+		return
+	}
+
+	copied, err := s.copyItem(path, parent, request.Name)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	monitor := newID()
+	_, s.monitors[monitor] = splitPath(copied)
+
+	w.Header().Set("Location", s.URL+"/monitor/"+monitor)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// serveMonitor returns the status of a copy, which is always completed.
+func (s *Server) serveMonitor(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	id, ok := s.monitors[strings.TrimPrefix(r.URL.Path, "/monitor/")]
+	s.mu.Unlock()
+
+	if !ok {
+		writeError(w, r, &graphError{http.StatusNotFound, "itemNotFound", "The monitor could not be found."})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":             "completed",
+		"percentageComplete": 100,
+		"resourceId":         id,
+	})
+}
+
+// serveSendMail sends a message for the user at path, saving it to the sent items folder.
+func (s *Server) serveSendMail(w http.ResponseWriter, r *http.Request, path string, body []byte) {
+	var request struct {
+		Message         map[string]interface{} `json:"message"`
+		SaveToSentItems *bool                  `json:"saveToSentItems"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil || request.Message == nil {
+		writeError(w, r, &graphError{http.StatusBadRequest, "ErrorInvalidRequest", "The message is required."})
+		return
+	}
+
+	_, userID := splitPath(path)
+	if _, ok := s.folders[userID]; !ok {
+		writeError(w, r, notFound(path))
+		return
+	}
+
+	s.sentMail = append(s.sentMail, json.RawMessage(append([]byte(nil), body...)))
+
+	if request.SaveToSentItems == nil || *request.SaveToSentItems {
+		message := prune(request.Message).(map[string]interface{})
+		message["isDraft"] = false
+		message["isRead"] = true
+		message["sentDateTime"] = now()
+		s.create(path+"/mailFolders/"+s.folders[userID]["sentitems"]+"/messages", message)
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// serveRecent returns the files of the drive at path, most recently modified first.
+func (s *Server) serveRecent(w http.ResponseWriter, r *http.Request, path string) {
+	var files []string
+	for _, item := range s.collections[path+"/items"] {
+		if _, ok := s.objects[item].data["file"]; ok {
+			files = append(files, item)
+		}
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		a, _ := s.objects[files[i]].data["lastModifiedDateTime"].(string)
+		b, _ := s.objects[files[j]].data["lastModifiedDateTime"].(string)
+		return a > b
+	})
+
+	s.serveList(w, r, files)
+}
+
+// batchRequest is a request within a JSON batch.
+type batchRequest struct {
+	ID      string            `json:"id"`
+	Method  string            `json:"method"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// batchResponse is the response to a request within a JSON batch.
+type batchResponse struct {
+	ID      string            `json:"id"`
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// serveBatch handles a JSON batch by handling each request in order.
+func (s *Server) serveBatch(w http.ResponseWriter, r *http.Request, version string, body []byte) {
+	var batch struct {
+		Requests []batchRequest `json:"requests"`
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		writeError(w, r, &graphError{http.StatusBadRequest, "BadRequest", "Invalid batch request: " + err.Error()})
+		return
+	}
+
+	if len(batch.Requests) > 20 {
+		writeError(w, r, &graphError{http.StatusBadRequest, "BadRequest", "The batch has more than 20 requests."})
+		return
+	}
+
+	responses := make([]batchResponse, 0, len(batch.Requests))
+	for _, req := range batch.Requests {
+		var reqBody []byte
+		if len(req.Body) > 0 && string(req.Body) != "null" {
+			reqBody = req.Body
+		}
+
+		sub, err := http.NewRequestWithContext(r.Context(), req.Method,
+			s.URL+"/"+version+"/"+strings.TrimPrefix(req.URL, "/"), bytes.NewReader(reqBody))
+		if err != nil {
+			responses = append(responses, batchResponse{ID: req.ID, Status: http.StatusBadRequest})
+			continue
+		}
+		for k, v := range req.Headers {
+			sub.Header.Set(k, v)
+		}
+
+		recorder := httptest.NewRecorder()
+		s.ServeHTTP(recorder, sub)
+
+		resp := batchResponse{
+			ID:      req.ID,
+			Status:  recorder.Code,
+			Headers: make(map[string]string),
+		}
+		for k := range recorder.Header() {
+			resp.Headers[k] = recorder.Header().Get(k)
+		}
+
+		// bodies that aren't JSON are base64 encoded
+		if data := recorder.Body.Bytes(); len(data) > 0 {
+			if json.Valid(data) {
+				resp.Body = data
+			} else {
+				resp.Body, _ = json.Marshal(base64.StdEncoding.EncodeToString(data))
+			}
+		}
+
+		responses = append(responses, resp)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"responses": responses})
+}
+
+// checkIfMatch writes a 412 Precondition Failed response and returns false
+// if the If-Match header of the request doesn't match the ETag of o.
+func (s *Server) checkIfMatch(w http.ResponseWriter, r *http.Request, o *object) bool {
+	match := r.Header.Get("If-Match")
+	if match == "" || etagMatches(match, o.etag()) {
+		return true
+	}
+
+	w.Header().Set("ETag", o.etag())
+	writeError(w, r, &graphError{http.StatusPreconditionFailed, "preconditionFailed",
+		"The ETag does not match the current ETag of the resource."})
+
+	return false
+}
+
+// etagMatches returns true if the If-Match or If-None-Match header value matches etag.
+func etagMatches(header string, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+
+	// split at commas outside of quotes, since an ETag may contain a comma
+	var values []string
+	quoted, start := false, 0
+	for n, r := range header {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ',' && !quoted:
+			values = append(values, header[start:n])
+			start = n + 1
+		}
+	}
+	values = append(values, header[start:])
+
+	for _, value := range values {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == "*" || value == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// writeJSON writes v as a JSON response with the status code.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes a Graph API error response for err.
+func writeError(w http.ResponseWriter, r *http.Request, err *graphError) {
+	writeJSON(w, err.status, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    err.code,
+			"message": err.message,
+			"innerError": map[string]interface{}{
+				"date":              time.Now().UTC().Format("2006-01-02T15:04:05"),
+				"request-id":        w.Header().Get("request-id"),
+				"client-request-id": r.Header.Get("client-request-id"),
+			},
+		},
+	})
+}
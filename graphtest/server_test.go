@@ -0,0 +1,367 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graphtest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bnixon67/msgraph4go"
+	"github.com/bnixon67/msgraph4go/graphtest"
+)
+
+// countRequests returns the number of requests to path received by server.
+func countRequests(server *graphtest.Server, path string) int {
+	n := 0
+	for _, r := range server.Requests() {
+		if r.Path == path {
+			n++
+		}
+	}
+
+	return n
+}
+
+func TestPaging(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	server.PageSize = 2
+	for n := 0; n < 5; n++ {
+		server.AddMessage("inbox", msgraph4go.Message{Subject: "Hello"})
+	}
+
+	var messages []msgraph4go.Message
+	err := server.Client().NewPager("/me/messages", nil).All(context.Background(), &messages)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(messages) != 5 {
+		t.Errorf("got %d messages, want 5", len(messages))
+	}
+	if n := countRequests(server, "/me/messages"); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+
+	// NextPage stops at MaxItems, even within a page
+	pager := server.Client().NewPager("/me/messages", nil)
+	pager.MaxItems = 3
+
+	total := 0
+	for {
+		var page msgraph4go.MessageCollection
+		ok, err := pager.NextPage(context.Background(), &page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !ok {
+			break
+		}
+		total += len(page.Value)
+	}
+
+	if total != 3 {
+		t.Errorf("NextPage returned %d messages, want 3", total)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	ctx := context.Background()
+
+	contact := server.AddContact(msgraph4go.Contact{GivenName: "Pavel"})
+	etag := msgraph4go.ETagOf(contact)
+	if etag == "" {
+		t.Fatal("contact has no ETag")
+	}
+
+	// another client changes the contact
+	if err := server.Update("/me/contacts/"+contact.ID, map[string]interface{}{"givenName": "Paul"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := client.UpdateContactWithContext(msgraph4go.IfMatch(ctx, etag), nil, "me", contact.ID,
+		strings.NewReader(`{"givenName":"Pavel"}`))
+	if !errors.Is(err, msgraph4go.ErrPreconditionFailed) {
+		t.Fatalf("UpdateContact error = %v, want %v", err, msgraph4go.ErrPreconditionFailed)
+	}
+
+	var precondErr *msgraph4go.PreconditionFailedError
+	if !errors.As(err, &precondErr) {
+		t.Fatalf("UpdateContact error = %T, want *PreconditionFailedError", err)
+	}
+
+	var current msgraph4go.Contact
+	if err := precondErr.Decode(&current); err != nil {
+		t.Fatal(err)
+	}
+	if current.GivenName != "Paul" || precondErr.ETag == etag || precondErr.ETag == "" {
+		t.Errorf("current = %q with ETag %q, want Paul with a new ETag", current.GivenName, precondErr.ETag)
+	}
+
+	// retry with the current ETag
+	updated, err := client.UpdateContactWithContext(msgraph4go.IfMatch(ctx, precondErr.ETag), nil, "me", contact.ID,
+		strings.NewReader(`{"givenName":"Pavel"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.GivenName != "Pavel" {
+		t.Errorf("GivenName = %q, want Pavel", updated.GivenName)
+	}
+
+	// a GET with the current ETag isn't modified
+	var got msgraph4go.Contact
+	modified, err := client.GetIfModified("/me/contacts/"+contact.ID, nil, msgraph4go.ETagOf(updated), &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modified {
+		t.Error("GetIfModified = true, want false for the current ETag")
+	}
+}
+
+func TestFaults(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+
+	server.Throttle("/me/messages", 2, 0)
+	if _, err := client.ListMyMessages(nil); err != nil {
+		t.Fatalf("ListMyMessages = %v, want retried until successful", err)
+	}
+	if n := countRequests(server, "/me/messages"); n != 3 {
+		t.Errorf("got %d requests, want 3", n)
+	}
+
+	server.ServerError("/me/messages", http.StatusServiceUnavailable, 1)
+	if _, err := client.ListMyMessages(nil); err != nil {
+		t.Fatalf("ListMyMessages = %v, want retried until successful", err)
+	}
+
+	// the client gives up after MaxAttempts
+	server.Throttle("/me/messages", 0, 0)
+	defer server.ClearFaults()
+
+	_, err := client.ListMyMessages(nil)
+	if !errors.Is(err, msgraph4go.ErrThrottled) {
+		t.Errorf("ListMyMessages error = %v, want %v", err, msgraph4go.ErrThrottled)
+	}
+}
+
+func TestDelta(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	server.PageSize = 2
+	for _, name := range []string{"Alex", "Megan", "Nestor"} {
+		server.AddContact(msgraph4go.Contact{GivenName: name})
+	}
+
+	ctx := context.Background()
+	delta := server.Client().ContactsDelta("me", nil, nil)
+
+	sync := func() map[msgraph4go.ChangeType][]string {
+		changes := make(map[msgraph4go.ChangeType][]string)
+		err := delta.Sync(ctx, func(change msgraph4go.Change) error {
+			name := change.ID
+			if contact, ok := change.Item.(*msgraph4go.Contact); ok && contact.GivenName != "" {
+				name = contact.GivenName
+			}
+			changes[change.Type] = append(changes[change.Type], name)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return changes
+	}
+
+	if changes := sync(); len(changes[msgraph4go.ChangeAdded]) != 3 {
+		t.Errorf("initial sync = %v, want 3 added", changes)
+	}
+
+	if changes := sync(); len(changes) != 0 {
+		t.Errorf("sync without changes = %v, want none", changes)
+	}
+
+	added := server.AddContact(msgraph4go.Contact{GivenName: "Lidia"})
+	if changes := sync(); len(changes[msgraph4go.ChangeUpdated]) != 1 || changes[msgraph4go.ChangeUpdated][0] != "Lidia" {
+		t.Errorf("sync after add = %v, want Lidia updated", changes)
+	}
+
+	if err := server.Remove("/me/contacts/" + added.ID); err != nil {
+		t.Fatal(err)
+	}
+	if changes := sync(); len(changes[msgraph4go.ChangeRemoved]) != 1 || changes[msgraph4go.ChangeRemoved][0] != added.ID {
+		t.Errorf("sync after remove = %v, want %s removed", changes, added.ID)
+	}
+
+	// an expired delta token starts a full sync
+	server.ExpireDeltaTokens()
+	if changes := sync(); len(changes[msgraph4go.ChangeAdded]) != 3 || len(changes) != 1 {
+		t.Errorf("sync after expiry = %v, want 3 added", changes)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	message := server.AddMessage("inbox", msgraph4go.Message{Subject: "Hello"})
+
+	responses, err := server.Client().Batch([]msgraph4go.BatchRequest{
+		{Method: http.MethodGet, URL: "/me"},
+		{Method: http.MethodGet, URL: "/me/messages/" + message.ID},
+		{Method: http.MethodGet, URL: "/me/messages/missing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(responses) != 3 {
+		t.Fatalf("got %d responses, want 3", len(responses))
+	}
+
+	var user msgraph4go.User
+	if err := responses[0].Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != server.Me().ID {
+		t.Errorf("user ID = %q, want %q", user.ID, server.Me().ID)
+	}
+
+	var got msgraph4go.Message
+	if err := responses[1].Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Subject != "Hello" {
+		t.Errorf("Subject = %q, want Hello", got.Subject)
+	}
+
+	if err := responses[2].Err(); !errors.Is(err, msgraph4go.ErrNotFound) {
+		t.Errorf("missing message error = %v, want %v", err, msgraph4go.ErrNotFound)
+	}
+
+	if n := countRequests(server, "/$batch"); n != 1 {
+		t.Errorf("got %d batch requests, want 1", n)
+	}
+}
+
+func TestPagerFilterTimestamp(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	server.PageSize = 2
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	for n := 0; n < 5; n++ {
+		server.AddMessage("inbox", msgraph4go.Message{
+			Subject:          "Message",
+			ReceivedDateTime: msgraph4go.NewTimestamp(start.Add(time.Duration(n) * time.Hour)),
+		})
+	}
+
+	// the server doesn't support $filter, so the filter is evaluated locally
+	since := start.Add(90 * time.Minute)
+	for _, value := range []interface{}{since, *msgraph4go.NewTimestamp(since), msgraph4go.NewTimestamp(since)} {
+		var messages []msgraph4go.Message
+		err := server.Client().NewPager("/me/messages", nil).
+			Filter(msgraph4go.Gt("receivedDateTime", value)).
+			All(context.Background(), &messages)
+		if err != nil {
+			t.Fatalf("%T: %v", value, err)
+		}
+
+		if len(messages) != 3 {
+			t.Errorf("%T: got %d messages, want 3", value, len(messages))
+		}
+		for _, message := range messages {
+			if !message.ReceivedDateTime.Time().After(since) {
+				t.Errorf("%T: message received %v, not after %v", value, message.ReceivedDateTime, since)
+			}
+		}
+	}
+}
+
+func TestPagerFilterNextPage(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	server.PageSize = 2
+	start := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	for n := 0; n < 5; n++ {
+		server.AddMessage("inbox", msgraph4go.Message{
+			Subject:          "Message",
+			ReceivedDateTime: msgraph4go.NewTimestamp(start.Add(time.Duration(n) * time.Hour)),
+		})
+	}
+
+	// NextPage matches the items as a Message, like Next
+	pager := server.Client().NewPager("/me/messages", nil).
+		Filter(msgraph4go.Ge("receivedDateTime", "2021-03-01T14:00:00Z"))
+
+	count := 0
+	for {
+		var page msgraph4go.MessageCollection
+		more, err := pager.NextPage(context.Background(), &page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !more {
+			break
+		}
+		count += len(page.Value)
+	}
+
+	if count != 3 {
+		t.Errorf("got %d messages, want 3", count)
+	}
+}
+
+func TestPagerFilterError(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	server.AddMessage("inbox", msgraph4go.Message{Subject: "Hello"})
+	server.InjectFault(graphtest.Fault{
+		Path:       "/me/messages",
+		StatusCode: http.StatusBadRequest,
+		Code:       "BadRequest",
+		Message:    "Could not find a property named 'subjekt' on type 'microsoft.graph.message'.",
+	})
+
+	// an error unrelated to $filter is returned rather than filtering locally
+	var messages []msgraph4go.Message
+	err := server.Client().NewPager("/me/messages", nil).
+		Filter(msgraph4go.Eq("subject", "Hello")).
+		All(context.Background(), &messages)
+	var graphErr *msgraph4go.GraphErrorResponse
+	if !errors.As(err, &graphErr) || graphErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v, want 400 Bad Request", err)
+	}
+
+	if n := countRequests(server, "/me/messages"); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
@@ -0,0 +1,755 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graphtest
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// graphTimeFormat is the format of timestamps returned by the server.
+const graphTimeFormat = "2006-01-02T15:04:05Z"
+
+// object is a resource stored by the Server.
+type object struct {
+	data        map[string]interface{}
+	version     int
+	seq         int
+	content     []byte
+	contentType string
+
+	// true for an Outlook item, which has a changeKey
+	outlook bool
+}
+
+// tombstone records the removal of an object from a collection, for delta queries.
+type tombstone struct {
+	id    string
+	seq   int
+	drive bool
+}
+
+// graphError is an error response returned by the Server.
+type graphError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *graphError) Error() string {
+	return fmt.Sprintf("graphtest: %d %s: %s", e.status, e.code, e.message)
+}
+
+// notFound returns a graphError for a resource that doesn't exist.
+func notFound(path string) *graphError {
+	code := "ResourceNotFound"
+	if strings.HasPrefix(path, "/drives/") {
+		code = "itemNotFound"
+	}
+
+	return &graphError{http.StatusNotFound, code, "The resource could not be found."}
+}
+
+// newID returns a random ID, similar in form to the IDs used by the Graph API.
+func newID() string {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return strings.ToUpper(hex.EncodeToString(b))
+}
+
+// newUUID returns a random UUID, used for request IDs.
+func newUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// now returns the current time formatted as a Graph API timestamp.
+func now() string {
+	return time.Now().UTC().Format(graphTimeFormat)
+}
+
+// toMap converts v, such as a msgraph4go.Message, into a JSON object,
+// removing null and empty string values.
+func toMap(v interface{}) (map[string]interface{}, error) {
+	if m, ok := v.(map[string]interface{}); ok {
+		return prune(m).(map[string]interface{}), nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	err = json.Unmarshal(data, &m)
+	if err != nil {
+		return nil, fmt.Errorf("graphtest: %T is not a JSON object", v)
+	}
+
+	return prune(m).(map[string]interface{}), nil
+}
+
+// prune returns a copy of v without null and empty string values.
+func prune(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			if value == nil || value == "" {
+				continue
+			}
+			m[k] = prune(value)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for n, value := range v {
+			s[n] = prune(value)
+		}
+		return s
+	default:
+		return v
+	}
+}
+
+// copyMap returns a deep copy of m.
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	data, _ := json.Marshal(m)
+
+	var copied map[string]interface{}
+	json.Unmarshal(data, &copied)
+
+	return copied
+}
+
+// splitPath returns the parent path and the last segment of path.
+func splitPath(path string) (parent string, last string) {
+	n := strings.LastIndex(path, "/")
+	if n < 0 {
+		return "", path
+	}
+
+	return path[:n], path[n+1:]
+}
+
+// isDriveItem returns true if path is the canonical path of a drive item.
+func isDriveItem(path string) bool {
+	segments := strings.Split(path, "/")
+	return len(segments) == 5 && segments[1] == "drives" && segments[3] == "items"
+}
+
+// isOutlookItem returns true if path is the canonical path of an Outlook
+// item, such as a message or contact, which has a changeKey.
+func isOutlookItem(path string) bool {
+	segments := strings.Split(path, "/")
+	if len(segments) != 5 || segments[1] != "users" {
+		return false
+	}
+
+	switch segments[3] {
+	case "messages", "events", "contacts", "calendars":
+		return true
+	}
+
+	return false
+}
+
+// driveOf returns the drive ID of a path under /drives.
+func driveOf(path string) string {
+	segments := strings.Split(path, "/")
+	if len(segments) < 3 || segments[1] != "drives" {
+		return ""
+	}
+
+	return segments[2]
+}
+
+// etag returns the ETag of the object at path.
+func (o *object) etag() string {
+	if o.outlook {
+		return `W/"` + o.changeKey() + `"`
+	}
+
+	id, _ := o.data["id"].(string)
+	return `"{` + id + `},` + strconv.Itoa(o.version) + `"`
+}
+
+// changeKey returns the changeKey of o, which changes with each update.
+func (o *object) changeKey() string {
+	id, _ := o.data["id"].(string)
+	return base64.RawURLEncoding.EncodeToString([]byte(id + "," + strconv.Itoa(o.version)))
+}
+
+// render returns the JSON representation of the object at path.
+func (s *Server) render(path string, o *object) map[string]interface{} {
+	data := copyMap(o.data)
+	data["@odata.etag"] = o.etag()
+
+	if isOutlookItem(path) {
+		data["changeKey"] = o.changeKey()
+	}
+
+	if isDriveItem(path) {
+		data["eTag"] = o.etag()
+		data["cTag"] = `"c:` + strings.Trim(o.etag(), `"`) + `"`
+
+		if folder, ok := data["folder"].(map[string]interface{}); ok {
+			folder["childCount"] = len(s.collections[path+"/children"])
+		}
+	}
+
+	return data
+}
+
+// put stores data as the object at path, adding it to collections.
+func (s *Server) put(path string, data map[string]interface{}, collections ...string) *object {
+	s.seq++
+
+	o := &object{data: data, version: 1, seq: s.seq, outlook: isOutlookItem(path)}
+	s.objects[path] = o
+
+	for _, collection := range collections {
+		s.collections[collection] = append(s.collections[collection], path)
+	}
+
+	return o
+}
+
+// touch records a change to o.
+func (s *Server) touch(o *object) {
+	s.seq++
+	o.seq = s.seq
+	o.version++
+
+	if _, ok := o.data["lastModifiedDateTime"]; ok {
+		o.data["lastModifiedDateTime"] = now()
+	}
+}
+
+// stamp sets the created and last modified timestamps of data, unless already set.
+func stamp(data map[string]interface{}) {
+	if _, ok := data["createdDateTime"]; !ok {
+		data["createdDateTime"] = now()
+	}
+	if _, ok := data["lastModifiedDateTime"]; !ok {
+		data["lastModifiedDateTime"] = data["createdDateTime"]
+	}
+}
+
+// isCollection returns true if path is a collection, which is either a
+// known collection or a collection of a drive item, such as its permissions.
+func (s *Server) isCollection(path string) bool {
+	if _, ok := s.collections[path]; ok {
+		return true
+	}
+
+	parent, _ := splitPath(path)
+	_, ok := s.objects[parent]
+
+	return ok && isDriveItem(parent)
+}
+
+// resolve returns the canonical path for path, a request path without the
+// API version, resolving aliases such as /me and path-based addressing
+// of drive items. For an upload to a drive item path that doesn't
+// exist, the item is created if create is true.
+func (s *Server) resolve(path string, create bool) (string, *graphError) {
+	path = strings.TrimSuffix(path, "/")
+
+	switch {
+	case path == "/me" || strings.HasPrefix(path, "/me/"):
+		path = "/users/" + s.meID + strings.TrimPrefix(path, "/me")
+	case path == "/drive" || strings.HasPrefix(path, "/drive/"):
+		path = "/users/" + s.meID + path
+	}
+
+	segments := strings.Split(path, "/")
+
+	if len(segments) >= 3 && segments[1] == "users" && segments[2] != "delta" {
+		// a user can be addressed by ID or userPrincipalName
+		userID, ok := s.userID(segments[2])
+		if !ok {
+			return "", notFound(path)
+		}
+		segments[2] = userID
+
+		if len(segments) >= 4 {
+			switch segments[3] {
+			case "drive":
+				segments = append([]string{"", "drives", s.drives[userID]}, segments[4:]...)
+			case "calendar":
+				segments = append([]string{"", "users", userID, "calendars", s.calendars[userID]}, segments[4:]...)
+			case "calendarView":
+				segments[3] = "events"
+			case "mailFolders":
+				if len(segments) >= 5 {
+					if id, ok := s.folders[userID][strings.ToLower(segments[4])]; ok {
+						segments[4] = id
+					}
+				}
+			}
+		}
+	}
+
+	if len(segments) >= 4 && segments[1] == "drives" {
+		root := s.roots[segments[2]]
+
+		switch {
+		case segments[3] == "root" || strings.HasPrefix(segments[3], "root:"):
+			// /drives/{id}/root is the root item
+			segments = append([]string{"", "drives", segments[2], "items", root + strings.TrimPrefix(segments[3], "root")}, segments[4:]...)
+		case segments[3] == "items" && len(segments) >= 5 &&
+			(segments[4] == "root" || strings.HasPrefix(segments[4], "root:")):
+			segments[4] = root + strings.TrimPrefix(segments[4], "root")
+		}
+	}
+
+	path = strings.Join(segments, "/")
+
+	if strings.HasPrefix(path, "/drives/") && strings.Contains(path, ":") {
+		return s.resolveItemPath(path, create)
+	}
+
+	return path, nil
+}
+
+// resolveItemPath resolves a drive item addressed by path, such as
+// /drives/{drive-id}/items/{item-id}:/folder/file.txt:/content
+func (s *Server) resolveItemPath(path string, create bool) (string, *graphError) {
+	n := strings.Index(path, ":")
+	item, rest := path[:n], path[n+1:]
+
+	relative, suffix := rest, ""
+	if n := strings.Index(rest, ":"); n >= 0 {
+		relative, suffix = rest[:n], rest[n+1:]
+	}
+
+	if _, ok := s.objects[item]; !ok || !isDriveItem(item) {
+		return "", notFound(path)
+	}
+
+	names := strings.Split(strings.Trim(relative, "/"), "/")
+	for n, name := range names {
+		if name == "" {
+			continue
+		}
+
+		child, ok := s.childByName(item, name)
+		if !ok {
+			// create a new file for an upload
+			if create && n == len(names)-1 && suffix == "/content" {
+				child, err := s.create(item+"/children", map[string]interface{}{
+					"name": name,
+					"file": map[string]interface{}{},
+					"size": 0,
+				})
+				if err != nil {
+					return "", err
+				}
+				return child + suffix, nil
+			}
+			return "", notFound(path)
+		}
+		item = child
+	}
+
+	return item + suffix, nil
+}
+
+// childByName returns the path of the child of the drive item at parent named name, ignoring case.
+func (s *Server) childByName(parent string, name string) (string, bool) {
+	for _, child := range s.collections[parent+"/children"] {
+		if childName, _ := s.objects[child].data["name"].(string); strings.EqualFold(childName, name) {
+			return child, true
+		}
+	}
+
+	return "", false
+}
+
+// userID returns the ID of the user identified by an ID or userPrincipalName.
+func (s *Server) userID(user string) (string, bool) {
+	if _, ok := s.objects["/users/"+user]; ok {
+		return user, true
+	}
+
+	for _, path := range s.collections["/users"] {
+		if upn, _ := s.objects[path].data["userPrincipalName"].(string); strings.EqualFold(upn, user) {
+			_, id := splitPath(path)
+			return id, true
+		}
+	}
+
+	return "", false
+}
+
+// create creates an object in collection from data, returning its path.
+//
+// Items of some collections are also added to related collections, e.g. a
+// message in a mail folder is also in the messages of the user.
+func (s *Server) create(collection string, data map[string]interface{}) (string, *graphError) {
+	id, _ := data["id"].(string)
+	if id == "" {
+		id = newID()
+		data["id"] = id
+	}
+
+	segments := strings.Split(collection, "/")
+	collections := []string{collection}
+	path := collection + "/" + id
+
+	switch {
+	case len(segments) == 6 && segments[1] == "drives" && segments[3] == "items" && segments[5] == "children":
+		// a drive item is in the children of its parent and the items of the drive
+		parent := strings.TrimSuffix(collection, "/children")
+		if _, ok := s.objects[parent]; !ok {
+			return "", notFound(parent)
+		}
+		name, _ := data["name"].(string)
+		if name == "" {
+			return "", &graphError{http.StatusBadRequest, "invalidRequest", "The name of the item is required."}
+		}
+		if _, ok := s.childByName(parent, name); ok {
+			return "", &graphError{http.StatusConflict, "nameAlreadyExists", "The specified item name already exists."}
+		}
+		data["parentReference"] = map[string]interface{}{"driveId": segments[2], "id": segments[4]}
+		path = "/drives/" + segments[2] + "/items/" + id
+		collections = append(collections, "/drives/"+segments[2]+"/items")
+		stamp(data)
+
+	case len(segments) == 6 && segments[3] == "mailFolders" && segments[5] == "messages":
+		data["parentFolderId"] = segments[4]
+		path = "/users/" + segments[2] + "/messages/" + id
+		collections = append(collections, "/users/"+segments[2]+"/messages")
+		stamp(data)
+
+	case len(segments) == 4 && segments[3] == "messages":
+		// a new message is a draft
+		folder := s.folders[segments[2]]["drafts"]
+		data["parentFolderId"] = folder
+		collections = append(collections, "/users/"+segments[2]+"/mailFolders/"+folder+"/messages")
+		stamp(data)
+
+	case len(segments) == 6 && segments[3] == "calendars" && segments[5] == "events":
+		path = "/users/" + segments[2] + "/events/" + id
+		collections = append(collections, "/users/"+segments[2]+"/events")
+		stamp(data)
+
+	case len(segments) == 4 && segments[3] == "events":
+		collections = append(collections, "/users/"+segments[2]+"/calendars/"+s.calendars[segments[2]]+"/events")
+		stamp(data)
+
+	case len(segments) == 4 && segments[3] == "mailFolders":
+		s.collections[path+"/messages"] = nil
+
+	case len(segments) == 4 && segments[3] == "calendars":
+		s.collections[path+"/events"] = nil
+
+	case len(segments) == 7 && segments[4] == "notebooks" && segments[6] == "sections":
+		notebook := s.objects[strings.TrimSuffix(collection, "/sections")]
+		if notebook == nil {
+			return "", notFound(collection)
+		}
+		data["parentNotebook"] = map[string]interface{}{"id": segments[5], "displayName": notebook.data["displayName"]}
+		path = "/users/" + segments[2] + "/onenote/sections/" + id
+		collections = append(collections, "/users/"+segments[2]+"/onenote/sections")
+		s.collections[path+"/pages"] = nil
+		stamp(data)
+
+	case len(segments) == 7 && segments[4] == "sections" && segments[6] == "pages":
+		section := s.objects[strings.TrimSuffix(collection, "/pages")]
+		if section == nil {
+			return "", notFound(collection)
+		}
+		data["parentSection"] = map[string]interface{}{"id": segments[5], "displayName": section.data["displayName"]}
+		path = "/users/" + segments[2] + "/onenote/pages/" + id
+		collections = append(collections, "/users/"+segments[2]+"/onenote/pages")
+		stamp(data)
+
+	case len(segments) == 5 && segments[3] == "onenote" && segments[4] == "notebooks":
+		s.collections[path+"/sections"] = nil
+		stamp(data)
+
+	case collection == "/users":
+		s.provisionUser(id)
+
+	case collection != "/subscriptions":
+		stamp(data)
+	}
+
+	if _, ok := s.objects[path]; ok {
+		return "", &graphError{http.StatusConflict, "conflict", "An item with the same ID already exists."}
+	}
+
+	s.put(path, data, collections...)
+
+	return path, nil
+}
+
+// update applies changes to the object at path, removing properties set to null.
+func (s *Server) update(path string, changes map[string]interface{}) *graphError {
+	o, ok := s.objects[path]
+	if !ok {
+		return notFound(path)
+	}
+
+	// moving a drive item changes its parent
+	if ref, ok := changes["parentReference"].(map[string]interface{}); ok && isDriveItem(path) {
+		if newParent, _ := ref["id"].(string); newParent != "" {
+			err := s.move(path, o, newParent)
+			if err != nil {
+				return err
+			}
+		}
+		delete(changes, "parentReference")
+	}
+
+	for k, v := range changes {
+		if k == "id" {
+			continue
+		}
+		if v == nil {
+			delete(o.data, k)
+			continue
+		}
+		o.data[k] = v
+	}
+
+	s.touch(o)
+
+	return nil
+}
+
+// move moves the drive item at path to the parent with the ID newParent.
+func (s *Server) move(path string, o *object, newParent string) *graphError {
+	drive := driveOf(path)
+	parentPath := "/drives/" + drive + "/items/" + newParent
+	if _, ok := s.objects[parentPath]; !ok {
+		return notFound(parentPath)
+	}
+
+	ref, _ := o.data["parentReference"].(map[string]interface{})
+	oldParent, _ := ref["id"].(string)
+
+	s.removeFrom("/drives/"+drive+"/items/"+oldParent+"/children", path)
+	s.collections[parentPath+"/children"] = append(s.collections[parentPath+"/children"], path)
+	o.data["parentReference"] = map[string]interface{}{"driveId": drive, "id": newParent}
+
+	return nil
+}
+
+// removeFrom removes path from collection, returning true if it was a member.
+func (s *Server) removeFrom(collection string, path string) bool {
+	members := s.collections[collection]
+	for n, member := range members {
+		if member == path {
+			s.collections[collection] = append(members[:n:n], members[n+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// remove deletes the object at path, along with the children of a drive
+// item, recording tombstones for delta queries.
+func (s *Server) remove(path string) *graphError {
+	o, ok := s.objects[path]
+	if !ok {
+		return notFound(path)
+	}
+
+	for _, child := range append([]string(nil), s.collections[path+"/children"]...) {
+		s.remove(child)
+	}
+
+	s.seq++
+	id, _ := o.data["id"].(string)
+
+	// sorted for a predictable order of tombstones
+	names := make([]string, 0, len(s.collections))
+	for collection := range s.collections {
+		names = append(names, collection)
+	}
+	sort.Strings(names)
+
+	for _, collection := range names {
+		if s.removeFrom(collection, path) {
+			s.removed[collection] = append(s.removed[collection],
+				tombstone{id: id, seq: s.seq, drive: isDriveItem(path)})
+		}
+	}
+
+	delete(s.objects, path)
+
+	// remove the collections of the object, such as its children
+	for collection := range s.collections {
+		if strings.HasPrefix(collection, path+"/") {
+			delete(s.collections, collection)
+		}
+	}
+
+	return nil
+}
+
+// copyItem copies the drive item at path into the folder with the path parent, returning the path of the copy.
+func (s *Server) copyItem(path string, parent string, name string) (string, *graphError) {
+	o := s.objects[path]
+
+	data := copyMap(o.data)
+	delete(data, "id")
+	delete(data, "createdDateTime")
+	delete(data, "lastModifiedDateTime")
+	if name != "" {
+		data["name"] = name
+	}
+
+	copied, err := s.create(parent+"/children", data)
+	if err != nil {
+		return "", err
+	}
+
+	s.objects[copied].content = o.content
+	s.objects[copied].contentType = o.contentType
+
+	for _, child := range s.collections[path+"/children"] {
+		_, err := s.copyItem(child, copied, "")
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return copied, nil
+}
+
+// compareValues compares JSON values for $orderby, returning -1, 0, or 1.
+func compareValues(a interface{}, b interface{}) int {
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			switch {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if b, ok := b.(bool); ok {
+			switch {
+			case a == b:
+				return 0
+			case !a:
+				return -1
+			}
+			return 1
+		}
+	case nil:
+		if b == nil {
+			return 0
+		}
+		return -1
+	}
+
+	if b == nil {
+		return 1
+	}
+
+	return strings.Compare(strings.ToLower(fmt.Sprint(a)), strings.ToLower(fmt.Sprint(b)))
+}
+
+// property returns the value of the property of data at path, e.g. "from/emailAddress/address".
+func property(data map[string]interface{}, path string) interface{} {
+	var v interface{} = data
+	for _, name := range strings.Split(path, "/") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+
+	return v
+}
+
+// orderBy sorts items according to the $orderby query option.
+func orderBy(items []map[string]interface{}, option string) {
+	type key struct {
+		property string
+		desc     bool
+	}
+
+	var keys []key
+	for _, clause := range strings.Split(option, ",") {
+		fields := strings.Fields(clause)
+		if len(fields) == 0 {
+			continue
+		}
+		keys = append(keys, key{fields[0], len(fields) > 1 && strings.EqualFold(fields[1], "desc")})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		for _, k := range keys {
+			c := compareValues(property(items[i], k.property), property(items[j], k.property))
+			if k.desc {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+}
+
+// selectProperties returns data with only the properties in the $select query option.
+func selectProperties(data map[string]interface{}, option string) map[string]interface{} {
+	selected := map[string]interface{}{}
+	for k, v := range data {
+		if k == "id" || strings.HasPrefix(k, "@") {
+			selected[k] = v
+		}
+	}
+
+	for _, name := range strings.Split(option, ",") {
+		name = strings.TrimSpace(name)
+		if v, ok := data[name]; ok {
+			selected[name] = v
+		}
+	}
+
+	return selected
+}
@@ -0,0 +1,39 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+// Logger logs diagnostic messages, such as retried requests and refreshed tokens.
+//
+// *log.Logger implements Logger, e.g. log.New(os.Stderr, "msgraph4go: ", log.LstdFlags).
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// nopLogger is a Logger that discards all messages.
+type nopLogger struct{}
+
+func (nopLogger) Printf(format string, v ...interface{}) {}
+
+// WithLogger sets the Logger for diagnostic messages. By default, nothing is logged.
+func WithLogger(logger Logger) Option {
+	return func(o *options) {
+		if logger == nil {
+			logger = nopLogger{}
+		}
+		o.logger = logger
+	}
+}
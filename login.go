@@ -0,0 +1,121 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+// LoginFunc interactively signs in a user to get a new token when no token is stored.
+//
+// conf identifies the application, the scopes, and the endpoints.
+// loginHint is the account set by WithAccount, if any.
+type LoginFunc func(ctx context.Context, conf *oauth2.Config, loginHint string) (*oauth2.Token, error)
+
+// WithLogin sets how a user signs in when no token is stored.
+// The default is PasteURLLogin using standard input and output.
+//
+// WithLogin(nil) disables interactive login, e.g. for a server, so
+// NewClient returns ErrInteractiveLoginRequired instead.
+func WithLogin(login LoginFunc) Option {
+	return func(o *options) {
+		o.login = login
+	}
+}
+
+// PasteURLLogin returns a LoginFunc for a host without a browser.
+//
+// The user is instructed, using out, to vist a URL to login and authorize
+// the client. Once the login is successful, the user must copy the
+// response URL from the browser and provide it using in.
+func PasteURLLogin(in io.Reader, out io.Writer) LoginFunc {
+	return func(ctx context.Context, conf *oauth2.Config, loginHint string) (*oauth2.Token, error) {
+		// generate random state to detect Cross-Site Request Forgery
+		state, err := randomBytesBase64(32)
+		if err != nil {
+			return nil, err
+		}
+
+		// get authentication URL for offline access
+		authURL := conf.AuthCodeURL(state, authCodeOptions(loginHint)...)
+
+		// instruct the user to vist the authentication URL
+		fmt.Fprintln(out, "Visit the following URL in a browser to authenticate this application")
+		fmt.Fprintln(out, "After authentication, copy the response URL from the browser")
+		fmt.Fprintln(out, authURL)
+
+		// read the response URL
+		fmt.Fprintln(out, "Enter the response URL:")
+		responseString, err := bufio.NewReader(in).ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		responseString = strings.TrimSpace(responseString)
+
+		// parse the response URL
+		responseURL, err := url.Parse(responseString)
+		if err != nil {
+			return nil, err
+		}
+		// get and compare state to prevent Cross-Site Request Forgery
+		query := responseURL.Query()
+		responseState := query.Get("state")
+		if responseState != state {
+			return nil, ErrStateMismatch
+		}
+
+		// the authorization failed, e.g. consent_required
+		if query.Get("error") != "" {
+			return nil, &AuthError{
+				Op:          "authorize",
+				Code:        query.Get("error"),
+				Description: query.Get("error_description"),
+			}
+		}
+
+		// get authorization code
+		code := query.Get("code")
+		if code == "" {
+			return nil, errors.New("msgraph4go: no authorization code in the response URL")
+		}
+
+		// exchange authorize code for token
+		return conf.Exchange(ctx, code)
+	}
+}
+
+// authCodeOptions returns the options for an authorization code request for offline access.
+func authCodeOptions(loginHint string) []oauth2.AuthCodeOption {
+	opts := []oauth2.AuthCodeOption{
+		oauth2.AccessTypeOffline,
+		oauth2.SetAuthURLParam("prompt", "consent"),
+	}
+
+	if loginHint != "" {
+		opts = append(opts, oauth2.SetAuthURLParam("login_hint", loginHint))
+	}
+
+	return opts
+}
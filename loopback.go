@@ -0,0 +1,246 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// defaultLoopbackRedirect is the redirect URI used by LoopbackLogin unless
+// WithRedirectURL sets another http://localhost or http://127.0.0.1 URI.
+const defaultLoopbackRedirect = "http://localhost"
+
+// loopbackResult is the result of the redirect to the loopback listener.
+type loopbackResult struct {
+	code string
+	err  error
+}
+
+// LoopbackLogin returns a LoginFunc for a host with a browser.
+//
+// A HTTP listener is started on localhost using a random port, which must
+// be allowed by registering http://localhost as a redirect URI of the
+// application. The authorize URL, with a PKCE S256 code challenge, is
+// passed to open, e.g. OpenBrowser. If open is nil or fails, the URL is
+// written to out, e.g. os.Stdout, so the user can open it. out may be nil
+// if open always succeeds.
+//
+// The authorization code is captured from the redirect and exchanged for a
+// token along with the PKCE code verifier. The listener is shut down once
+// the code is received, or after timeout.
+func LoopbackLogin(open func(authURL string) error, out io.Writer, timeout time.Duration) LoginFunc {
+	return func(ctx context.Context, conf *oauth2.Config, loginHint string) (*oauth2.Token, error) {
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		redirect, err := loopbackRedirect(conf.RedirectURL)
+		if err != nil {
+			return nil, err
+		}
+
+		// listen on a random port
+		listener, err := net.Listen("tcp", net.JoinHostPort(redirect.Hostname(), "0"))
+		if err != nil {
+			return nil, err
+		}
+		redirect.Host = net.JoinHostPort(redirect.Hostname(),
+			strconv.Itoa(listener.Addr().(*net.TCPAddr).Port))
+
+		loopbackConf := *conf
+		loopbackConf.RedirectURL = redirect.String()
+
+		// generate random state to detect Cross-Site Request Forgery
+		state, err := randomBytesBase64(32)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+
+		verifier, challenge, err := pkceChallenge()
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+
+		authOpts := append(authCodeOptions(loginHint),
+			oauth2.SetAuthURLParam("code_challenge", challenge),
+			oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+		)
+		authURL := loopbackConf.AuthCodeURL(state, authOpts...)
+
+		results := make(chan loopbackResult, 1)
+		server := &http.Server{Handler: loopbackHandler(redirect.Path, state, results)}
+		go server.Serve(listener)
+
+		defer func() {
+			// allow the response to the browser to complete
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			server.Shutdown(shutdownCtx)
+		}()
+
+		if (open == nil || open(authURL) != nil) && out != nil {
+			fmt.Fprintln(out, "Visit the following URL in a browser to authenticate this application")
+			fmt.Fprintln(out, authURL)
+		}
+
+		var result loopbackResult
+		select {
+		case result = <-results:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		if result.err != nil {
+			return nil, result.err
+		}
+
+		// exchange authorize code for token, proving possession of the verifier
+		return loopbackConf.Exchange(ctx, result.code,
+			oauth2.SetAuthURLParam("code_verifier", verifier))
+	}
+}
+
+// loopbackRedirect returns the loopback redirect URI based on redirectURL.
+func loopbackRedirect(redirectURL string) (*url.URL, error) {
+	redirect, err := url.Parse(redirectURL)
+	if err != nil || redirect.Scheme != "http" {
+		redirect, err = url.Parse(defaultLoopbackRedirect)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	switch redirect.Hostname() {
+	case "localhost", "127.0.0.1", "::1":
+	default:
+		redirect, err = url.Parse(defaultLoopbackRedirect)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if redirect.Path == "" {
+		redirect.Path = "/"
+	}
+
+	return redirect, nil
+}
+
+// loopbackHandler returns a handler for the redirect that sends the
+// authorization code, or an error, to results.
+//
+// Other requests, such as for a favicon, a prefetch, or a redirect with
+// the wrong state, get 400 Bad Request, and the handler keeps waiting for
+// the redirect, so they can't end the login.
+func loopbackHandler(path string, state string, results chan<- loopbackResult) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+		if r.URL.Path != path || (query.Get("code") == "" && query.Get("error") == "") {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, "Not an authentication response.")
+			return
+		}
+
+		// the state doesn't match a request that wasn't made by this login
+		if query.Get("state") != state {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, "Invalid state. You can close this window.")
+			return
+		}
+
+		var result loopbackResult
+		if query.Get("error") != "" {
+			result.err = &AuthError{
+				Op:          "authorize",
+				Code:        query.Get("error"),
+				Description: query.Get("error_description"),
+			}
+		} else {
+			result.code = query.Get("code")
+		}
+
+		if result.err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, "Authentication failed. You can close this window.")
+		} else {
+			fmt.Fprintln(w, "Authentication complete. You can close this window.")
+		}
+
+		// only the first result is used
+		select {
+		case results <- result:
+		default:
+		}
+	})
+}
+
+// pkceChallenge returns a random PKCE code verifier and its S256 code challenge (RFC 7636).
+func pkceChallenge() (verifier string, challenge string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	verifier = base64.RawURLEncoding.EncodeToString(b)
+	sum := sha256.Sum256([]byte(verifier))
+	challenge = base64.RawURLEncoding.EncodeToString(sum[:])
+
+	return verifier, challenge, nil
+}
+
+// OpenBrowser opens urlString in the default browser, for use with LoopbackLogin.
+func OpenBrowser(urlString string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", urlString)
+	case "darwin":
+		cmd = exec.Command("open", urlString)
+	default:
+		// a browser can't be opened without a display
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return errors.New("msgraph4go: no display to open a browser")
+		}
+		cmd = exec.Command("xdg-open", urlString)
+	}
+
+	return cmd.Start()
+}
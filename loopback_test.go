@@ -0,0 +1,152 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestLoopbackHandler(t *testing.T) {
+	results := make(chan loopbackResult, 1)
+	handler := loopbackHandler("/", "the-state", results)
+
+	// requests that aren't the redirect of this login are rejected
+	for _, target := range []string{
+		"/favicon.ico",
+		"/",
+		"/?state=the-state",
+		"/?code=code&state=other",
+		"/?code=code",
+		"/?error=access_denied&state=other",
+		"/other?code=code&state=the-state",
+	} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want %d", target, w.Code, http.StatusBadRequest)
+		}
+		select {
+		case result := <-results:
+			t.Fatalf("%s: result = %+v, want none", target, result)
+		default:
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?code=the-code&state=the-state", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusOK)
+	}
+	if result := <-results; result.err != nil || result.code != "the-code" {
+		t.Errorf("result = %+v, want the-code", result)
+	}
+
+	// an error from the authorization server ends the login
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet,
+		"/?error=access_denied&error_description=denied&state=the-state", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	var authErr *AuthError
+	if result := <-results; !errors.As(result.err, &authErr) || authErr.Code != "access_denied" {
+		t.Errorf("result = %+v, want access_denied", result)
+	}
+}
+
+func TestLoopbackLogin(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("code") != "the-code" || r.PostForm.Get("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	defer tokenServer.Close()
+
+	conf := &oauth2.Config{
+		ClientID: "client-id",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  tokenServer.URL + "/authorize",
+			TokenURL: tokenServer.URL + "/token",
+		},
+		RedirectURL: "http://127.0.0.1/callback",
+	}
+
+	// the browser requests a favicon and the redirect of an earlier login before the redirect
+	var statuses []int
+	open := func(authURL string) error {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		redirect, err := url.Parse(u.Query().Get("redirect_uri"))
+		if err != nil {
+			return err
+		}
+
+		for _, query := range []string{"", "code=old-code&state=old-state", "code=the-code&state=" + u.Query().Get("state")} {
+			target := *redirect
+			target.RawQuery = query
+			if query == "" {
+				target.Path = "/favicon.ico"
+			}
+
+			resp, err := http.Get(target.String())
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			statuses = append(statuses, resp.StatusCode)
+		}
+
+		return nil
+	}
+
+	login := LoopbackLogin(open, nil, 10*time.Second)
+	token, err := login(context.Background(), conf, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "access-token" {
+		t.Errorf("AccessToken = %q, want access-token", token.AccessToken)
+	}
+
+	want := []int{http.StatusBadRequest, http.StatusBadRequest, http.StatusOK}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("statuses = %v, want %v", statuses, want)
+	}
+}
@@ -17,42 +17,88 @@ limitations under the License.
 package msgraph4go
 
 import (
-	"encoding/json"
+	"context"
+	"io"
 	"net/url"
 )
 
 // ListMessages gets all the messages in the current users mailbox.
 func (c *MSGraphClient) ListMyMessages(query url.Values) (response MessageCollection, err error) {
-	body, err := c.Get("/me/messages", query)
+	return c.ListMyMessagesWithContext(context.Background(), query)
+}
+
+// ListMyMessagesWithContext is like ListMyMessages, but uses ctx for the request.
+func (c *MSGraphClient) ListMyMessagesWithContext(ctx context.Context, query url.Values) (response MessageCollection, err error) {
+	body, err := c.GetWithContext(ctx, "/me/messages", query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
 
+// ListMyMessagesPager returns a Pager for the Message items of the collection returned by ListMyMessages.
+func (c *MSGraphClient) ListMyMessagesPager(query url.Values) *Pager {
+	return c.NewPager("/me/messages", query)
+}
+
 // ListMessagesInFolder gets all the messages in a folder for the current user.
 func (c *MSGraphClient) ListMyMessagesInFolder(folder string, query url.Values) (response MessageCollection, err error) {
-	body, err := c.Get("/me/mailFolders/"+folder+"/messages", query)
+	return c.ListMyMessagesInFolderWithContext(context.Background(), folder, query)
+}
+
+// ListMyMessagesInFolderWithContext is like ListMyMessagesInFolder, but uses ctx for the request.
+func (c *MSGraphClient) ListMyMessagesInFolderWithContext(ctx context.Context, folder string, query url.Values) (response MessageCollection, err error) {
+	body, err := c.GetWithContext(ctx, "/me/mailFolders/"+folder+"/messages", query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
 
+// ListMyMessagesInFolderPager returns a Pager for the Message items of the collection returned by ListMyMessagesInFolder.
+func (c *MSGraphClient) ListMyMessagesInFolderPager(folder string, query url.Values) *Pager {
+	return c.NewPager("/me/mailFolders/"+folder+"/messages", query)
+}
+
 // GetMyMessageByID gets the message for the specified ID for the current user
 func (c *MSGraphClient) GetMyMessageByID(messageID string, query url.Values) (response Message, err error) {
-	body, err := c.Get("/me/messages/"+messageID, query)
+	return c.GetMyMessageByIDWithContext(context.Background(), messageID, query)
+}
+
+// GetMyMessageByIDWithContext is like GetMyMessageByID, but uses ctx for the request.
+func (c *MSGraphClient) GetMyMessageByIDWithContext(ctx context.Context, messageID string, query url.Values) (response Message, err error) {
+	body, err := c.GetWithContext(ctx, "/me/messages/"+messageID, query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
+
+// SendMyMail sends the message specified in data, the JSON body of a
+// sendMail request, e.g. {"message": {...}, "saveToSentItems": true}.
+//
+// See https://docs.microsoft.com/en-us/graph/api/user-sendmail for more information.
+func (c *MSGraphClient) SendMyMail(data io.Reader) (err error) {
+	return c.SendMyMailWithContext(context.Background(), data)
+}
+
+// SendMyMailWithContext is like SendMyMail, but uses ctx for the request.
+func (c *MSGraphClient) SendMyMailWithContext(ctx context.Context, data io.Reader) (err error) {
+	_, err = c.PostWithContext(ctx, "/me/sendMail", nil, data)
+	return err
+}
+
+// MyMessagesInFolderDelta returns a Delta that tracks changes to the messages in a folder for the current user.
+// Change.Item is a *Message.
+func (c *MSGraphClient) MyMessagesInFolderDelta(folder string, query url.Values, store CheckpointStore) *Delta {
+	return c.NewDelta("/me/mailFolders/"+folder+"/messages/delta", query, store, func() interface{} { return &Message{} })
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Middleware wraps the http.RoundTripper next, e.g. to add headers, log,
+// or measure requests, returning a http.RoundTripper that calls next to
+// send the request.
+//
+// Like any http.RoundTripper, the returned RoundTripper must not modify
+// the request, so use req.Clone to change headers.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to allow the use of a function as a http.RoundTripper.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req).
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// WithMiddleware appends middleware to the chain of Middleware used by the
+// client, in order, so the first Middleware receives the request first.
+//
+// The chain is applied to every request to the Graph API, after retries
+// and after the Authorization header is added, so each attempt of a
+// retried request passes through the chain. Requests to the token endpoint
+// are not sent through the chain.
+//
+// For a client created with NewWithHTTPClient, the chain is applied before
+// the transport of the provided http.Client, so the Authorization header
+// may not be set yet.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// chain returns base wrapped by the middleware, with the first Middleware outermost.
+func (o *options) chain(base http.RoundTripper) http.RoundTripper {
+	transport := base
+	for n := len(o.middleware) - 1; n >= 0; n-- {
+		transport = o.middleware[n](transport)
+	}
+
+	return transport
+}
+
+// authorizedClient returns a client that sends requests using the HTTP
+// client of ctx, if any, authorized with tokens from tokenSource.
+//
+// Unlike oauth2.NewClient, the middleware is applied between the
+// authorization and the base transport, so it sees the Authorization header.
+func (o *options) authorizedClient(ctx context.Context, tokenSource oauth2.TokenSource) *http.Client {
+	client := http.Client{}
+	if c, ok := ctx.Value(oauth2.HTTPClient).(*http.Client); ok && c != nil {
+		client = *c
+	}
+
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	// the cache and the authorization share the token
+	source := oauth2.ReuseTokenSource(nil, tokenSource)
+
+	client.Transport = o.cached(newRetryTransport(&oauth2.Transport{
+		Source: source,
+		Base:   o.chain(transport),
+	}, o.retryPolicy, o.logger), source)
+
+	return &client
+}
+
+// UserAgent returns Middleware that sets the User-Agent header of each request to userAgent.
+func UserAgent(userAgent string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("User-Agent", userAgent)
+
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// ClientRequestID returns Middleware that sets the client-request-id header
+// of each request, unless already set, to a random UUID. The Graph API
+// returns the client-request-id in the response, and it is included in a
+// GraphErrorResponse, so the request can be found in logs on both sides.
+func ClientRequestID() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("client-request-id") != "" {
+				return next.RoundTrip(req)
+			}
+
+			id, err := newUUID()
+			if err != nil {
+				return nil, err
+			}
+
+			req = req.Clone(req.Context())
+			req.Header.Set("client-request-id", id)
+
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // variant 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// bearerToken matches a bearer token, such as in an Authorization header.
+var bearerToken = regexp.MustCompile(`(?i)(bearer\s+)[^\s,]+`)
+
+// sensitiveHeaders are headers whose values are not logged.
+var sensitiveHeaders = map[string]bool{
+	"Cookie":     true,
+	"Set-Cookie": true,
+}
+
+// redactHeader returns a copy of header with tokens and cookies redacted.
+func redactHeader(header http.Header) http.Header {
+	redacted := make(http.Header, len(header))
+	for k, values := range header {
+		for _, v := range values {
+			switch {
+			case sensitiveHeaders[http.CanonicalHeaderKey(k)]:
+				v = "[REDACTED]"
+			case http.CanonicalHeaderKey(k) == "Authorization" && !bearerToken.MatchString(v):
+				v = "[REDACTED]"
+			default:
+				v = bearerToken.ReplaceAllString(v, "${1}[REDACTED]")
+			}
+			redacted[k] = append(redacted[k], v)
+		}
+	}
+
+	return redacted
+}
+
+// Logging returns Middleware that logs each request and response to
+// logger, including the headers, but not the bodies. Bearer tokens,
+// other Authorization values, and cookies are redacted.
+func Logging(logger Logger) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			logger.Printf("--> %s %s %v", req.Method, req.URL.Redacted(), redactHeader(req.Header))
+
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			elapsed := time.Since(start)
+
+			if err != nil {
+				logger.Printf("<-- %s %s failed after %v: %v", req.Method, req.URL.Redacted(), elapsed, err)
+				return resp, err
+			}
+
+			logger.Printf("<-- %s %s %s (%v) %v", req.Method, req.URL.Redacted(), resp.Status, elapsed,
+				redactHeader(resp.Header))
+
+			return resp, nil
+		})
+	}
+}
+
+// Timing returns Middleware that calls observe after each request with
+// the response, or error, and the time taken until the response headers
+// were received, e.g. to record metrics.
+func Timing(observe func(req *http.Request, resp *http.Response, err error, elapsed time.Duration)) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			observe(req, resp, err, time.Since(start))
+
+			return resp, err
+		})
+	}
+}
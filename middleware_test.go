@@ -0,0 +1,240 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// eventRecorder records the events of a request as it passes through the transports.
+type eventRecorder struct {
+	events []string
+}
+
+// middleware returns Middleware named name that records the request and
+// response, and adds a X-Name header to both.
+func (e *eventRecorder) middleware(name string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			e.events = append(e.events, fmt.Sprintf("%s> auth=%v seen=%s",
+				name, req.Header.Get("Authorization") != "", strings.Join(req.Header.Values("X-Name"), ",")))
+
+			req = req.Clone(req.Context())
+			req.Header.Add("X-Name", name)
+
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+
+			e.events = append(e.events, fmt.Sprintf("<%s %d seen=%s",
+				name, resp.StatusCode, strings.Join(resp.Header.Values("X-Name"), ",")))
+			resp.Header.Add("X-Name", name)
+
+			return resp, nil
+		})
+	}
+}
+
+// base returns a transport that records each request, responding with
+// an empty JSON object and the statuses in order.
+func (e *eventRecorder) base(statuses ...int) http.RoundTripper {
+	return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		e.events = append(e.events, fmt.Sprintf("base auth=%s seen=%s",
+			req.Header.Get("Authorization"), strings.Join(req.Header.Values("X-Name"), ",")))
+
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(statuses[0])
+		w.WriteString("{}")
+		if len(statuses) > 1 {
+			statuses = statuses[1:]
+		}
+
+		return w.Result(), nil
+	})
+}
+
+// checkEvents fails the test unless the recorded events are want.
+func (e *eventRecorder) checkEvents(t *testing.T, want ...string) {
+	t.Helper()
+
+	if strings.Join(e.events, "\n") != strings.Join(want, "\n") {
+		t.Errorf("events:\n%s\nwant:\n%s", strings.Join(e.events, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+// testRetryPolicy retries quickly.
+var testRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+
+func TestMiddlewareOrder(t *testing.T) {
+	var e eventRecorder
+
+	o := newOptions([]Option{
+		WithMiddleware(e.middleware("a"), e.middleware("b")),
+		WithRetryPolicy(testRetryPolicy),
+	})
+
+	// the HTTP client of the context is the base transport
+	ctx := context.WithValue(context.Background(), oauth2.HTTPClient,
+		&http.Client{Transport: e.base(http.StatusServiceUnavailable, http.StatusOK)})
+	client := o.authorizedClient(ctx, oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"}))
+
+	req := httptest.NewRequest(http.MethodGet, "https://graph.microsoft.com/v1.0/me", nil)
+	req.RequestURI = ""
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	// the first Middleware is outermost, after the Authorization header is
+	// added, and each attempt of the retried request passes through the chain
+	e.checkEvents(t,
+		"a> auth=true seen=",
+		"b> auth=true seen=a",
+		"base auth=Bearer token seen=a,b",
+		"<b 503 seen=",
+		"<a 503 seen=b",
+		"a> auth=true seen=",
+		"b> auth=true seen=a",
+		"base auth=Bearer token seen=a,b",
+		"<b 200 seen=",
+		"<a 200 seen=b",
+	)
+
+	// the response is changed by the middleware, but the request isn't
+	if got := strings.Join(resp.Header.Values("X-Name"), ","); got != "b,a" {
+		t.Errorf("response X-Name = %q, want b,a", got)
+	}
+	if got := req.Header.Values("X-Name"); len(got) != 0 {
+		t.Errorf("request X-Name = %q, want none", got)
+	}
+}
+
+func TestMiddlewareOrderWithHTTPClient(t *testing.T) {
+	var e eventRecorder
+
+	// the transport of the HTTP client adds the Authorization header after the chain
+	authorize := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer caller")
+			return next.RoundTrip(req)
+		})
+	}
+
+	c := NewWithHTTPClient(&http.Client{Transport: authorize(e.base(http.StatusTooManyRequests, http.StatusOK))},
+		WithMiddleware(e.middleware("a")),
+		WithRetryPolicy(testRetryPolicy),
+		WithCache(NewMemoryCache(0), time.Hour))
+
+	for n := 0; n < 2; n++ {
+		if _, err := c.Get("/me", nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the second request is a cache hit, before the retries and the chain,
+	// so it doesn't pass through the chain
+	e.checkEvents(t,
+		"a> auth=false seen=",
+		"base auth=Bearer caller seen=a",
+		"<a 429 seen=",
+		"a> auth=false seen=",
+		"base auth=Bearer caller seen=a",
+		"<a 200 seen=",
+	)
+}
+
+func TestUserAgentAndClientRequestID(t *testing.T) {
+	var got []*http.Request
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		got = append(got, req)
+		return httptest.NewRecorder().Result(), nil
+	})
+
+	transport := UserAgent("test/1.0")(ClientRequestID()(base))
+
+	req := httptest.NewRequest(http.MethodGet, "https://graph.microsoft.com/v1.0/me", nil)
+	transport.RoundTrip(req)
+
+	req.Header.Set("client-request-id", "caller-id")
+	transport.RoundTrip(req)
+
+	if ua := got[0].Header.Get("User-Agent"); ua != "test/1.0" {
+		t.Errorf("User-Agent = %q, want test/1.0", ua)
+	}
+	if id := got[0].Header.Get("client-request-id"); len(id) != 36 || id[14] != '4' {
+		t.Errorf("client-request-id = %q, want a version 4 UUID", id)
+	}
+	if id := got[1].Header.Get("client-request-id"); id != "caller-id" {
+		t.Errorf("client-request-id = %q, want caller-id", id)
+	}
+	if req.Header.Get("User-Agent") != "" {
+		t.Error("User-Agent set on the request of the caller")
+	}
+}
+
+// logRecorder is a Logger that records the messages.
+type logRecorder struct {
+	messages []string
+}
+
+func (l *logRecorder) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func TestLogging(t *testing.T) {
+	var logger logRecorder
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.WriteHeader(http.StatusNotFound)
+		return w.Result(), nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "https://graph.microsoft.com/v1.0/me", nil)
+	req.Header.Set("Authorization", "Bearer secret-token")
+
+	resp, err := Logging(&logger)(base).RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ioutil.ReadAll(resp.Body)
+
+	if len(logger.messages) != 2 {
+		t.Fatalf("got %d messages, want 2", len(logger.messages))
+	}
+	for _, message := range logger.messages {
+		if strings.Contains(message, "secret") {
+			t.Errorf("message %q isn't redacted", message)
+		}
+	}
+	if !strings.Contains(logger.messages[0], "Bearer [REDACTED]") || !strings.Contains(logger.messages[1], "404") {
+		t.Errorf("messages = %q", logger.messages)
+	}
+}
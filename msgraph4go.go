@@ -19,12 +19,12 @@ limitations under the License.
 package msgraph4go
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -37,218 +37,359 @@ import (
 	"golang.org/x/oauth2"
 )
 
-const (
-	msGraphBase   = "https://graph.microsoft.com/v1.0"
-	msAuthBase    = "https://login.microsoftonline.com/common/oauth2/v2.0"
-	msAuthURL     = msAuthBase + "/authorize"
-	msTokenURL    = msAuthBase + "/token"
-	myRedirectURL = "https://login.microsoftonline.com/common/oauth2/nativeclient"
-)
-
-// init sets default logging flags
-func init() {
-	// log with date, time, file name, and line number
-	log.SetFlags(log.Lshortfile)
-}
-
+// GetFile executes a GET request for urlString and writes the response body to filepath.
+//
+// The file is only created if the request succeeds. If the body can't be
+// read completely, then the partial file is removed. If the request uses
+// IfNoneMatch and the file hasn't changed, the existing file is kept and
+// a *NotModifiedError is returned.
 func (c *MSGraphClient) GetFile(urlString string, filepath string) (err error) {
+	return c.GetFileWithContext(context.Background(), urlString, filepath)
+}
 
-	// parse the URL string
-	url, err := url.Parse(urlString)
+// GetFileWithContext is like GetFile, but uses ctx for the request.
+func (c *MSGraphClient) GetFileWithContext(ctx context.Context, urlString string, filepath string) (err error) {
+	stream, err := c.GetStreamWithContext(ctx, urlString, nil)
 	if err != nil {
 		return err
 	}
+	defer stream.Close()
 
-	// execute the request
-	resp, err := c.httpClient.Get(url.String())
-	if err != nil {
-		return err
+	// keep the existing file if it hasn't changed
+	if stream.NotModified {
+		return notModified(stream.Header)
 	}
-	defer resp.Body.Close()
 
 	file, err := os.Create(filepath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
 	// write the body to the file
-	_, err = io.Copy(file, resp.Body)
+	_, err = io.Copy(file, stream)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
+		os.Remove(filepath)
 		return err
 	}
 
-	return err
+	return nil
 }
 
 // Get executes the MS Graph API call, returning the response body.
 //
+// urlString is either relative to the Graph API base URL, e.g. "/me", or
+// an absolute URL, e.g. an @odata.nextLink.
+//
 // Query parmeters can be included to specify and control the amount of data returned in a response.
 //
 // Exact query parameters varies from one API operation to another.
 //
 // More information can be found at https://docs.microsoft.com/en-us/graph/query-parameters
 func (c *MSGraphClient) Get(urlString string, query url.Values) (body []byte, err error) {
+	return c.GetWithContext(context.Background(), urlString, query)
+}
 
-	// parse the URL string
-	url, err := url.Parse(msGraphBase + urlString)
-	if err != nil {
-		return body, err
-	}
+// GetWithContext is like Get, but uses ctx for the request.
+func (c *MSGraphClient) GetWithContext(ctx context.Context, urlString string, query url.Values) (body []byte, err error) {
+	return c.send(ctx, http.MethodGet, urlString, query, nil, "")
+}
 
-	// add the query parameters to the URL
-	url.RawQuery = query.Encode()
+// Put executes the MS Graph API call, returning the response body.
+//
+// Query parmeters can be included to specify and control the amount of data returned in a response.
+//
+// Exact query parameters varies from one API operation to another.
+//
+// More information can be found at https://docs.microsoft.com/en-us/graph/query-parameters
+func (c *MSGraphClient) Put(urlString string, query url.Values, data io.Reader) (body []byte, err error) {
+	return c.PutWithContext(context.Background(), urlString, query, data)
+}
 
-	//fmt.Println("DEBUG:", url.String())
-	// execute the request
-	resp, err := c.httpClient.Get(url.String())
+// PutWithContext is like Put, but uses ctx for the request.
+func (c *MSGraphClient) PutWithContext(ctx context.Context, urlString string, query url.Values, data io.Reader) (body []byte, err error) {
+	return c.send(ctx, http.MethodPut, urlString, query, data, "")
+}
+
+// Patch executes the MS Graph API call, returning the response body.
+//
+// Query parmeters can be included to specify and control the amount of data returned in a response.
+//
+// Exact query parameters varies from one API operation to another.
+//
+// More information can be found at https://docs.microsoft.com/en-us/graph/query-parameters
+func (c *MSGraphClient) Patch(urlString string, query url.Values, data io.Reader) (body []byte, err error) {
+	return c.PatchWithContext(context.Background(), urlString, query, data)
+}
+
+// PatchWithContext is like Patch, but uses ctx for the request.
+func (c *MSGraphClient) PatchWithContext(ctx context.Context, urlString string, query url.Values, data io.Reader) (body []byte, err error) {
+	return c.send(ctx, http.MethodPatch, urlString, query, data, "application/json")
+}
+
+// Post executes the MS Graph API call with JSON data, returning the response body.
+//
+// The body is empty for requests that return 202 Accepted or 204 No Content.
+// Use Do to send other types of content or to get the Location header.
+func (c *MSGraphClient) Post(urlString string, query url.Values, data io.Reader) (body []byte, err error) {
+	return c.PostWithContext(context.Background(), urlString, query, data)
+}
+
+// PostWithContext is like Post, but uses ctx for the request.
+func (c *MSGraphClient) PostWithContext(ctx context.Context, urlString string, query url.Values, data io.Reader) (body []byte, err error) {
+	return c.send(ctx, http.MethodPost, urlString, query, data, "application/json")
+}
+
+// Delete executes the MS Graph API call to delete the resource at urlString.
+func (c *MSGraphClient) Delete(urlString string, query url.Values) (err error) {
+	return c.DeleteWithContext(context.Background(), urlString, query)
+}
+
+// DeleteWithContext is like Delete, but uses ctx for the request.
+func (c *MSGraphClient) DeleteWithContext(ctx context.Context, urlString string, query url.Values) (err error) {
+	_, err = c.send(ctx, http.MethodDelete, urlString, query, nil, "")
+	return err
+}
+
+// Response is the response to a request sent by Do.
+type Response struct {
+	// StatusCode is the HTTP status code, e.g. 200, 201 Created, 202 Accepted, or 204 No Content.
+	StatusCode int
+
+	// Header is the header of the response.
+	Header http.Header
+
+	// Body is the response body, which is empty for 202 Accepted and 204 No Content.
+	Body []byte
+
+	// Location is the Location header, if any, e.g. the URL of a created
+	// resource or of a monitor for a long running operation.
+	Location string
+
+	// NotModified is true for a 304 Not Modified response to a request
+	// using IfNoneMatch, which means the resource hasn't changed.
+	NotModified bool
+}
+
+// Decode unmarshals the JSON body of the response into v.
+// v is left unchanged if the body is empty.
+func (r *Response) Decode(v interface{}) error {
+	return decodeBody(r.Body, v)
+}
+
+// Do executes the MS Graph API call using method, returning the response.
+//
+// urlString is either relative to the Graph API base URL, e.g. "/me", or
+// an absolute URL. header is added to the request, e.g. to set the
+// Content-Type of body, which may be nil.
+//
+// Any response without a 2xx status code is returned as a *GraphErrorResponse,
+// except for 304 Not Modified, which sets NotModified of the Response
+// without an error, and 412 Precondition Failed, which is a *PreconditionFailedError, see IfNoneMatch and IfMatch.
+func (c *MSGraphClient) Do(method string, urlString string, query url.Values, header http.Header, body io.Reader) (*Response, error) {
+	return c.DoWithContext(context.Background(), method, urlString, query, header, body)
+}
+
+// DoWithContext is like Do, but uses ctx for the request.
+func (c *MSGraphClient) DoWithContext(ctx context.Context, method string, urlString string, query url.Values, header http.Header, body io.Reader) (*Response, error) {
+	resp, err := c.do(ctx, method, urlString, query, header, body)
 	if err != nil {
-		return body, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	// read the body
-	body, err = ioutil.ReadAll(resp.Body)
+	data, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return body, err
+		return nil, err
 	}
 
-	// check if a MS Graph error occured and return a GraphErrorResponse
-	if codeIsError(resp.StatusCode) {
-		resError := GraphErrorResponse{}
-
-		err = json.Unmarshal(body, &resError)
-		if err != nil {
-			return nil, err
-		}
+	response := &Response{
+		StatusCode:  resp.StatusCode,
+		Header:      resp.Header,
+		Body:        data,
+		NotModified: resp.StatusCode == http.StatusNotModified,
+	}
 
-		return nil, &resError
+	if location, err := resp.Location(); err == nil {
+		response.Location = location.String()
 	}
 
-	return body, err
+	return response, nil
 }
 
-// Put executes the MS Graph API call, returning the response body.
-//
-// Query parmeters can be included to specify and control the amount of data returned in a response.
-//
-// Exact query parameters varies from one API operation to another.
+// do executes the MS Graph API call using method, returning the response
+// with the body unread. The caller must close the body.
 //
-// More information can be found at https://docs.microsoft.com/en-us/graph/query-parameters
-func (c *MSGraphClient) Put(urlString string, query url.Values, data io.Reader) (body []byte, err error) {
+// Any response without a 2xx status code is returned as a *GraphErrorResponse.
+func (c *MSGraphClient) do(ctx context.Context, method string, urlString string, query url.Values, header http.Header, body io.Reader) (*http.Response, error) {
 
 	// parse the URL string
-	url, err := url.Parse(msGraphBase + urlString)
+	url, err := c.parseURL(urlString)
 	if err != nil {
-		return body, err
+		return nil, err
 	}
 
 	// add the query parameters to the URL
-	url.RawQuery = query.Encode()
+	if len(query) > 0 {
+		url.RawQuery = query.Encode()
+	}
 
-	//fmt.Println("DEBUG:", url.String())
-	req, err := http.NewRequest(http.MethodPut, url.String(), data)
+	req, err := http.NewRequestWithContext(ctx, method, url.String(), body)
 	if err != nil {
-		return body, err
+		return nil, err
 	}
 
-	// execute the request
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		// handle error
-		return body, err
+	for k, v := range header {
+		req.Header[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
 	}
-	defer resp.Body.Close()
 
-	// read the body
-	body, err = ioutil.ReadAll(resp.Body)
+	// advanced queries of directory objects require eventual consistency
+	if req.Header.Get("ConsistencyLevel") == "" && needsEventualConsistency(url.Path, url.Query()) {
+		req.Header.Set("ConsistencyLevel", "eventual")
+	}
+
+	// If-Match or If-None-Match set by IfMatch or IfNoneMatch
+	setConditions(req)
+
+	// execute the request
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return body, err
+		return nil, err
 	}
 
-	// check if a MS Graph error occured and return a GraphErrorResponse
-	if codeIsError(resp.StatusCode) {
-		resError := GraphErrorResponse{}
+	// check if a MS Graph error occured and return a GraphErrorResponse,
+	// but a 304 Not Modified response to a conditional request isn't an error
+	if codeIsError(resp.StatusCode) && resp.StatusCode != http.StatusNotModified {
+		defer resp.Body.Close()
 
-		err = json.Unmarshal(body, &resError)
+		data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
 		if err != nil {
 			return nil, err
 		}
 
-		return nil, &resError
+		graphErr := newGraphError(resp.StatusCode, resp.Header, data)
+		if resp.StatusCode == http.StatusPreconditionFailed {
+			return nil, c.preconditionFailed(ctx, method, url, graphErr)
+		}
+
+		return nil, graphErr
 	}
 
-	return body, err
+	return resp, nil
 }
 
-// Patch executes the MS Graph API call, returning the response body.
-//
-// Query parmeters can be included to specify and control the amount of data returned in a response.
-//
-// Exact query parameters varies from one API operation to another.
-//
-// More information can be found at https://docs.microsoft.com/en-us/graph/query-parameters
-func (c *MSGraphClient) Patch(urlString string, query url.Values, data io.Reader) (body []byte, err error) {
+// parseURL parses urlString, which is either a path relative to the Graph
+// API base URL or an absolute URL such as an @odata.nextLink.
+func (c *MSGraphClient) parseURL(urlString string) (*url.URL, error) {
+	if strings.HasPrefix(urlString, "https://") || strings.HasPrefix(urlString, "http://") {
+		return c.parseAbsoluteURL(urlString)
+	}
 
-	// parse the URL string
-	url, err := url.Parse(msGraphBase + urlString)
+	urlString, err := c.resolveMe(urlString)
 	if err != nil {
-		return body, err
+		return nil, err
 	}
 
-	// add the query parameters to the URL
-	url.RawQuery = query.Encode()
+	return url.Parse(c.graphURL + urlString)
+}
 
-	//fmt.Println("DEBUG:", url.String())
-	req, err := http.NewRequest(http.MethodPatch, url.String(), data)
+// parseAbsoluteURL parses urlString, an absolute URL. The path of a URL
+// of the Graph API, such as an @odata.nextLink, is resolved like a
+// relative URL, so /me is rewritten or rejected the same way.
+func (c *MSGraphClient) parseAbsoluteURL(urlString string) (*url.URL, error) {
+	u, err := url.Parse(urlString)
 	if err != nil {
-		return body, err
+		return nil, err
 	}
 
-	req.Header.Add("Content-type", "application/json")
+	base, err := url.Parse(c.graphURL)
+	if err != nil || !strings.EqualFold(u.Host, base.Host) {
+		return u, nil
+	}
 
-	// execute the request
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		// handle error
-		return body, err
+	// the path starts with the API version, which may differ from the client's
+	path := u.EscapedPath()
+	n := strings.Index(strings.TrimPrefix(path, "/"), "/")
+	if n < 0 {
+		return u, nil
 	}
-	defer resp.Body.Close()
+	version, rest := path[:n+1], path[n+1:]
 
-	// read the body
-	body, err = ioutil.ReadAll(resp.Body)
+	resolved, err := c.resolveMe(rest)
+	if err != nil || resolved == rest {
+		return u, err
+	}
+
+	resolvedURL := u.Scheme + "://" + u.Host + version + resolved
+	if u.RawQuery != "" {
+		resolvedURL += "?" + u.RawQuery
+	}
+
+	return url.Parse(resolvedURL)
+}
+
+// send executes the MS Graph API call using method, returning the response body.
+//
+// A 304 Not Modified response is returned as a *NotModifiedError.
+//
+// If contentType is not empty, it is used as the Content-type of data.
+func (c *MSGraphClient) send(ctx context.Context, method string, urlString string, query url.Values, data io.Reader, contentType string) (body []byte, err error) {
+	var header http.Header
+	if contentType != "" {
+		header = http.Header{"Content-Type": {contentType}}
+	}
+
+	resp, err := c.DoWithContext(ctx, method, urlString, query, header, data)
 	if err != nil {
-		return body, err
+		return nil, err
 	}
 
-	// check if a MS Graph error occured and return a GraphErrorResponse
-	if codeIsError(resp.StatusCode) {
-		resError := GraphErrorResponse{}
+	// the resource isn't included in a 304 response
+	if resp.NotModified {
+		return nil, notModified(resp.Header)
+	}
 
-		err = json.Unmarshal(body, &resError)
-		if err != nil {
-			return nil, err
-		}
+	return resp.Body, nil
+}
 
-		return nil, &resError
+// decodeBody unmarshals the JSON body into v, unless the body is empty.
+func decodeBody(body []byte, v interface{}) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
 	}
 
-	return body, err
+	return json.Unmarshal(body, v)
 }
 
 // MSGraphClient is a client connection to the MS Graph API
 type MSGraphClient struct {
 	httpClient *http.Client
+
+	// base URL of the Graph API, including the version
+	graphURL string
+
+	// true if there is no signed in user, so /me can't be used
+	appOnly bool
+
+	// path of the user that replaces /me, set by AsUser
+	userPath string
 }
 
 // New creates an initialized MSGraphClient using the token from tokenFileName.
 //
 // If tokenFileName doesn't exist, then a token is requested and saved in the file.
+// When the token is refreshed, the new token is saved in the file.
+// WithTokenStore can be used to keep the token elsewhere, in which case
+// tokenFileName is ignored.
 //
-// The current approach assumes the client runs on a host without a
-// browser. The user is instructed to vist a URL to login and authorize the
-// client. Once the login is successful, the user must copy the response
-// URL and provide to the client program.
+// By default, the client is assumed to run on a host without a browser.
+// The user is instructed to vist a URL to login and authorize the client.
+// Once the login is successful, the user must copy the response URL and
+// provide to the client program. WithLogin can be used to select another
+// way to login, such as DeviceCodeLogin.
 //
 // The token is requested for offline access, which should include a refresh
 // token to allow access for a long period of time.
@@ -258,129 +399,131 @@ type MSGraphClient struct {
 //
 // scopes should include the permissions required to call the precding APIs.
 // See https://docs.microsoft.com/en-us/graph/permissions-reference for more information.
-func New(tokenFileName string, clientID string, scopes []string) *MSGraphClient {
+//
+// opts can be used to select the Graph API endpoint, authority host, tenant,
+// redirect URL, and token store.
+//
+// New calls log.Fatal if the client can't be created. Use NewClient to handle the error instead.
+func New(tokenFileName string, clientID string, scopes []string, opts ...Option) *MSGraphClient {
 	// default Context that is never canceled, has no values, and has no deadline
-	ctx := context.Background()
+	return NewWithContext(context.Background(), tokenFileName, clientID, scopes, opts...)
+}
+
+// NewWithContext is like New, but uses ctx for the token exchange and for
+// refreshing the token. ctx should not be canceled while the client is in use.
+func NewWithContext(ctx context.Context, tokenFileName string, clientID string, scopes []string, opts ...Option) *MSGraphClient {
+	client, err := NewClient(ctx, tokenFileName, clientID, scopes, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	scopes = append(scopes, "offline_access")
+	return client
+}
+
+// NewClient is like NewWithContext, but returns an error instead of calling log.Fatal.
+//
+// An expired token is refreshed when the client is created. If the user
+// must sign in again, or consent to new scopes, then the LoginFunc is used.
+// If interactive login is disabled using WithLogin(nil), then an error
+// matching ErrInteractiveLoginRequired or ErrConsentRequired is returned.
+//
+// Errors from the Microsoft identity platform are returned as an *AuthError.
+func NewClient(ctx context.Context, tokenFileName string, clientID string, scopes []string, opts ...Option) (*MSGraphClient, error) {
+	o := newOptions(opts)
+
+	// tokens are stored in tokenFileName unless another TokenStore is given
+	store := o.tokenStore
+	if store == nil {
+		store = NewFileTokenStore(tokenFileName)
+	}
+	key := TokenKey{ClientID: clientID, Scopes: scopes, Account: o.account}
+
+	scopes = append(append([]string{}, scopes...), "offline_access")
 
 	// OAuth2 configuration object
 	conf := &oauth2.Config{
 		ClientID: clientID,
 		Scopes:   scopes,
 		Endpoint: oauth2.Endpoint{
-			AuthURL:  msAuthURL,
-			TokenURL: msTokenURL,
+			AuthURL:  o.authURL(),
+			TokenURL: o.tokenURL(),
 		},
-		RedirectURL: myRedirectURL,
+		RedirectURL: o.redirect(),
 	}
 
-	client := &MSGraphClient{}
+	// try to get a token from the store
+	token, err := store.Load(key)
+	if err != nil && !errors.Is(err, ErrTokenNotFound) {
+		return nil, &TokenStoreError{Op: "load", Key: key, Err: err}
+	}
 
-	// try to get a token from the file
-	token, _ := readTokenFromFile(tokenFileName)
+	// refresh an expired token now, so a login can be requested if needed
+	var refreshErr error
+	if token != nil && !token.Valid() {
+		token, refreshErr = newStoreTokenSource(ctx, conf, store, key, token).Token()
+		if refreshErr != nil && !errors.Is(refreshErr, ErrInteractiveLoginRequired) &&
+			!errors.Is(refreshErr, ErrConsentRequired) {
+			return nil, refreshErr
+		}
+	}
 
 	// if token couldn't be retrived, then get a new token
 	if token == nil {
-		// generate random state to detect Cross-Site Request Forgery
-		state := randomBytesBase64(32)
-
-		// get authentication URL for offline access
-		authURL := conf.AuthCodeURL(
-			state,
-			oauth2.AccessTypeOffline,
-			oauth2.SetAuthURLParam("prompt", "consent"),
-		)
-
-		// instruct the user to vist the authentication URL
-		fmt.Println("Visit the following URL in a browser to authenticate this application")
-		fmt.Println("After authentication, copy the response URL from the browser")
-		fmt.Println(authURL)
-
-		// read the response URL
-		fmt.Println("Enter the response URL:")
-		responseString, err := bufio.NewReader(os.Stdin).ReadString('\n')
-		if err != nil {
-			log.Fatal(err)
+		if o.login == nil {
+			if refreshErr != nil {
+				return nil, refreshErr
+			}
+			return nil, ErrInteractiveLoginRequired
 		}
-		responseString = strings.TrimSpace(responseString)
 
-		// parse the response URL
-		responseURL, err := url.Parse(responseString)
+		token, err = o.login(ctx, conf, o.account)
 		if err != nil {
-			log.Fatal(err)
+			return nil, authError("exchange", err)
 		}
-		// get and compare state to prevent Cross-Site Request Forgery
-		responseState := responseURL.Query().Get("state")
-		if responseState != state {
-			log.Fatalln("state mismatch, potenial Cross-Site Request Forgery (CSRF)")
-		}
-
-		// get authorization code
-		code := responseURL.Query().Get("code")
 
-		// exchange authorize code for token
-		token, err = conf.Exchange(ctx, code)
+		// save the token to the store
+		err = store.Save(key, token)
 		if err != nil {
-			log.Fatal(err)
+			return nil, &TokenStoreError{Op: "save", Key: key, Err: err}
 		}
-
-		// save the token to a file
-		writeTokenToFile(tokenFileName, token)
 	}
 
-	// create HTTP client using the provided token
-	client.httpClient = conf.Client(ctx, token)
+	// create HTTP client using the provided token, saving the token when it is refreshed
+	tokenSource := newStoreTokenSource(ctx, conf, store, key, token)
 
-	return client
+	return &MSGraphClient{
+		httpClient: o.authorizedClient(ctx, tokenSource),
+		graphURL:   o.graphURL(),
+	}, nil
 }
 
-// randomBytesBase64 returns n bytes encoded in URL friendly base64.
-func randomBytesBase64(n int) string {
+// NewWithHTTPClient creates a MSGraphClient that sends requests using httpClient.
+//
+// httpClient is responsible for authorizing the requests, e.g. a client
+// returned by oauth2.Config.Client, or a plain client for a local test server.
+// httpClient is not modified; requests are sent using a copy of it.
+func NewWithHTTPClient(httpClient *http.Client, opts ...Option) *MSGraphClient {
+	o := newOptions(opts)
+
+	return &MSGraphClient{
+		httpClient: o.httpClient(httpClient),
+		graphURL:   o.graphURL(),
+	}
+}
+
+// randomBytesBase64 returns n random bytes encoded in URL friendly base64.
+func randomBytesBase64(n int) (string, error) {
 	// buffer to store n bytes
 	b := make([]byte, n)
 
 	// get b random bytes
 	_, err := rand.Read(b)
 	if err != nil {
-		log.Fatal(err)
+		return "", err
 	}
 
 	// convert to URL friendly base64
-	return base64.URLEncoding.EncodeToString(b)
-}
-
-// readTokenFromFile reads the json encoded token from a file.
-func readTokenFromFile(filename string) (*oauth2.Token, error) {
-	// open file
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer file.Close()
-
-	// read json encoded token
-	token := &oauth2.Token{}
-	err = json.NewDecoder(file).Decode(token)
-
-	return token, err
-}
-
-// writeTokenToFile writes a josn encoded token to a file.
-//
-// If file already exists, it is replaced.
-func writeTokenToFile(fileName string, token *oauth2.Token) {
-	// create file
-	file, err := os.Create(fileName)
-	if err != nil {
-		log.Fatal(err)
-	}
-	defer file.Close()
-
-	// write access token string
-	json.NewEncoder(file).Encode(token)
-
-	return
+	return base64.URLEncoding.EncodeToString(b), nil
 }
 
 func prettyPrintJson(src []byte) {
@@ -17,74 +17,134 @@ limitations under the License.
 package msgraph4go
 
 import (
-	"encoding/json"
+	"context"
 	"net/url"
 )
 
 // ListNotebooks retrives a list of Notebook objects
 func (c *MSGraphClient) ListNotebooks(query url.Values) (response NotebookCollection, err error) {
-	body, err := c.Get("/me/onenote/notebooks", query)
+	return c.ListNotebooksWithContext(context.Background(), query)
+}
+
+// ListNotebooksWithContext is like ListNotebooks, but uses ctx for the request.
+func (c *MSGraphClient) ListNotebooksWithContext(ctx context.Context, query url.Values) (response NotebookCollection, err error) {
+	body, err := c.GetWithContext(ctx, "/me/onenote/notebooks", query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
 
+// ListNotebooksPager returns a Pager for the Notebook items of the collection returned by ListNotebooks.
+func (c *MSGraphClient) ListNotebooksPager(query url.Values) *Pager {
+	return c.NewPager("/me/onenote/notebooks", query)
+}
+
 // ListPages retrives a list of Page objects
 func (c *MSGraphClient) ListPages(query url.Values) (response PageCollection, err error) {
-	body, err := c.Get("/me/onenote/pages", query)
+	return c.ListPagesWithContext(context.Background(), query)
+}
+
+// ListPagesWithContext is like ListPages, but uses ctx for the request.
+func (c *MSGraphClient) ListPagesWithContext(ctx context.Context, query url.Values) (response PageCollection, err error) {
+	body, err := c.GetWithContext(ctx, "/me/onenote/pages", query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
 
+// ListPagesPager returns a Pager for the Page items of the collection returned by ListPages.
+func (c *MSGraphClient) ListPagesPager(query url.Values) *Pager {
+	return c.NewPager("/me/onenote/pages", query)
+}
+
 // ListSectionPages retrieve a list of page objects from the specified section.
 func (c *MSGraphClient) ListSectionPages(sectionID string, query url.Values) (response PageCollection, err error) {
-	body, err := c.Get("/me/onenote/sections/"+sectionID+"/pages", query)
+	return c.ListSectionPagesWithContext(context.Background(), sectionID, query)
+}
+
+// ListSectionPagesWithContext is like ListSectionPages, but uses ctx for the request.
+func (c *MSGraphClient) ListSectionPagesWithContext(ctx context.Context, sectionID string, query url.Values) (response PageCollection, err error) {
+	body, err := c.GetWithContext(ctx, "/me/onenote/sections/"+sectionID+"/pages", query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
 
+// ListSectionPagesPager returns a Pager for the Page items of the collection returned by ListSectionPages.
+func (c *MSGraphClient) ListSectionPagesPager(sectionID string, query url.Values) *Pager {
+	return c.NewPager("/me/onenote/sections/"+sectionID+"/pages", query)
+}
+
 // ListSections retrives a list of Section objects
 func (c *MSGraphClient) ListSections(query url.Values) (response SectionResponse, err error) {
-	body, err := c.Get("/me/onenote/sections", query)
+	return c.ListSectionsWithContext(context.Background(), query)
+}
+
+// ListSectionsWithContext is like ListSections, but uses ctx for the request.
+func (c *MSGraphClient) ListSectionsWithContext(ctx context.Context, query url.Values) (response SectionResponse, err error) {
+	body, err := c.GetWithContext(ctx, "/me/onenote/sections", query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
 
+// ListSectionsPager returns a Pager for the Section items of the collection returned by ListSections.
+func (c *MSGraphClient) ListSectionsPager(query url.Values) *Pager {
+	return c.NewPager("/me/onenote/sections", query)
+}
+
 func (c *MSGraphClient) GetPage(id string, query url.Values) (response Page, err error) {
-	body, err := c.Get("/me/onenote/pages/"+id, query)
+	return c.GetPageWithContext(context.Background(), id, query)
+}
+
+// GetPageWithContext is like GetPage, but uses ctx for the request.
+func (c *MSGraphClient) GetPageWithContext(ctx context.Context, id string, query url.Values) (response Page, err error) {
+	body, err := c.GetWithContext(ctx, "/me/onenote/pages/"+id, query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
 
 func (c *MSGraphClient) GetPageContent(id string, query url.Values) (response string, err error) {
-	body, err := c.Get("/me/onenote/pages/"+id+"/content", query)
+	return c.GetPageContentWithContext(context.Background(), id, query)
+}
+
+// GetPageContentWithContext is like GetPageContent, but uses ctx for the request.
+func (c *MSGraphClient) GetPageContentWithContext(ctx context.Context, id string, query url.Values) (response string, err error) {
+	body, err := c.GetWithContext(ctx, "/me/onenote/pages/"+id+"/content", query)
 	if err != nil {
 		return response, err
 	}
 
 	return string(body), err
 }
+
+// GetPageContentStream is like GetPageContent, but returns the HTML content as a Stream.
+func (c *MSGraphClient) GetPageContentStream(id string, query url.Values) (stream *Stream, err error) {
+	return c.GetPageContentStreamWithContext(context.Background(), id, query)
+}
+
+// GetPageContentStreamWithContext is like GetPageContentStream, but uses ctx for the request.
+func (c *MSGraphClient) GetPageContentStreamWithContext(ctx context.Context, id string, query url.Values) (stream *Stream, err error) {
+	return c.GetStreamWithContext(ctx, "/me/onenote/pages/"+id+"/content", query)
+}
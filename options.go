@@ -0,0 +1,194 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Graph API root endpoints for the global service and the national clouds.
+// See https://docs.microsoft.com/en-us/graph/deployments for more information.
+const (
+	GraphGlobal   = "https://graph.microsoft.com"
+	GraphUSGov    = "https://graph.microsoft.us"
+	GraphUSGovDoD = "https://dod-graph.microsoft.us"
+	GraphChina    = "https://microsoftgraph.chinacloudapi.cn"
+	GraphGermany  = "https://graph.microsoft.de"
+)
+
+// Versions of the Graph API.
+const (
+	VersionV1   = "v1.0"
+	VersionBeta = "beta"
+)
+
+// Authority hosts of the Microsoft identity platform for the global service and the national clouds.
+const (
+	AuthorityGlobal  = "https://login.microsoftonline.com"
+	AuthorityUSGov   = "https://login.microsoftonline.us"
+	AuthorityChina   = "https://login.chinacloudapi.cn"
+	AuthorityGermany = "https://login.microsoftonline.de"
+)
+
+// Cloud is a Graph API root endpoint along with the authority host used to authenticate to it.
+type Cloud struct {
+	GraphRoot     string
+	AuthorityHost string
+}
+
+// Predefined clouds for use with WithCloud.
+var (
+	CloudGlobal   = Cloud{GraphRoot: GraphGlobal, AuthorityHost: AuthorityGlobal}
+	CloudUSGov    = Cloud{GraphRoot: GraphUSGov, AuthorityHost: AuthorityUSGov}
+	CloudUSGovDoD = Cloud{GraphRoot: GraphUSGovDoD, AuthorityHost: AuthorityUSGov}
+	CloudChina    = Cloud{GraphRoot: GraphChina, AuthorityHost: AuthorityChina}
+	CloudGermany  = Cloud{GraphRoot: GraphGermany, AuthorityHost: AuthorityGermany}
+)
+
+// defaultTenant allows both work or school and personal accounts to sign in.
+const defaultTenant = "common"
+
+// options contains the configurable settings of a MSGraphClient.
+type options struct {
+	graphRoot     string
+	apiVersion    string
+	authorityHost string
+	tenant        string
+	redirectURL   string
+	retryPolicy   RetryPolicy
+	tokenStore    TokenStore
+	account       string
+	login         LoginFunc
+	logger        Logger
+	middleware    []Middleware
+	cache         Cache
+	cacheTTL      time.Duration
+}
+
+// Option configures a MSGraphClient when it is created.
+type Option func(*options)
+
+// newOptions returns the default options with opts applied.
+func newOptions(opts []Option) *options {
+	o := &options{
+		graphRoot:     GraphGlobal,
+		apiVersion:    VersionV1,
+		authorityHost: AuthorityGlobal,
+		tenant:        defaultTenant,
+		retryPolicy:   DefaultRetryPolicy,
+		login:         PasteURLLogin(os.Stdin, os.Stdout),
+		logger:        nopLogger{},
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// graphURL returns the base URL of the Graph API, including the version.
+func (o *options) graphURL() string {
+	return strings.TrimSuffix(o.graphRoot, "/") + "/" + o.apiVersion
+}
+
+// authBase returns the base URL of the OAuth 2.0 endpoints for the tenant.
+func (o *options) authBase() string {
+	return strings.TrimSuffix(o.authorityHost, "/") + "/" + o.tenant + "/oauth2/v2.0"
+}
+
+// authURL returns the URL of the authorization endpoint.
+func (o *options) authURL() string {
+	return o.authBase() + "/authorize"
+}
+
+// tokenURL returns the URL of the token endpoint.
+func (o *options) tokenURL() string {
+	return o.authBase() + "/token"
+}
+
+// redirect returns the redirect URL, which defaults to the native client URL of the authority host.
+func (o *options) redirect() string {
+	if o.redirectURL != "" {
+		return o.redirectURL
+	}
+
+	return strings.TrimSuffix(o.authorityHost, "/") + "/common/oauth2/nativeclient"
+}
+
+// httpClient returns a copy of base that sends requests through the
+// transports configured by the options.
+func (o *options) httpClient(base *http.Client) *http.Client {
+	client := *base
+
+	transport := client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	client.Transport = o.cached(newRetryTransport(o.chain(transport), o.retryPolicy, o.logger), nil)
+
+	return &client
+}
+
+// WithCloud sets the Graph API root endpoint and authority host to those of cloud.
+func WithCloud(cloud Cloud) Option {
+	return func(o *options) {
+		o.graphRoot = cloud.GraphRoot
+		o.authorityHost = cloud.AuthorityHost
+	}
+}
+
+// WithGraphEndpoint sets the root endpoint of the Graph API, without the version,
+// e.g. GraphUSGov or the URL of a local test server.
+func WithGraphEndpoint(root string) Option {
+	return func(o *options) {
+		o.graphRoot = root
+	}
+}
+
+// WithAPIVersion sets the version of the Graph API, e.g. VersionV1 or VersionBeta.
+func WithAPIVersion(version string) Option {
+	return func(o *options) {
+		o.apiVersion = version
+	}
+}
+
+// WithAuthorityHost sets the host of the Microsoft identity platform, e.g. AuthorityChina.
+func WithAuthorityHost(host string) Option {
+	return func(o *options) {
+		o.authorityHost = host
+	}
+}
+
+// WithTenant sets the tenant used to sign in, which can be "common",
+// "organizations", "consumers", a tenant ID, or a domain name.
+func WithTenant(tenant string) Option {
+	return func(o *options) {
+		o.tenant = tenant
+	}
+}
+
+// WithRedirectURL sets the redirect URI registered for the application.
+func WithRedirectURL(redirectURL string) Option {
+	return func(o *options) {
+		o.redirectURL = redirectURL
+	}
+}
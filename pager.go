@@ -0,0 +1,386 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Pager iterates over a collection returned by the Graph API, following
+// the complete @odata.nextLink URL to request additional pages as needed.
+//
+// Items can be read one at a time with Next, all at once with All, or a
+// page at a time with NextPage. A Pager should not be used concurrently,
+// and NextPage should not be mixed with Next or All.
+//
+// Paging example:
+//
+//	pager := client.ListContactsPager(nil, "me")
+//	var contact msgraph4go.Contact
+//	for pager.Next(ctx, &contact) {
+//		fmt.Println(contact.DisplayName)
+//	}
+//	if err := pager.Err(); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// See https://docs.microsoft.com/en-us/graph/paging for more information.
+type Pager struct {
+	// MaxItems is the maximum number of items returned by the Pager.
+	// A value of 0 means there is no limit.
+	MaxItems int
+
+	c *MSGraphClient
+
+	// URL and query for the next page, an empty URL if no more pages
+	nextURL   string
+	nextQuery url.Values
+
+	// items of the current page not yet returned by Next
+	items []json.RawMessage
+
+	// number of items returned so far
+	count int
+
+	// filter set by Filter, evaluated locally if localFilter is true
+	filter      Expr
+	localFilter bool
+	fetched     bool
+
+	err error
+}
+
+// collectionPage is the common part of every collection response.
+type collectionPage struct {
+	ODataNextLink string            `json:"@odata.nextLink,omitempty"`
+	Value         []json.RawMessage `json:"value"`
+}
+
+// errStopped is used by Pager.Stop to end the iteration.
+var errStopped = errors.New("msgraph4go: pager stopped")
+
+// NewPager returns a Pager for the collection at urlString with the given query parameters.
+func (c *MSGraphClient) NewPager(urlString string, query url.Values) *Pager {
+	return &Pager{
+		c:         c,
+		nextURL:   urlString,
+		nextQuery: query,
+	}
+}
+
+// Filter sets the $filter of the first request to expr, replacing any
+// $filter of the query. It must be called before the first page is requested.
+//
+// If the Graph API rejects the filter, e.g. with 400 Bad Request for a
+// property that can't be filtered, then the request is sent without the
+// filter, and expr is evaluated locally for each item instead. Other
+// errors, such as an invalid $select, are returned as is. Items
+// that don't match are skipped by Next, All, and NextPage.
+func (p *Pager) Filter(expr Expr) *Pager {
+	query := url.Values{}
+	for k, v := range p.nextQuery {
+		query[k] = v
+	}
+	query.Set("$filter", expr.String())
+
+	p.nextQuery = query
+	p.filter = expr
+
+	return p
+}
+
+// fetch gets the next page of the collection, returning the response body.
+func (p *Pager) fetch(ctx context.Context) (body []byte, page collectionPage, err error) {
+	body, err = p.c.GetWithContext(ctx, p.nextURL, p.nextQuery)
+
+	// fall back to filtering locally if the filter is rejected by the first request
+	var graphErr *GraphErrorResponse
+	if err != nil && p.filter != nil && !p.fetched && errors.As(err, &graphErr) && unsupportedFilter(graphErr) {
+		p.localFilter = true
+
+		query := url.Values{}
+		for k, v := range p.nextQuery {
+			query[k] = v
+		}
+		query.Del("$filter")
+		p.nextQuery = query
+
+		body, err = p.c.GetWithContext(ctx, p.nextURL, p.nextQuery)
+	}
+	if err != nil {
+		return nil, page, err
+	}
+	p.fetched = true
+
+	err = decodeBody(body, &page)
+	if err != nil {
+		return nil, page, err
+	}
+
+	// the nextLink is a complete URL that includes the query parameters
+	p.nextURL = page.ODataNextLink
+	p.nextQuery = nil
+
+	return body, page, nil
+}
+
+// unsupportedFilterCodes are the error codes of responses rejecting a $filter.
+var unsupportedFilterCodes = map[string]bool{
+	"request_unsupportedquery":   true,
+	"errorinvalidurlqueryfilter": true,
+	"inefficientfilter":          true,
+}
+
+// unsupportedFilter returns true if err rejects the $filter of a request,
+// rather than another part of the request, such as $select or the path.
+func unsupportedFilter(err *GraphErrorResponse) bool {
+	if err.StatusCode != http.StatusBadRequest && err.StatusCode != http.StatusNotImplemented {
+		return false
+	}
+	if err.ODataError == nil {
+		return false
+	}
+
+	if unsupportedFilterCodes[strings.ToLower(err.ODataError.Code)] {
+		return true
+	}
+
+	// e.g. "Invalid filter clause" or "The property 'x' does not support filtering."
+	return strings.Contains(strings.ToLower(err.ODataError.Message), "filter")
+}
+
+// itemType returns the type of the items of page, a pointer to a collection
+// type such as *MessageCollection, or nil if it isn't known.
+func itemType(page interface{}) reflect.Type {
+	v := indirect(reflect.ValueOf(page))
+	if !v.IsValid() || v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	value := fieldByJSONName(v, "value")
+	if !value.IsValid() || value.Kind() != reflect.Slice {
+		return nil
+	}
+
+	return value.Type().Elem()
+}
+
+// filterPage returns body with only the items that match the filter, along with those items.
+//
+// Each item is decoded as typ, if not nil, before it is matched, so the
+// filter is evaluated the same way as by Next.
+func (p *Pager) filterPage(body []byte, items []json.RawMessage, typ reflect.Type) ([]byte, []json.RawMessage, error) {
+	matched := []json.RawMessage{}
+	for _, raw := range items {
+		var item interface{}
+		if typ != nil {
+			v := reflect.New(typ)
+			if err := json.Unmarshal(raw, v.Interface()); err != nil {
+				return nil, nil, err
+			}
+			item = v.Interface()
+		} else if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, nil, err
+		}
+
+		ok, err := p.filter.Match(item)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			matched = append(matched, raw)
+		}
+	}
+
+	body, err := replaceValue(body, matched)
+
+	return body, matched, err
+}
+
+// replaceValue returns body, a collection response, with value replaced by items.
+func replaceValue(body []byte, items []json.RawMessage) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(body, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	fields["value"], err = json.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(fields)
+}
+
+// limitReached returns true if MaxItems items have been returned.
+func (p *Pager) limitReached() bool {
+	return p.MaxItems > 0 && p.count >= p.MaxItems
+}
+
+// NextPage decodes the next page of the collection into page, which should
+// be a pointer to a collection type such as *DriveItemResponse.
+//
+// NextPage returns false, without modifying page, when there are no more
+// pages. If MaxItems is set, the page is truncated to the remaining items.
+func (p *Pager) NextPage(ctx context.Context, page interface{}) (bool, error) {
+	if p.err != nil {
+		if p.err == errStopped {
+			return false, nil
+		}
+		return false, p.err
+	}
+
+	if p.nextURL == "" || p.limitReached() {
+		return false, nil
+	}
+
+	body, raw, err := p.fetch(ctx)
+	if err != nil {
+		p.err = err
+		return false, err
+	}
+
+	if p.localFilter {
+		body, raw.Value, err = p.filterPage(body, raw.Value, itemType(page))
+		if err != nil {
+			p.err = err
+			return false, err
+		}
+	}
+
+	// the last page may have more items than remain before MaxItems
+	if remaining := p.MaxItems - p.count; p.MaxItems > 0 && len(raw.Value) > remaining {
+		raw.Value = raw.Value[:remaining]
+		body, err = replaceValue(body, raw.Value)
+		if err != nil {
+			p.err = err
+			return false, err
+		}
+	}
+
+	err = decodeBody(body, page)
+	if err != nil {
+		p.err = err
+		return false, err
+	}
+
+	p.count += len(raw.Value)
+
+	return true, nil
+}
+
+// Next decodes the next item of the collection into item, which should
+// be a pointer to an item type such as *DriveItem.
+//
+// Next returns false when there are no more items, MaxItems has been
+// reached, the Pager was stopped, or an error occurred. Err returns the error, if any.
+func (p *Pager) Next(ctx context.Context, item interface{}) bool {
+	if p.err != nil || p.limitReached() {
+		return false
+	}
+
+	for {
+		// get pages until an item is available, since a page may be empty
+		for len(p.items) == 0 {
+			if p.nextURL == "" {
+				return false
+			}
+
+			_, page, err := p.fetch(ctx)
+			if err != nil {
+				p.err = err
+				return false
+			}
+
+			p.items = page.Value
+		}
+
+		// clear item so fields omitted from this item don't keep previous values
+		if v := reflect.ValueOf(item); v.Kind() == reflect.Ptr && !v.IsNil() {
+			v.Elem().Set(reflect.Zero(v.Elem().Type()))
+		}
+
+		err := json.Unmarshal(p.items[0], item)
+		if err != nil {
+			p.err = err
+			return false
+		}
+		p.items = p.items[1:]
+
+		// skip items that don't match a filter that is evaluated locally
+		if p.localFilter {
+			ok, err := p.filter.Match(item)
+			if err != nil {
+				p.err = err
+				return false
+			}
+			if !ok {
+				continue
+			}
+		}
+
+		p.count++
+
+		return true
+	}
+}
+
+// All appends the remaining items of the collection to the slice pointed
+// to by items, which should be a pointer to a slice such as *[]DriveItem.
+func (p *Pager) All(ctx context.Context, items interface{}) error {
+	v := reflect.ValueOf(items)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Slice {
+		return errors.New("msgraph4go: items must be a pointer to a slice")
+	}
+
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+
+	for {
+		elem := reflect.New(elemType)
+		if !p.Next(ctx, elem.Interface()) {
+			break
+		}
+		slice.Set(reflect.Append(slice, elem.Elem()))
+	}
+
+	return p.Err()
+}
+
+// Stop ends the iteration. Subsequent calls to Next return false and no more pages are requested.
+func (p *Pager) Stop() {
+	if p.err == nil {
+		p.err = errStopped
+	}
+	p.items = nil
+}
+
+// Err returns the error, if any, that occurred while iterating.
+func (p *Pager) Err() error {
+	if p.err == errStopped {
+		return nil
+	}
+
+	return p.err
+}
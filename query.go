@@ -0,0 +1,397 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query builds the OData query parameters of a request, such as $select and $filter.
+//
+// The Values method returns url.Values that can be used with any method
+// that accepts a query, e.g.
+//
+//	query := msgraph4go.NewQuery().
+//		Select("subject", "from").
+//		Filterf("from/emailAddress/address eq %v", address).
+//		OrderBy("receivedDateTime desc").
+//		Top(10)
+//	messages, err := c.ListMyMessages(query.Values())
+//
+// See https://docs.microsoft.com/en-us/graph/query-parameters for more information.
+type Query struct {
+	selects []string
+	filter  string
+	orderBy []string
+	expands []expand
+	search  string
+	top     int
+	hasTop  bool
+	skip    int
+	count   bool
+}
+
+// expand is a navigation property to expand, along with its own query, if any.
+type expand struct {
+	name  string
+	query *Query
+}
+
+// NewQuery returns an empty Query.
+func NewQuery() *Query {
+	return &Query{}
+}
+
+// Select adds properties to $select.
+func (q *Query) Select(properties ...string) *Query {
+	q.selects = append(q.selects, properties...)
+	return q
+}
+
+// Filter sets $filter to filter, which is used as is.
+// Use Filterf to include string literals or other values safely.
+func (q *Query) Filter(filter string) *Query {
+	q.filter = filter
+	return q
+}
+
+// Filterf sets $filter using format, replacing each %v or %s with the
+// OData literal of the corresponding argument as returned by Literal, so
+// string values are quoted and escaped.
+//
+// Other verbs format numbers and booleans as usual, e.g. %d, %.2f, or %t.
+// A verb that doesn't apply to the argument, such as %d for a string, is
+// formatted as %!d(string) without the value, so it can't change the filter.
+func (q *Query) Filterf(format string, args ...interface{}) *Query {
+	literals := make([]interface{}, len(args))
+	for n, arg := range args {
+		literals[n] = literal{arg}
+	}
+
+	q.filter = fmt.Sprintf(format, literals...)
+	return q
+}
+
+// literal formats a value for Filterf as an OData literal.
+type literal struct {
+	value interface{}
+}
+
+// Format implements fmt.Formatter.
+func (l literal) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v', 's', 'q':
+		io.WriteString(f, Literal(l.value))
+		return
+	}
+
+	kind := reflect.Invalid
+	if l.value != nil {
+		kind = reflect.TypeOf(l.value).Kind()
+	}
+
+	var ok bool
+	switch verb {
+	case 't':
+		ok = kind == reflect.Bool
+	case 'd', 'b', 'o', 'x', 'X':
+		ok = kind >= reflect.Int && kind <= reflect.Uint64
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		ok = kind == reflect.Float32 || kind == reflect.Float64
+	}
+	if !ok {
+		fmt.Fprintf(f, "%%!%c(%T)", verb, l.value)
+		return
+	}
+
+	fmt.Fprintf(f, directive(f, verb), l.value)
+}
+
+// directive returns the formatting directive of f and verb, e.g. "%.2f".
+func directive(f fmt.State, verb rune) string {
+	var b strings.Builder
+	b.WriteByte('%')
+	for _, flag := range "+-# 0" {
+		if f.Flag(int(flag)) {
+			b.WriteRune(flag)
+		}
+	}
+	if width, ok := f.Width(); ok {
+		b.WriteString(strconv.Itoa(width))
+	}
+	if precision, ok := f.Precision(); ok {
+		b.WriteByte('.')
+		b.WriteString(strconv.Itoa(precision))
+	}
+	b.WriteRune(verb)
+
+	return b.String()
+}
+
+// FilterExpr sets $filter to expr, e.g. And(Eq("isRead", false), Contains("subject", "report")).
+func (q *Query) FilterExpr(expr Expr) *Query {
+	q.filter = expr.String()
+	return q
+}
+
+// OrderBy adds properties to $orderby, each optionally followed by " desc", e.g. "receivedDateTime desc".
+func (q *Query) OrderBy(properties ...string) *Query {
+	q.orderBy = append(q.orderBy, properties...)
+	return q
+}
+
+// Expand adds the navigation property to $expand. If query is not nil,
+// its options, such as $select, are applied to the expanded property.
+func (q *Query) Expand(property string, query *Query) *Query {
+	q.expands = append(q.expands, expand{name: property, query: query})
+	return q
+}
+
+// Search sets $search to term, which is quoted.
+func (q *Query) Search(term string) *Query {
+	q.search = `"` + strings.ReplaceAll(term, `"`, `\"`) + `"`
+	return q
+}
+
+// Top sets $top, the number of items to return in each page.
+func (q *Query) Top(n int) *Query {
+	q.top = n
+	q.hasTop = true
+	return q
+}
+
+// Skip sets $skip, the number of items to skip.
+func (q *Query) Skip(n int) *Query {
+	q.skip = n
+	return q
+}
+
+// Count sets $count, which includes the total number of items as @odata.count.
+func (q *Query) Count(count bool) *Query {
+	q.count = count
+	return q
+}
+
+// Values returns the query parameters.
+func (q *Query) Values() url.Values {
+	query := url.Values{}
+
+	for _, option := range q.options() {
+		query.Set(option[0], option[1])
+	}
+
+	return query
+}
+
+// String returns the query parameters in the form used within $expand,
+// e.g. "$select=id,name;$top=5".
+func (q *Query) String() string {
+	var options []string
+	for _, option := range q.options() {
+		options = append(options, option[0]+"="+option[1])
+	}
+
+	return strings.Join(options, ";")
+}
+
+// options returns the name and value of each query option that is set.
+func (q *Query) options() [][2]string {
+	var options [][2]string
+
+	if len(q.selects) > 0 {
+		options = append(options, [2]string{"$select", strings.Join(q.selects, ",")})
+	}
+
+	if q.filter != "" {
+		options = append(options, [2]string{"$filter", q.filter})
+	}
+
+	if len(q.orderBy) > 0 {
+		options = append(options, [2]string{"$orderby", strings.Join(q.orderBy, ",")})
+	}
+
+	if len(q.expands) > 0 {
+		expands := make([]string, len(q.expands))
+		for n, e := range q.expands {
+			expands[n] = e.name
+			if e.query != nil {
+				if nested := e.query.String(); nested != "" {
+					expands[n] += "(" + nested + ")"
+				}
+			}
+		}
+		options = append(options, [2]string{"$expand", strings.Join(expands, ",")})
+	}
+
+	if q.search != "" {
+		options = append(options, [2]string{"$search", q.search})
+	}
+
+	if q.hasTop {
+		options = append(options, [2]string{"$top", strconv.Itoa(q.top)})
+	}
+
+	if q.skip > 0 {
+		options = append(options, [2]string{"$skip", strconv.Itoa(q.skip)})
+	}
+
+	if q.count {
+		options = append(options, [2]string{"$count", "true"})
+	}
+
+	return options
+}
+
+// Literal returns v as an OData literal for use in a $filter.
+//
+// Strings are quoted, with single quotes doubled. time.Time values are
+// formatted in UTC using RFC 3339. nil is null. Booleans and numbers are
+// formatted as is. Other values are formatted as quoted strings.
+func Literal(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "'" + strings.ReplaceAll(v, "'", "''") + "'"
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case *time.Time:
+		if v == nil {
+			return "null"
+		}
+		return v.UTC().Format(time.RFC3339Nano)
+	case Timestamp:
+		return Literal(time.Time(v))
+	case *Timestamp:
+		if v == nil {
+			return "null"
+		}
+		return Literal(v.Time())
+	case bool:
+		return strconv.FormatBool(v)
+	case fmt.Stringer:
+		return Literal(v.String())
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'f', -1, 64)
+	case reflect.String:
+		return Literal(rv.String())
+	}
+
+	return Literal(fmt.Sprint(v))
+}
+
+// needsEventualConsistency returns true if query is an advanced query of
+// the directory objects at path, which requires the ConsistencyLevel
+// header to be "eventual". Other resources, such as messages, support
+// $count and $search without it.
+//
+// See https://docs.microsoft.com/en-us/graph/aad-advanced-queries for more information.
+func needsEventualConsistency(path string, query url.Values) bool {
+	if !isDirectoryPath(path) {
+		return false
+	}
+
+	if query.Get("$search") != "" || query.Get("$count") == "true" || strings.HasSuffix(path, "/$count") {
+		return true
+	}
+
+	filter := strings.ToLower(query.Get("$filter"))
+	for _, operator := range []string{" ne ", "not(", "not ", "endswith("} {
+		if strings.Contains(filter, operator) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// directoryCollections are the lowercase names of the collections and
+// relationships of directory objects, such as users and groups.
+var directoryCollections = map[string]bool{
+	"users":               true,
+	"groups":              true,
+	"devices":             true,
+	"applications":        true,
+	"serviceprincipals":   true,
+	"directoryobjects":    true,
+	"directoryroles":      true,
+	"administrativeunits": true,
+	"memberof":            true,
+	"transitivememberof":  true,
+	"members":             true,
+	"transitivemembers":   true,
+	"owners":              true,
+	"ownedobjects":        true,
+	"owneddevices":        true,
+	"registeredowners":    true,
+	"registeredusers":     true,
+	"registereddevices":   true,
+	"directreports":       true,
+	"createdobjects":      true,
+}
+
+// isDirectoryPath returns true if path, e.g. "/v1.0/users" or
+// "/me/memberOf/microsoft.graph.group", refers to directory objects rather
+// than to other resources, such as "/users/{id}/messages".
+func isDirectoryPath(path string) bool {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	// ignore the path of the API version
+	for n, segment := range segments {
+		if segment == "v1.0" || segment == "beta" {
+			segments = segments[n+1:]
+			break
+		}
+	}
+
+	directory := false
+	key := false // the next segment is the key of an item of a collection
+	for n, segment := range segments {
+		name := strings.ToLower(segment)
+		switch {
+		case key:
+			key = false
+		case name == "$count" || strings.HasPrefix(name, "microsoft.graph."):
+			// a count or a cast of the previous segment
+		case name == "me":
+			directory = true
+		case n == 0 && name == "contacts":
+			// organizational contacts, unlike /me/contacts
+			directory, key = true, true
+		case directoryCollections[name]:
+			directory, key = true, true
+		default:
+			directory = false
+		}
+	}
+
+	return directory
+}
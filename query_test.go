@@ -0,0 +1,149 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestLiteral(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{"Bill", "'Bill'"},
+		{"O'Brien", "'O''Brien'"},
+		{"''", "''''''"},
+		{"", "''"},
+		{"0b4c7c49-6b2d-4d0f-8b1e-2d6f3a7c9e10", "'0b4c7c49-6b2d-4d0f-8b1e-2d6f3a7c9e10'"},
+		{time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC), "2021-03-01T12:00:00Z"},
+		{time.Date(2021, 3, 1, 12, 0, 0, 500000000, time.FixedZone("EST", -5*60*60)), "2021-03-01T17:00:00.5Z"},
+		{NewTimestamp(time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)), "2021-03-01T12:00:00Z"},
+		{(*Timestamp)(nil), "null"},
+		{nil, "null"},
+		{true, "true"},
+		{42, "42"},
+		{uint8(7), "7"},
+		{2.5, "2.5"},
+		{time.Minute, "'1m0s'"},
+	}
+
+	for _, tt := range tests {
+		if got := Literal(tt.value); got != tt.want {
+			t.Errorf("Literal(%#v) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestFilterf(t *testing.T) {
+	tests := []struct {
+		format string
+		args   []interface{}
+		want   string
+	}{
+		{"displayName eq %v", []interface{}{"it's"}, "displayName eq 'it''s'"},
+		{"displayName eq %s", []interface{}{"it's"}, "displayName eq 'it''s'"},
+		{"displayName eq %q", []interface{}{"it's"}, "displayName eq 'it''s'"},
+		{"id eq %v", []interface{}{"0b4c7c49-6b2d-4d0f-8b1e-2d6f3a7c9e10"}, "id eq '0b4c7c49-6b2d-4d0f-8b1e-2d6f3a7c9e10'"},
+		{"receivedDateTime ge %v", []interface{}{time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)}, "receivedDateTime ge 2021-03-01T00:00:00Z"},
+		{"size gt %d", []interface{}{1024}, "size gt 1024"},
+		{"price lt %.2f", []interface{}{9.5}, "price lt 9.50"},
+		{"isRead eq %t", []interface{}{false}, "isRead eq false"},
+		{"isRead eq %v", []interface{}{false}, "isRead eq false"},
+		{"size gt %d", []interface{}{"1 or 1 eq 1"}, "size gt %!d(string)"},
+		{"isRead eq %t", []interface{}{"true) or (1 eq 1"}, "isRead eq %!t(string)"},
+	}
+
+	for _, tt := range tests {
+		if got := NewQuery().Filterf(tt.format, tt.args...).Values().Get("$filter"); got != tt.want {
+			t.Errorf("Filterf(%q, %v) = %q, want %q", tt.format, tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestQueryValues(t *testing.T) {
+	query := NewQuery().
+		Select("subject", "from").
+		FilterExpr(And(Eq("isRead", false), Eq("from/emailAddress/address", "o'brien@contoso.com"))).
+		OrderBy("receivedDateTime desc").
+		Expand("attachments", NewQuery().Select("name").Top(5)).
+		Top(10).
+		Skip(20).
+		Count(true)
+
+	want := url.Values{
+		"$select":  {"subject,from"},
+		"$filter":  {"isRead eq false and from/emailAddress/address eq 'o''brien@contoso.com'"},
+		"$orderby": {"receivedDateTime desc"},
+		"$expand":  {"attachments($select=name;$top=5)"},
+		"$top":     {"10"},
+		"$skip":    {"20"},
+		"$count":   {"true"},
+	}
+
+	got := query.Values()
+	if got.Encode() != want.Encode() {
+		t.Errorf("Values = %v, want %v", got, want)
+	}
+
+	if s := NewQuery().Search(`say "hi"`).Top(0).Values().Encode(); s != `%24search=%22say+%5C%22hi%5C%22%22&%24top=0` {
+		t.Errorf("Values = %s", s)
+	}
+
+	if s := NewQuery().String(); s != "" {
+		t.Errorf("String of empty query = %q, want empty", s)
+	}
+}
+
+func TestNeedsEventualConsistency(t *testing.T) {
+	tests := []struct {
+		path  string
+		query string
+		want  bool
+	}{
+		{"/v1.0/users", "$count=true", true},
+		{"/v1.0/users", "$search=%22displayName:bill%22", true},
+		{"/v1.0/users", "$filter=endswith(mail,'@contoso.com')", true},
+		{"/v1.0/users", "$filter=accountEnabled ne false", true},
+		{"/v1.0/users", "$filter=startswith(displayName,'b')", false},
+		{"/v1.0/users/$count", "", true},
+		{"/beta/groups/123/members/microsoft.graph.user", "$count=true", true},
+		{"/v1.0/me/memberOf", "$search=%22displayName:sales%22", true},
+		{"/v1.0/me/transitiveMemberOf/microsoft.graph.group/$count", "", true},
+		{"/v1.0/contacts", "$count=true", true},
+		{"/groups", "$count=true", true},
+
+		{"/v1.0/me/messages", "$search=%22report%22", false},
+		{"/v1.0/me/messages", "$count=true", false},
+		{"/v1.0/me/contacts", "$count=true", false},
+		{"/v1.0/users/123/messages", "$filter=isRead ne true", false},
+		{"/v1.0/users/123/events/$count", "", false},
+		{"/v1.0/me/drive/root/children", "$count=true", false},
+	}
+
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := needsEventualConsistency(tt.path, query); got != tt.want {
+			t.Errorf("needsEventualConsistency(%s, %s) = %v, want %v", tt.path, tt.query, got, tt.want)
+		}
+	}
+}
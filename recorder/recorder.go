@@ -0,0 +1,468 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package recorder provides a http.RoundTripper that records HTTP
+// interactions with the Graph API to a cassette file, and replays them,
+// so integration tests can run offline and without credentials.
+//
+// Interactions are redacted before they are saved, removing tokens and
+// replacing email addresses and IDs with consistent placeholders.
+//
+// Record the cassette once, with real credentials, using the recorder as
+// the transport of the HTTP client used by msgraph4go:
+//
+//	rec, err := recorder.New("testdata/drive.json", recorder.Options{Mode: recorder.ModeRecord})
+//	ctx = context.WithValue(ctx, oauth2.HTTPClient, rec.Client())
+//	client, err := msgraph4go.NewClient(ctx, ".token.json", clientID, scopes)
+//	...
+//	err = rec.Stop() // saves the cassette
+//
+// Then replay it, e.g. in CI, without credentials:
+//
+//	rec, err := recorder.New("testdata/drive.json", recorder.Options{Mode: recorder.ModeReplay})
+//	client := msgraph4go.NewWithHTTPClient(rec.Client(),
+//		msgraph4go.WithRetryPolicy(msgraph4go.RetryPolicy{MaxAttempts: 1}))
+//	...
+//	err = rec.Stop() // reports requests that didn't match
+//
+// A request that doesn't match fails with ErrNoMatch. Since this is
+// returned as a transport error, disable retries when replaying so the
+// test fails immediately.
+//
+// Since IDs are replaced in the cassette, a replayed test should use the
+// IDs returned by earlier requests, or the placeholders in the cassette.
+package recorder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// ErrNoMatch is returned by RoundTrip in ModeReplay for a request that
+// doesn't match any unused recorded interaction.
+var ErrNoMatch = errors.New("recorder: no recorded interaction matches the request")
+
+// Mode is the mode of a Recorder.
+type Mode int
+
+const (
+	// ModeReplay replays the interactions of an existing cassette, without
+	// sending any requests. This is the default mode.
+	ModeReplay Mode = iota
+
+	// ModeRecord sends requests using the Transport and records the
+	// interactions, replacing the cassette when the Recorder is stopped.
+	ModeRecord
+
+	// ModeAuto uses ModeReplay if the cassette exists, otherwise ModeRecord.
+	ModeAuto
+)
+
+// String returns the name of the mode.
+func (m Mode) String() string {
+	switch m {
+	case ModeReplay:
+		return "replay"
+	case ModeRecord:
+		return "record"
+	case ModeAuto:
+		return "auto"
+	}
+
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// Request is a recorded request.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body"`
+}
+
+// Body is a recorded request or response body. Text is stored as is,
+// while other content is stored base64 encoded.
+type Body struct {
+	Text   string `json:"text,omitempty"`
+	Base64 string `json:"base64,omitempty"`
+}
+
+// newBody returns a Body for data.
+func newBody(data []byte) Body {
+	if utf8.Valid(data) {
+		return Body{Text: string(data)}
+	}
+
+	return Body{Base64: base64.StdEncoding.EncodeToString(data)}
+}
+
+// Bytes returns the content of the body.
+func (b Body) Bytes() []byte {
+	if b.Base64 != "" {
+		data, _ := base64.StdEncoding.DecodeString(b.Base64)
+		return data
+	}
+
+	return []byte(b.Text)
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Cassette is the file format of the recorded interactions.
+type Cassette struct {
+	Version      int            `json:"version"`
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// cassetteVersion is the current version of the cassette file format.
+const cassetteVersion = 1
+
+// Matcher returns true if the request, with the body, matches the recorded request.
+type Matcher func(req *http.Request, body []byte, recorded Request) bool
+
+// DefaultMatcher matches requests by method, path, query, and body. The
+// query parameters are compared regardless of order, and JSON bodies are
+// compared regardless of formatting and the order of properties. The
+// scheme and host are ignored, so a cassette can be replayed using any endpoint.
+func DefaultMatcher(req *http.Request, body []byte, recorded Request) bool {
+	if req.Method != recorded.Method {
+		return false
+	}
+
+	u, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+
+	if req.URL.Path != u.Path || req.URL.Query().Encode() != u.Query().Encode() {
+		return false
+	}
+
+	return bytes.Equal(normalizeBody(body), normalizeBody(recorded.Body.Bytes()))
+}
+
+// normalizeBody returns JSON bodies in a canonical form, and other bodies as is.
+func normalizeBody(body []byte) []byte {
+	var v interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return body
+	}
+
+	normalized, err := json.Marshal(v)
+	if err != nil {
+		return body
+	}
+
+	return normalized
+}
+
+// Options configures a Recorder.
+type Options struct {
+	// Mode is the mode of the Recorder. The default is ModeReplay.
+	Mode Mode
+
+	// Transport sends the requests in ModeRecord, or http.DefaultTransport if nil.
+	Transport http.RoundTripper
+
+	// Redactors are applied to each interaction before it is saved, or
+	// DefaultRedactors if nil. Use an empty slice to disable redaction.
+	Redactors []Redactor
+
+	// Matcher matches requests with recorded requests in ModeReplay, or DefaultMatcher if nil.
+	Matcher Matcher
+}
+
+// Recorder is a http.RoundTripper that records or replays interactions.
+// Use New to create a Recorder, and Stop when finished.
+type Recorder struct {
+	fileName  string
+	mode      Mode
+	transport http.RoundTripper
+	redactors []Redactor
+	matcher   Matcher
+
+	mu        sync.Mutex
+	cassette  Cassette
+	used      []bool
+	unmatched []string
+}
+
+// New returns a Recorder for the cassette fileName.
+//
+// In ModeReplay, the cassette must exist.
+func New(fileName string, opts Options) (*Recorder, error) {
+	r := &Recorder{
+		fileName:  fileName,
+		mode:      opts.Mode,
+		transport: opts.Transport,
+		redactors: opts.Redactors,
+		matcher:   opts.Matcher,
+		cassette:  Cassette{Version: cassetteVersion},
+	}
+
+	if r.transport == nil {
+		r.transport = http.DefaultTransport
+	}
+	if r.redactors == nil {
+		r.redactors = DefaultRedactors()
+	}
+	if r.matcher == nil {
+		r.matcher = DefaultMatcher
+	}
+
+	if r.mode == ModeAuto {
+		r.mode = ModeRecord
+		if _, err := os.Stat(fileName); err == nil {
+			r.mode = ModeReplay
+		}
+	}
+
+	switch r.mode {
+	case ModeRecord:
+		return r, nil
+	case ModeReplay:
+	default:
+		return nil, fmt.Errorf("recorder: invalid mode %v", r.mode)
+	}
+
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("recorder: cassette %s not found, record it using ModeRecord: %w", fileName, err)
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &r.cassette)
+	if err != nil {
+		return nil, fmt.Errorf("recorder: reading cassette %s: %w", fileName, err)
+	}
+
+	if r.cassette.Version != cassetteVersion {
+		return nil, fmt.Errorf("recorder: cassette %s has unsupported version %d", fileName, r.cassette.Version)
+	}
+
+	r.used = make([]bool, len(r.cassette.Interactions))
+
+	return r, nil
+}
+
+// Mode returns the mode of the Recorder, which is ModeReplay or ModeRecord.
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Client returns a http.Client that sends requests using the Recorder.
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip records or replays the interaction for req.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.mode == ModeRecord {
+		return r.record(req, body)
+	}
+
+	return r.replay(req, body)
+}
+
+// readBody returns the body of req, leaving req.Body unread.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return body, nil
+}
+
+// record sends req and records the interaction.
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+
+	interaction := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   newBody(body),
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Body:       newBody(respBody),
+		},
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, redact := range r.redactors {
+		redact(interaction)
+	}
+
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+
+	// the caller receives the response as sent, not redacted
+	return resp, nil
+}
+
+// replay returns the response of the first unused interaction matching req.
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for n, interaction := range r.cassette.Interactions {
+		if r.used[n] || !r.matcher(req, body, interaction.Request) {
+			continue
+		}
+
+		r.used[n] = true
+
+		respBody := interaction.Response.Body.Bytes()
+		header := interaction.Response.Header.Clone()
+		if header == nil {
+			header = make(http.Header)
+		}
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(respBody)),
+			ContentLength: int64(len(respBody)),
+			Request:       req,
+		}, nil
+	}
+
+	description := req.Method + " " + req.URL.RequestURI()
+	if len(body) > 0 {
+		description += " with body " + truncate(string(body), 200)
+	}
+	// a retried request is only reported once
+	if len(r.unmatched) == 0 || r.unmatched[len(r.unmatched)-1] != description {
+		r.unmatched = append(r.unmatched, description)
+	}
+
+	return nil, fmt.Errorf("%w in %s: %s", ErrNoMatch, r.fileName, description)
+}
+
+// truncate returns s truncated to n bytes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return s[:n] + "..."
+}
+
+// Stop finishes recording or replaying.
+//
+// In ModeRecord, the cassette is saved. In ModeReplay, an error is returned
+// if any request didn't match, even if the error returned by RoundTrip was ignored.
+func (r *Recorder) Stop() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.mode == ModeReplay {
+		if len(r.unmatched) > 0 {
+			return fmt.Errorf("%w in %s: %s", ErrNoMatch, r.fileName, strings.Join(r.unmatched, "; "))
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(r.fileName), 0755)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so an existing cassette isn't lost if writing fails
+	tmp, err := ioutil.TempFile(filepath.Dir(r.fileName), filepath.Base(r.fileName)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmp.Write(append(data, '\n'))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), r.fileName)
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,269 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recorder_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bnixon67/msgraph4go"
+	"github.com/bnixon67/msgraph4go/recorder"
+)
+
+const (
+	userID       = "a1b2c3d4-e5f6-4a1b-8c2d-3e4f5a6b7c8d"
+	userMail     = "AdeleV@contoso.onmicrosoft.com"
+	bearerToken  = "Bearer secret-access-token"
+	authCode     = "secret-auth-code"
+	refreshToken = "secret-refresh-token"
+)
+
+// secrets are the values that must not be saved in a cassette.
+var secrets = []string{"secret-", userID, userMail}
+
+// newUpstream returns a server with a token endpoint and a few Graph API endpoints.
+func newUpstream(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/token":
+			r.ParseForm()
+			if r.PostForm.Get("refresh_token") != refreshToken {
+				http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{
+				"access_token":  "secret-new-access-token",
+				"refresh_token": "secret-new-refresh-token",
+				"token_type":    "Bearer",
+			})
+		case "/authorize":
+			json.NewEncoder(w).Encode(map[string]string{"state": r.URL.Query().Get("state")})
+		case "/v1.0/me":
+			json.NewEncoder(w).Encode(map[string]string{
+				"id":                userID,
+				"mail":              userMail,
+				"userPrincipalName": userMail,
+				"displayName":       "Adele Vance",
+			})
+		case "/v1.0/users/" + userID + "/messages":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"value": []map[string]string{{"id": "AAMkAGI2THVSAAA=", "subject": "Hello"}},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error":{"code":"itemNotFound","message":"Not found."}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// authorized returns a http.Client that adds an Authorization header to
+// each request, sending it using transport.
+func authorized(transport http.RoundTripper) *http.Client {
+	return &http.Client{Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", bearerToken)
+		return transport.RoundTrip(req)
+	})}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// newClient returns a MSGraphClient for endpoint that sends requests using
+// rec, without retries so an unmatched request fails immediately.
+func newClient(rec *recorder.Recorder, endpoint string) *msgraph4go.MSGraphClient {
+	return msgraph4go.NewWithHTTPClient(authorized(rec),
+		msgraph4go.WithGraphEndpoint(endpoint),
+		msgraph4go.WithRetryPolicy(msgraph4go.RetryPolicy{MaxAttempts: 1}))
+}
+
+// getMessages gets the profile of the signed in user, and then their
+// messages using the ID from the profile, returning the subject of the first message.
+func getMessages(t *testing.T, client *msgraph4go.MSGraphClient) (user msgraph4go.User, subject string) {
+	t.Helper()
+
+	user, err := client.GetMyProfile(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var messages msgraph4go.MessageCollection
+	body, err := client.Get("/users/"+user.ID+"/messages", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, &messages); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages.Value) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages.Value))
+	}
+
+	return user, messages.Value[0].Subject
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	upstream := newUpstream(t)
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := recorder.New(cassette, recorder.Options{Mode: recorder.ModeRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the authorization code flow and a refresh, as sent by oauth2
+	resp, err := authorized(rec).Get(upstream.URL + "/authorize?code=" + authCode + "&state=xyz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = authorized(rec).PostForm(upstream.URL+"/token", url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	// the caller receives the response as sent
+	if !strings.Contains(string(token), "secret-new-refresh-token") {
+		t.Errorf("token response = %s, want it unredacted", token)
+	}
+
+	recorded, _ := getMessages(t, newClient(rec, upstream.URL))
+	if recorded.ID != userID {
+		t.Errorf("recorded user ID = %q, want %q", recorded.ID, userID)
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(cassette)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, secret := range secrets {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %q:\n%s", secret, data)
+		}
+	}
+
+	var saved recorder.Cassette
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Interactions) != 4 {
+		t.Fatalf("cassette has %d interactions, want 4", len(saved.Interactions))
+	}
+	for _, i := range saved.Interactions {
+		if got := i.Request.Header.Get("Authorization"); got != "REDACTED" {
+			t.Errorf("%s Authorization = %q, want REDACTED", i.Request.URL, got)
+		}
+	}
+	if got := saved.Interactions[0].Request.URL; !strings.Contains(got, "code=REDACTED") {
+		t.Errorf("authorize URL = %q, want code=REDACTED", got)
+	}
+	if got := saved.Interactions[1].Request.Body.Text; !strings.Contains(got, "refresh_token=REDACTED") {
+		t.Errorf("token request = %q, want refresh_token=REDACTED", got)
+	}
+
+	// replay without the upstream server, using the placeholder IDs
+	upstream.Close()
+
+	rec, err = recorder.New(cassette, recorder.Options{Mode: recorder.ModeReplay})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replayed, subject := getMessages(t, newClient(rec, upstream.URL))
+	if replayed.ID == userID || replayed.ID == "" {
+		t.Errorf("replayed user ID = %q, want a placeholder", replayed.ID)
+	}
+	if subject != "Hello" {
+		t.Errorf("subject = %q, want Hello", subject)
+	}
+
+	if err := rec.Stop(); err != nil {
+		t.Errorf("Stop = %v, want nil", err)
+	}
+}
+
+func TestReplayNoMatch(t *testing.T) {
+	upstream := newUpstream(t)
+	cassette := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := recorder.New(cassette, recorder.Options{Mode: recorder.ModeRecord})
+	if err != nil {
+		t.Fatal(err)
+	}
+	getMessages(t, newClient(rec, upstream.URL))
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err = recorder.New(cassette, recorder.Options{Mode: recorder.ModeReplay})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newClient(rec, upstream.URL)
+
+	// the original ID was replaced in the cassette, so it no longer matches
+	if _, err := client.Get("/users/"+userID+"/messages", nil); !errors.Is(err, recorder.ErrNoMatch) {
+		t.Errorf("Get error = %v, want %v", err, recorder.ErrNoMatch)
+	}
+
+	// each interaction is replayed once
+	if _, err := client.GetMyProfile(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetMyProfile(nil); !errors.Is(err, recorder.ErrNoMatch) {
+		t.Errorf("second GetMyProfile error = %v, want %v", err, recorder.ErrNoMatch)
+	}
+
+	if err := rec.Stop(); !errors.Is(err, recorder.ErrNoMatch) {
+		t.Errorf("Stop = %v, want %v", err, recorder.ErrNoMatch)
+	}
+}
+
+func TestNewReplayMissingCassette(t *testing.T) {
+	_, err := recorder.New(filepath.Join(t.TempDir(), "missing.json"), recorder.Options{Mode: recorder.ModeReplay})
+	if err == nil {
+		t.Error("New succeeded for a missing cassette")
+	}
+}
@@ -0,0 +1,283 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// redacted replaces sensitive values.
+const redacted = "REDACTED"
+
+// Redactor removes sensitive data from an interaction before it is saved.
+//
+// A Redactor may keep state, e.g. to replace a value with the same
+// placeholder in every interaction of a cassette.
+type Redactor func(i *Interaction)
+
+// DefaultRedactors returns the Redactors used unless Options.Redactors is
+// set. They redact authorization headers, cookies, and tokens, and replace
+// email addresses and IDs with placeholders.
+func DefaultRedactors() []Redactor {
+	return []Redactor{
+		RedactHeaders("Authorization", "Cookie", "Set-Cookie"),
+		RedactQueryParams("access_token", "refresh_token", "code", "client_secret",
+			"client_assertion", "tempauth"),
+		RedactJSONFields("access_token", "refresh_token", "id_token", "client_secret"),
+		RedactEmails(),
+		RedactIDs(),
+	}
+}
+
+// RedactHeaders replaces the values of the request and response headers names with "REDACTED".
+func RedactHeaders(names ...string) Redactor {
+	return func(i *Interaction) {
+		for _, header := range []http.Header{i.Request.Header, i.Response.Header} {
+			for _, name := range names {
+				values := header.Values(name)
+				for n := range values {
+					values[n] = redacted
+				}
+			}
+		}
+	}
+}
+
+// RedactQueryParams replaces the values of the query parameters names with
+// "REDACTED", in the request URL, URLs within bodies and headers, and
+// form encoded bodies.
+func RedactQueryParams(names ...string) Redactor {
+	var patterns []*regexp.Regexp
+	for _, name := range names {
+		patterns = append(patterns, regexp.MustCompile(`(^|[?&])(`+regexp.QuoteMeta(name)+`=)[^&"'\s]*`))
+	}
+
+	return func(i *Interaction) {
+		for _, re := range patterns {
+			rewriteText(i, func(s string) string {
+				return re.ReplaceAllString(s, "${1}${2}"+redacted)
+			})
+		}
+	}
+}
+
+// RedactJSONFields replaces the values of the properties names, at any
+// depth of JSON request and response bodies, with "REDACTED".
+func RedactJSONFields(names ...string) Redactor {
+	fields := make(map[string]bool, len(names))
+	for _, name := range names {
+		fields[name] = true
+	}
+
+	return func(i *Interaction) {
+		for _, body := range []*Body{&i.Request.Body, &i.Response.Body} {
+			v, ok := decodeJSON(*body)
+			if !ok {
+				continue
+			}
+
+			changed := false
+			walkJSON(v, func(m map[string]interface{}, key string) {
+				if fields[key] {
+					m[key] = redacted
+					changed = true
+				}
+			})
+
+			if !changed {
+				continue
+			}
+
+			if data, err := json.Marshal(v); err == nil {
+				*body = Body{Text: string(data)}
+			}
+		}
+	}
+}
+
+// emailPattern matches an email address.
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+// RedactEmails replaces email addresses with placeholders such as
+// "user1@example.com", using the same placeholder for the same address.
+func RedactEmails() Redactor {
+	return Pseudonymize(emailPattern, "user%d@example.com")
+}
+
+// Pseudonymize replaces the matches of pattern, in the URL, headers, and
+// bodies, with placeholders created by formatting format with a number,
+// e.g. "name-%d". The same value is replaced with the same placeholder in
+// every interaction, so replayed requests match the recorded requests.
+func Pseudonymize(pattern *regexp.Regexp, format string) Redactor {
+	p := newPseudonyms(format)
+
+	return func(i *Interaction) {
+		var values []string
+		rewriteText(i, func(s string) string {
+			values = append(values, pattern.FindAllString(s, -1)...)
+			return s
+		})
+
+		p.add(values)
+		p.replace(i)
+	}
+}
+
+// minIDLength is the minimum length of IDs replaced by RedactIDs, so short
+// values, such as "root" or "1", aren't replaced.
+const minIDLength = 8
+
+// RedactIDs replaces IDs with placeholders such as "id-1", using the same
+// placeholder for the same ID in every interaction. IDs are the string
+// values, of at least 8 characters, of the JSON properties named "id" or
+// ending in "Id", such as "driveId", and are replaced wherever they occur,
+// such as in URLs.
+func RedactIDs() Redactor {
+	p := newPseudonyms("id-%d")
+
+	return func(i *Interaction) {
+		var values []string
+		for _, body := range []Body{i.Request.Body, i.Response.Body} {
+			v, ok := decodeJSON(body)
+			if !ok {
+				continue
+			}
+
+			walkJSON(v, func(m map[string]interface{}, key string) {
+				if key != "id" && !strings.HasSuffix(key, "Id") {
+					return
+				}
+				if s, ok := m[key].(string); ok && len(s) >= minIDLength {
+					values = append(values, s)
+				}
+			})
+		}
+
+		p.add(values)
+		p.replace(i)
+	}
+}
+
+// pseudonyms maps values to placeholders.
+type pseudonyms struct {
+	format string
+	values map[string]string
+
+	// the values ordered longest first, so a value containing another is replaced first
+	order []string
+}
+
+func newPseudonyms(format string) *pseudonyms {
+	return &pseudonyms{format: format, values: make(map[string]string)}
+}
+
+// add assigns placeholders to new values.
+func (p *pseudonyms) add(values []string) {
+	added := false
+	for _, value := range values {
+		if _, ok := p.values[value]; ok {
+			continue
+		}
+		p.values[value] = fmt.Sprintf(p.format, len(p.values)+1)
+		p.order = append(p.order, value)
+		added = true
+	}
+
+	if added {
+		sort.SliceStable(p.order, func(i, j int) bool { return len(p.order[i]) > len(p.order[j]) })
+	}
+}
+
+// replace replaces the values, and their URL escaped forms, in i.
+func (p *pseudonyms) replace(i *Interaction) {
+	if len(p.order) == 0 {
+		return
+	}
+
+	var pairs []string
+	for _, value := range p.order {
+		placeholder := p.values[value]
+		pairs = append(pairs, value, placeholder)
+
+		for _, escaped := range []string{url.PathEscape(value), url.QueryEscape(value)} {
+			if escaped != value {
+				pairs = append(pairs, escaped, placeholder)
+			}
+		}
+	}
+
+	replacer := strings.NewReplacer(pairs...)
+	rewriteText(i, replacer.Replace)
+}
+
+// rewriteText applies rewrite to the URL, header values, and text bodies of i.
+func rewriteText(i *Interaction, rewrite func(string) string) {
+	i.Request.URL = rewrite(i.Request.URL)
+
+	for _, header := range []http.Header{i.Request.Header, i.Response.Header} {
+		for _, values := range header {
+			for n := range values {
+				values[n] = rewrite(values[n])
+			}
+		}
+	}
+
+	for _, body := range []*Body{&i.Request.Body, &i.Response.Body} {
+		if body.Text != "" {
+			body.Text = rewrite(body.Text)
+		}
+	}
+}
+
+// decodeJSON decodes a JSON body, preserving numbers, returning false if it isn't JSON.
+func decodeJSON(body Body) (interface{}, bool) {
+	if body.Text == "" {
+		return nil, false
+	}
+
+	var v interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader([]byte(body.Text)))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, false
+	}
+
+	return v, true
+}
+
+// walkJSON calls visit for each property of each object within v.
+func walkJSON(v interface{}, visit func(m map[string]interface{}, key string)) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			visit(v, key)
+			walkJSON(value, visit)
+		}
+	case []interface{}:
+		for _, value := range v {
+			walkJSON(value, visit)
+		}
+	}
+}
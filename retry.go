@@ -0,0 +1,284 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RetryPolicy controls how requests are retried after throttling or transient errors.
+//
+// See https://docs.microsoft.com/en-us/graph/throttling for more information.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	// A value of 1 or less disables retries.
+	MaxAttempts int
+
+	// MaxElapsed is the maximum time spent on a request, including delays
+	// between attempts. A value of 0 means there is no limit.
+	MaxElapsed time.Duration
+
+	// BaseDelay is the delay before the first retry when the response has no
+	// Retry-After header. The delay doubles for each subsequent retry.
+	BaseDelay time.Duration
+
+	// MaxDelay is the maximum delay between attempts, unless a longer delay
+	// is requested by the Retry-After header.
+	MaxDelay time.Duration
+}
+
+// DefaultRetryPolicy is the RetryPolicy used unless WithRetryPolicy is given.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	MaxElapsed:  2 * time.Minute,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+}
+
+// WithRetryPolicy sets the policy used to retry throttled requests and transient errors.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = policy
+	}
+}
+
+// retryTransport is a http.RoundTripper that retries requests according to a RetryPolicy.
+//
+// Throttled (429) and unavailable (503) responses are retried for every
+// method, since the request was not processed. Other 5xx responses and
+// network errors are only retried for idempotent methods, so not for POST
+// or PATCH, which may have been processed before the error.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+	logger Logger
+}
+
+// newRetryTransport returns a retryTransport that sends requests using base.
+func newRetryTransport(base http.RoundTripper, policy RetryPolicy, logger Logger) http.RoundTripper {
+	if policy.MaxAttempts <= 1 {
+		return base
+	}
+
+	return &retryTransport{base: base, policy: policy, logger: logger}
+}
+
+// RoundTrip executes a single HTTP transaction, retrying it as needed.
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	ctx := req.Context()
+
+	// the body must be replayable to retry the request
+	getBody, err := replayableBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		r := req
+		if getBody != nil {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+
+			r = req.Clone(ctx)
+			r.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(r)
+
+		if attempt >= t.policy.MaxAttempts || !shouldRetry(req.Method, resp, err) {
+			return resp, err
+		}
+
+		// the Retry-After header is honored even if longer than MaxDelay
+		delay, ok := retryAfter(resp)
+		if !ok {
+			delay = t.backoff(attempt)
+		}
+
+		// give up if the next attempt would exceed the maximum elapsed time
+		if t.policy.MaxElapsed > 0 && time.Since(start)+delay > t.policy.MaxElapsed {
+			return resp, err
+		}
+
+		if err != nil {
+			t.logger.Printf("retrying %s %s in %v after error: %v", req.Method, req.URL.Path, delay, err)
+		} else {
+			t.logger.Printf("retrying %s %s in %v after status %d", req.Method, req.URL.Path, delay, resp.StatusCode)
+		}
+
+		// discard the response so the connection can be reused
+		if resp != nil {
+			io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns an exponential delay with jitter for attempt.
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	d := t.policy.BaseDelay
+	for n := 1; n < attempt && (t.policy.MaxDelay <= 0 || d < t.policy.MaxDelay); n++ {
+		d *= 2
+	}
+	if t.policy.MaxDelay > 0 && d > t.policy.MaxDelay {
+		d = t.policy.MaxDelay
+	}
+
+	// use a random delay between d/2 and d to avoid retrying in lockstep
+	if d > 1 {
+		d = d/2 + time.Duration(jitter(int64(d/2)))
+	}
+
+	return d
+}
+
+// replayableBody returns a function that returns a new copy of the request body.
+//
+// If the request doesn't provide GetBody, then the body is read into memory.
+// A nil function is returned if the request has no body.
+func replayableBody(req *http.Request) (func() (io.ReadCloser, error), error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+
+	if req.GetBody != nil {
+		// each attempt uses a new copy of the body
+		req.Body.Close()
+		return req.GetBody, nil
+	}
+
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	}, nil
+}
+
+// shouldRetry returns true if the request should be retried given the response and error.
+func shouldRetry(method string, resp *http.Response, err error) bool {
+	if err != nil {
+		// don't retry when the request was canceled or timed out
+		if resp == nil && isIdempotent(method) {
+			return !isContextError(err)
+		}
+		return false
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true
+	case resp.StatusCode == http.StatusServiceUnavailable:
+		return true
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return isIdempotent(method)
+	}
+
+	return false
+}
+
+// isIdempotent returns true if method can safely be sent more than once.
+//
+// PATCH isn't included, since a PATCH may append to a collection property
+// or change an ETag checked by a later request.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace,
+		http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	return false
+}
+
+// retryAfter returns the delay requested by the Retry-After header of resp, if any.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	if resp == nil {
+		return 0, false
+	}
+
+	return parseRetryAfter(resp.Header.Get("Retry-After"))
+}
+
+// parseRetryAfter parses a Retry-After value, which is either a number of seconds or a HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		d := time.Until(t)
+		if d < 0 {
+			d = 0
+		}
+		return d, true
+	}
+
+	return 0, false
+}
+
+// isContextError returns true if err was caused by a canceled or expired context.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// jitter returns a random number in [0,n).
+func jitter(n int64) int64 {
+	if n <= 0 {
+		return 0
+	}
+
+	jitterMu.Lock()
+	defer jitterMu.Unlock()
+
+	return jitterRand.Int63n(n)
+}
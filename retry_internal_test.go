@@ -0,0 +1,143 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+		ok    bool
+	}{
+		{"", 0, 0, false},
+		{"0", 0, 0, true},
+		{"7", 7 * time.Second, 7 * time.Second, true},
+		{"-1", 0, 0, false},
+		{"soon", 0, 0, false},
+		{time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat), 28 * time.Second, 30 * time.Second, true},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0, true},
+		{"Mon, 02 Jan 2006 15:04:05 GMT", 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		d, ok := parseRetryAfter(tt.value)
+		if ok != tt.ok || d < tt.min || d > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, %v, want [%v, %v], %v", tt.value, d, ok, tt.min, tt.max, tt.ok)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	transport := &retryTransport{policy: RetryPolicy{
+		MaxAttempts: 10,
+		BaseDelay:   100 * time.Millisecond,
+		MaxDelay:    time.Second,
+	}}
+
+	// each delay is between half and all of the exponential delay, capped at MaxDelay
+	for attempt, want := range []time.Duration{100, 200, 400, 800, 1000, 1000} {
+		want *= time.Millisecond
+
+		seen := make(map[time.Duration]bool)
+		for n := 0; n < 100; n++ {
+			d := transport.backoff(attempt + 1)
+			if d < want/2 || d > want {
+				t.Fatalf("backoff(%d) = %v, want between %v and %v", attempt+1, d, want/2, want)
+			}
+			seen[d] = true
+		}
+
+		if len(seen) < 2 {
+			t.Errorf("backoff(%d) returned %v every time, want jitter", attempt+1, seen)
+		}
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	errNetwork := errors.New("connection reset")
+
+	tests := []struct {
+		method string
+		status int
+		err    error
+		want   bool
+	}{
+		{http.MethodGet, http.StatusTooManyRequests, nil, true},
+		{http.MethodPost, http.StatusTooManyRequests, nil, true},
+		{http.MethodPatch, http.StatusTooManyRequests, nil, true},
+		{http.MethodPatch, http.StatusServiceUnavailable, nil, true},
+		{http.MethodPost, http.StatusServiceUnavailable, nil, true},
+		{http.MethodGet, http.StatusInternalServerError, nil, true},
+		{http.MethodPut, http.StatusBadGateway, nil, true},
+		{http.MethodDelete, http.StatusGatewayTimeout, nil, true},
+		{http.MethodPatch, http.StatusInternalServerError, nil, false},
+		{http.MethodPost, http.StatusBadGateway, nil, false},
+		{http.MethodGet, http.StatusNotImplemented, nil, false},
+		{http.MethodGet, http.StatusNotFound, nil, false},
+		{http.MethodGet, 0, errNetwork, true},
+		{http.MethodPatch, 0, errNetwork, false},
+		{http.MethodPost, 0, errNetwork, false},
+	}
+
+	for _, tt := range tests {
+		var resp *http.Response
+		if tt.err == nil {
+			resp = &http.Response{StatusCode: tt.status}
+		}
+
+		if got := shouldRetry(tt.method, resp, tt.err); got != tt.want {
+			t.Errorf("shouldRetry(%s, %d, %v) = %v, want %v", tt.method, tt.status, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestReplayableBody(t *testing.T) {
+	// a body without GetBody is read into memory
+	req, _ := http.NewRequest(http.MethodPost, "https://graph.microsoft.com/v1.0/me/sendMail",
+		ioutil.NopCloser(strings.NewReader("message")))
+	req.GetBody = nil
+
+	getBody, err := replayableBody(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for n := 0; n < 2; n++ {
+		body, err := getBody()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := ioutil.ReadAll(body)
+		if string(data) != "message" {
+			t.Errorf("attempt %d body = %q, want %q", n+1, data, "message")
+		}
+	}
+
+	req, _ = http.NewRequest(http.MethodGet, "https://graph.microsoft.com/v1.0/me", nil)
+	if getBody, err := replayableBody(req); getBody != nil || err != nil {
+		t.Errorf("replayableBody without a body = %v, want nil function and error", err)
+	}
+}
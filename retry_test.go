@@ -0,0 +1,150 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bnixon67/msgraph4go"
+	"github.com/bnixon67/msgraph4go/graphtest"
+)
+
+// requestsTo returns the requests to path received by server.
+func requestsTo(server *graphtest.Server, method string, path string) []graphtest.Request {
+	var requests []graphtest.Request
+	for _, r := range server.Requests() {
+		if r.Method == method && r.Path == path {
+			requests = append(requests, r)
+		}
+	}
+
+	return requests
+}
+
+func TestRetryAfter(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+
+	// the Retry-After header is honored even though it exceeds MaxDelay
+	server.Throttle("/me/messages", 1, time.Second)
+
+	start := time.Now()
+	if _, err := client.ListMyMessages(nil); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want at least the Retry-After of 1s", elapsed)
+	}
+}
+
+func TestRetryMaxElapsed(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	client := server.Client(msgraph4go.WithRetryPolicy(msgraph4go.RetryPolicy{
+		MaxAttempts: 5,
+		MaxElapsed:  500 * time.Millisecond,
+		BaseDelay:   time.Millisecond,
+		MaxDelay:    10 * time.Millisecond,
+	}))
+
+	// waiting for the Retry-After would exceed MaxElapsed, so the response is returned
+	server.Throttle("/me/messages", 1, 2*time.Second)
+
+	start := time.Now()
+	_, err := client.ListMyMessages(nil)
+	if !errors.Is(err, msgraph4go.ErrThrottled) {
+		t.Errorf("error = %v, want %v", err, msgraph4go.ErrThrottled)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("gave up after %v, want immediately", elapsed)
+	}
+	if n := len(requestsTo(server, http.MethodGet, "/me/messages")); n != 1 {
+		t.Errorf("got %d requests, want 1", n)
+	}
+}
+
+func TestRetryReplaysBody(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	server.Throttle("/me/contacts", 2, 0)
+
+	// a reader without GetBody, so the body is buffered to be replayed
+	body := io.MultiReader(strings.NewReader(`{"givenName":"Pavel"}`))
+
+	if _, err := client.Post("/me/contacts", nil, body); err != nil {
+		t.Fatal(err)
+	}
+
+	requests := requestsTo(server, http.MethodPost, "/me/contacts")
+	if len(requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(requests))
+	}
+	for n, r := range requests {
+		if string(r.Body) != `{"givenName":"Pavel"}` {
+			t.Errorf("attempt %d body = %q", n+1, r.Body)
+		}
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	contact := server.AddContact(msgraph4go.Contact{GivenName: "Pavel"})
+	path := "/me/contacts/" + contact.ID
+
+	// a PATCH may have been processed before a 500, so it isn't retried
+	server.ServerError(path, http.StatusInternalServerError, 1)
+	_, err := client.Patch(path, nil, strings.NewReader(`{"givenName":"Paul"}`))
+
+	var graphErr *msgraph4go.GraphErrorResponse
+	if !errors.As(err, &graphErr) || graphErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("PATCH error = %v, want 500", err)
+	}
+	if n := len(requestsTo(server, http.MethodPatch, path)); n != 1 {
+		t.Errorf("got %d PATCH requests, want 1", n)
+	}
+
+	// a 503 means the request wasn't processed, so it is retried
+	server.ServerError(path, http.StatusServiceUnavailable, 1)
+	if _, err := client.Patch(path, nil, strings.NewReader(`{"givenName":"Paul"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(requestsTo(server, http.MethodPatch, path)); n != 3 {
+		t.Errorf("got %d PATCH requests, want 3", n)
+	}
+
+	// a GET is retried after a 500
+	server.ServerError(path, http.StatusInternalServerError, 2)
+	if _, err := client.Get(path, nil); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(requestsTo(server, http.MethodGet, path)); n != 3 {
+		t.Errorf("got %d GET requests, want 3", n)
+	}
+}
@@ -0,0 +1,253 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// maxErrorBodySize is the maximum size of an error response body that is read.
+const maxErrorBodySize = 1 << 20
+
+// Stream is a response body that is read as it is received, rather than
+// buffered in memory. The caller must close the Stream.
+type Stream struct {
+	io.ReadCloser
+
+	// ContentType is the Content-Type of the body, e.g. "image/jpeg".
+	ContentType string
+
+	// ContentLength is the length of the body, or -1 if it is unknown.
+	ContentLength int64
+
+	// Header is the header of the response.
+	Header http.Header
+
+	// NotModified is true for a 304 Not Modified response to a request
+	// using IfNoneMatch, in which case the body is empty.
+	NotModified bool
+}
+
+// newStream returns a Stream for the body of resp.
+func newStream(resp *http.Response) *Stream {
+	return &Stream{
+		ReadCloser:    resp.Body,
+		ContentType:   resp.Header.Get("Content-Type"),
+		ContentLength: resp.ContentLength,
+		Header:        resp.Header,
+		NotModified:   resp.StatusCode == http.StatusNotModified,
+	}
+}
+
+// GetStream is like Get, but returns the response body as a Stream.
+//
+// The status code is checked before the Stream is returned, so an error
+// response is returned as a *GraphErrorResponse rather than as the body.
+// If IfNoneMatch matches the resource, NotModified of the Stream is true.
+func (c *MSGraphClient) GetStream(urlString string, query url.Values) (stream *Stream, err error) {
+	return c.GetStreamWithContext(context.Background(), urlString, query)
+}
+
+// GetStreamWithContext is like GetStream, but uses ctx for the request.
+func (c *MSGraphClient) GetStreamWithContext(ctx context.Context, urlString string, query url.Values) (stream *Stream, err error) {
+	resp, err := c.do(ctx, http.MethodGet, urlString, query, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStream(resp), nil
+}
+
+// CollectionDecoder decodes the items of a collection, such as a
+// MessageCollection, one at a time as the response body is read, so that
+// a large collection isn't held in memory.
+//
+//	dec, err := c.GetCollectionDecoder("/me/messages", query)
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	defer dec.Close()
+//
+//	for dec.More() {
+//		var message msgraph4go.Message
+//		if err := dec.Decode(&message); err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//	if err := dec.Err(); err != nil {
+//		log.Fatal(err)
+//	}
+type CollectionDecoder struct {
+	dec    *json.Decoder
+	closer io.Closer
+
+	started bool
+	inValue bool
+	done    bool
+
+	nextLink  string
+	deltaLink string
+	err       error
+}
+
+// NewCollectionDecoder returns a CollectionDecoder that reads a collection from r.
+func NewCollectionDecoder(r io.Reader) *CollectionDecoder {
+	return &CollectionDecoder{dec: json.NewDecoder(r)}
+}
+
+// GetCollectionDecoder executes a GET request for the collection at
+// urlString, returning a CollectionDecoder for the response body.
+// The caller must close the CollectionDecoder.
+func (c *MSGraphClient) GetCollectionDecoder(urlString string, query url.Values) (dec *CollectionDecoder, err error) {
+	return c.GetCollectionDecoderWithContext(context.Background(), urlString, query)
+}
+
+// GetCollectionDecoderWithContext is like GetCollectionDecoder, but uses ctx for the request.
+func (c *MSGraphClient) GetCollectionDecoderWithContext(ctx context.Context, urlString string, query url.Values) (dec *CollectionDecoder, err error) {
+	stream, err := c.GetStreamWithContext(ctx, urlString, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// there is no collection to decode in a 304 response
+	if stream.NotModified {
+		stream.Close()
+		return nil, notModified(stream.Header)
+	}
+
+	dec = NewCollectionDecoder(stream)
+	dec.closer = stream
+
+	return dec, nil
+}
+
+// More returns true if there is another item to Decode.
+func (d *CollectionDecoder) More() bool {
+	if d.err != nil || d.done {
+		return false
+	}
+
+	if !d.started {
+		d.started = true
+		if d.expectDelim('{') != nil {
+			return false
+		}
+	}
+
+	if d.inValue {
+		if d.dec.More() {
+			return true
+		}
+
+		// end of the value array
+		d.inValue = false
+		if d.expectDelim(']') != nil {
+			return false
+		}
+	}
+
+	// read the properties until the value array or the end of the collection
+	for d.dec.More() {
+		token, err := d.dec.Token()
+		if err != nil {
+			d.err = err
+			return false
+		}
+
+		name, _ := token.(string)
+		switch name {
+		case "value":
+			if d.expectDelim('[') != nil {
+				return false
+			}
+			d.inValue = true
+			return d.More()
+		case "@odata.nextLink":
+			d.err = d.dec.Decode(&d.nextLink)
+		case "@odata.deltaLink":
+			d.err = d.dec.Decode(&d.deltaLink)
+		default:
+			var skip json.RawMessage
+			d.err = d.dec.Decode(&skip)
+		}
+		if d.err != nil {
+			return false
+		}
+	}
+
+	d.done = true
+	d.expectDelim('}')
+
+	return false
+}
+
+// Decode decodes the next item into v. More must return true before each call to Decode.
+func (d *CollectionDecoder) Decode(v interface{}) error {
+	if !d.inValue {
+		return errors.New("msgraph4go: no item to decode, call More first")
+	}
+
+	err := d.dec.Decode(v)
+	if err != nil && d.err == nil {
+		d.err = err
+	}
+
+	return err
+}
+
+// NextLink returns the @odata.nextLink of the collection, if any, once More returns false.
+func (d *CollectionDecoder) NextLink() string {
+	return d.nextLink
+}
+
+// DeltaLink returns the @odata.deltaLink of the collection, if any, once More returns false.
+func (d *CollectionDecoder) DeltaLink() string {
+	return d.deltaLink
+}
+
+// Err returns the first error that occured while reading the collection, if any.
+func (d *CollectionDecoder) Err() error {
+	return d.err
+}
+
+// Close closes the response body, if the CollectionDecoder was returned by GetCollectionDecoder.
+func (d *CollectionDecoder) Close() error {
+	if d.closer == nil {
+		return nil
+	}
+
+	return d.closer.Close()
+}
+
+// expectDelim reads the next token, setting the error if it isn't delim.
+func (d *CollectionDecoder) expectDelim(delim json.Delim) error {
+	token, err := d.dec.Token()
+	if err == nil && token != delim {
+		err = fmt.Errorf("msgraph4go: invalid collection, expected %v but found %v", delim, token)
+	}
+	if err != nil && d.err == nil {
+		d.err = err
+	}
+
+	return err
+}
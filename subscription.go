@@ -0,0 +1,262 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// CreateSubscription creates a subscription to receive change notifications for a resource.
+//
+// The notification URL must answer the validation request, e.g. using a
+// WebhookHandler, before the subscription is created.
+//
+// See https://docs.microsoft.com/en-us/graph/api/subscription-post-subscriptions for more information.
+func (c *MSGraphClient) CreateSubscription(subscription Subscription) (response Subscription, err error) {
+	return c.CreateSubscriptionWithContext(context.Background(), subscription)
+}
+
+// CreateSubscriptionWithContext is like CreateSubscription, but uses ctx for the request.
+func (c *MSGraphClient) CreateSubscriptionWithContext(ctx context.Context, subscription Subscription) (response Subscription, err error) {
+	data, err := json.Marshal(subscription)
+	if err != nil {
+		return response, err
+	}
+
+	body, err := c.PostWithContext(ctx, "/subscriptions", nil, bytes.NewReader(data))
+	if err != nil {
+		return response, err
+	}
+
+	err = decodeBody(body, &response)
+
+	return response, err
+}
+
+// GetSubscription gets the subscription with the ID.
+func (c *MSGraphClient) GetSubscription(id string, query url.Values) (response Subscription, err error) {
+	return c.GetSubscriptionWithContext(context.Background(), id, query)
+}
+
+// GetSubscriptionWithContext is like GetSubscription, but uses ctx for the request.
+func (c *MSGraphClient) GetSubscriptionWithContext(ctx context.Context, id string, query url.Values) (response Subscription, err error) {
+	body, err := c.GetWithContext(ctx, "/subscriptions/"+id, query)
+	if err != nil {
+		return response, err
+	}
+
+	err = decodeBody(body, &response)
+
+	return response, err
+}
+
+// ListSubscriptions gets the subscriptions of the application, or of the current user.
+func (c *MSGraphClient) ListSubscriptions(query url.Values) (response SubscriptionCollection, err error) {
+	return c.ListSubscriptionsWithContext(context.Background(), query)
+}
+
+// ListSubscriptionsWithContext is like ListSubscriptions, but uses ctx for the request.
+func (c *MSGraphClient) ListSubscriptionsWithContext(ctx context.Context, query url.Values) (response SubscriptionCollection, err error) {
+	body, err := c.GetWithContext(ctx, "/subscriptions", query)
+	if err != nil {
+		return response, err
+	}
+
+	err = decodeBody(body, &response)
+
+	return response, err
+}
+
+// ListSubscriptionsPager returns a Pager for the Subscription items of the collection returned by ListSubscriptions.
+func (c *MSGraphClient) ListSubscriptionsPager(query url.Values) *Pager {
+	return c.NewPager("/subscriptions", query)
+}
+
+// RenewSubscription extends the subscription with the ID until expiration.
+func (c *MSGraphClient) RenewSubscription(id string, expiration time.Time) (response Subscription, err error) {
+	return c.RenewSubscriptionWithContext(context.Background(), id, expiration)
+}
+
+// RenewSubscriptionWithContext is like RenewSubscription, but uses ctx for the request.
+func (c *MSGraphClient) RenewSubscriptionWithContext(ctx context.Context, id string, expiration time.Time) (response Subscription, err error) {
+	data, err := json.Marshal(Subscription{ExpirationDateTime: NewTimestamp(expiration)})
+	if err != nil {
+		return response, err
+	}
+
+	body, err := c.PatchWithContext(ctx, "/subscriptions/"+id, nil, bytes.NewReader(data))
+	if err != nil {
+		return response, err
+	}
+
+	err = decodeBody(body, &response)
+
+	return response, err
+}
+
+// DeleteSubscription deletes the subscription with the ID.
+func (c *MSGraphClient) DeleteSubscription(id string) (err error) {
+	return c.DeleteSubscriptionWithContext(context.Background(), id)
+}
+
+// DeleteSubscriptionWithContext is like DeleteSubscription, but uses ctx for the request.
+func (c *MSGraphClient) DeleteSubscriptionWithContext(ctx context.Context, id string) (err error) {
+	return c.DeleteWithContext(ctx, "/subscriptions/"+id, nil)
+}
+
+// SubscriptionRenewer renews subscriptions before they expire.
+//
+// Add each subscription after it is created, then call Run, or call
+// RenewDue periodically, to renew the subscriptions that expire soon.
+type SubscriptionRenewer struct {
+	// OnError is called, if not nil, when a subscription can't be renewed.
+	// A subscription that no longer exists is removed from the renewer.
+	OnError func(id string, err error)
+
+	c           *MSGraphClient
+	lifetime    time.Duration
+	renewBefore time.Duration
+
+	mu          sync.Mutex
+	expirations map[string]time.Time
+}
+
+// NewSubscriptionRenewer returns a SubscriptionRenewer that renews each
+// subscription for lifetime, once it expires within renewBefore.
+//
+// lifetime must not exceed the maximum for the resource, e.g. just under
+// 3 days for messages, events, and contacts.
+// See https://docs.microsoft.com/en-us/graph/api/resources/subscription#maximum-length-of-subscription-per-resource-type
+func (c *MSGraphClient) NewSubscriptionRenewer(lifetime time.Duration, renewBefore time.Duration) *SubscriptionRenewer {
+	return &SubscriptionRenewer{
+		c:           c,
+		lifetime:    lifetime,
+		renewBefore: renewBefore,
+		expirations: make(map[string]time.Time),
+	}
+}
+
+// Add renews subscription before its ExpirationDateTime.
+func (r *SubscriptionRenewer) Add(subscription Subscription) error {
+	expiration := subscription.ExpirationDateTime.Time()
+	if expiration.IsZero() {
+		return fmt.Errorf("msgraph4go: subscription %q has no expirationDateTime", subscription.ID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.expirations[subscription.ID] = expiration
+
+	return nil
+}
+
+// Remove stops renewing the subscription with the ID, e.g. after it is deleted.
+func (r *SubscriptionRenewer) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.expirations, id)
+}
+
+// Expiration returns when the subscription with the ID expires, and false if it isn't renewed by r.
+func (r *SubscriptionRenewer) Expiration(id string) (time.Time, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	expiration, ok := r.expirations[id]
+
+	return expiration, ok
+}
+
+// RenewDue renews the subscriptions that expire within renewBefore,
+// returning the first error, if any.
+func (r *SubscriptionRenewer) RenewDue(ctx context.Context) error {
+	deadline := time.Now().Add(r.renewBefore)
+
+	// collect the IDs first, so the lock isn't held during requests
+	var due []string
+	r.mu.Lock()
+	for id, expiration := range r.expirations {
+		if expiration.Before(deadline) {
+			due = append(due, id)
+		}
+	}
+	r.mu.Unlock()
+
+	var firstErr error
+	for _, id := range due {
+		expiration := time.Now().Add(r.lifetime)
+		subscription, err := r.c.RenewSubscriptionWithContext(ctx, id, expiration)
+		if err == nil {
+			// the response should include the new expiration, but don't rely on it
+			if !subscription.ExpirationDateTime.IsZero() {
+				expiration = subscription.ExpirationDateTime.Time()
+			}
+			r.renewed(id, expiration)
+		}
+		if err != nil {
+			// the subscription expired or was deleted, so it can't be renewed
+			if errors.Is(err, ErrNotFound) {
+				r.Remove(id)
+			}
+			if r.OnError != nil {
+				r.OnError(id, err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// renewed sets the expiration of the subscription with the ID, unless it
+// was removed while it was renewed.
+func (r *SubscriptionRenewer) renewed(id string, expiration time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.expirations[id]; ok {
+		r.expirations[id] = expiration
+	}
+}
+
+// Run calls RenewDue every interval until ctx is done, returning ctx.Err().
+// Errors renewing subscriptions are reported using OnError.
+func (r *SubscriptionRenewer) Run(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		r.RenewDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
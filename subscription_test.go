@@ -0,0 +1,141 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/bnixon67/msgraph4go"
+	"github.com/bnixon67/msgraph4go/graphtest"
+)
+
+// createSubscription creates a subscription that expires at expiration.
+func createSubscription(t *testing.T, client *msgraph4go.MSGraphClient, expiration time.Time) msgraph4go.Subscription {
+	t.Helper()
+
+	subscription, err := client.CreateSubscription(msgraph4go.Subscription{
+		ChangeType:         "created",
+		NotificationURL:    "https://example.com/notify",
+		Resource:           "me/messages",
+		ExpirationDateTime: msgraph4go.NewTimestamp(expiration),
+		ClientState:        "secret",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return subscription
+}
+
+func TestSubscriptionRenewer(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	now := time.Now()
+
+	due := createSubscription(t, client, now.Add(time.Minute))
+	later := createSubscription(t, client, now.Add(48*time.Hour))
+
+	renewer := client.NewSubscriptionRenewer(72*time.Hour, time.Hour)
+	for _, subscription := range []msgraph4go.Subscription{due, later} {
+		if err := renewer.Add(subscription); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := renewer.RenewDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the subscription that expires within an hour is renewed
+	if expiration, _ := renewer.Expiration(due.ID); expiration.Before(now.Add(71 * time.Hour)) {
+		t.Errorf("expiration = %v, want renewed for 72 hours", expiration)
+	}
+	if expiration, _ := renewer.Expiration(later.ID); !expiration.Equal(later.ExpirationDateTime.Time()) {
+		t.Errorf("expiration = %v, want unchanged %v", expiration, later.ExpirationDateTime.Time())
+	}
+
+	renewed, err := client.GetSubscription(due.ID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.ExpirationDateTime.Time().Before(now.Add(71 * time.Hour)) {
+		t.Errorf("server expiration = %v, want renewed", renewed.ExpirationDateTime)
+	}
+}
+
+func TestSubscriptionRenewerDeleted(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	client := server.Client()
+	subscription := createSubscription(t, client, time.Now().Add(time.Minute))
+
+	renewer := client.NewSubscriptionRenewer(72*time.Hour, time.Hour)
+	renewer.Add(subscription)
+
+	var errs []error
+	renewer.OnError = func(id string, err error) { errs = append(errs, err) }
+
+	if err := client.DeleteSubscription(subscription.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := renewer.RenewDue(context.Background()); !errors.Is(err, msgraph4go.ErrNotFound) {
+		t.Errorf("RenewDue = %v, want %v", err, msgraph4go.ErrNotFound)
+	}
+	if len(errs) != 1 {
+		t.Errorf("OnError called %d times, want 1", len(errs))
+	}
+	if _, ok := renewer.Expiration(subscription.ID); ok {
+		t.Error("deleted subscription is still renewed")
+	}
+}
+
+func TestSubscriptionRenewerRemovedWhileRenewing(t *testing.T) {
+	server := graphtest.NewServer()
+	defer server.Close()
+
+	var renewer *msgraph4go.SubscriptionRenewer
+
+	// remove the subscription while the renewal request is sent
+	client := server.Client(msgraph4go.WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return msgraph4go.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method == http.MethodPatch && renewer != nil {
+				renewer.Remove(req.URL.Path[len("/v1.0/subscriptions/"):])
+			}
+			return next.RoundTrip(req)
+		})
+	}))
+
+	subscription := createSubscription(t, client, time.Now().Add(time.Minute))
+
+	renewer = client.NewSubscriptionRenewer(72*time.Hour, time.Hour)
+	renewer.Add(subscription)
+
+	if err := renewer.RenewDue(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := renewer.Expiration(subscription.ID); ok {
+		t.Error("subscription removed while renewing was added again")
+	}
+}
@@ -0,0 +1,224 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// graphTimeFormat is the format of timestamps sent to the Graph API.
+	graphTimeFormat = "2006-01-02T15:04:05.0000000Z"
+
+	// localTimeFormat is the format of a DateTimeTimeZone, which is in its time zone.
+	localTimeFormat = "2006-01-02T15:04:05.0000000"
+)
+
+// Timestamp is a date and time returned by the Graph API, such as the
+// lastModifiedDateTime of a DriveItem.
+//
+// Properties are a *Timestamp, so they are left out of a request body
+// unless set. Time returns the time.Time, which is zero for a nil
+// Timestamp, e.g. item.LastModifiedDateTime.Time().Before(t).
+//
+// A Timestamp is decoded using ParseTime, and encoded in UTC with 7
+// digits of fractional seconds as expected by the Graph API.
+type Timestamp time.Time
+
+// NewTimestamp returns a Timestamp for t, e.g. for the ExpirationDateTime of a Subscription.
+func NewTimestamp(t time.Time) *Timestamp {
+	ts := Timestamp(t)
+	return &ts
+}
+
+// Time returns t as a time.Time, or the zero time if t is nil.
+func (t *Timestamp) Time() time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+
+	return time.Time(*t)
+}
+
+// IsZero returns true if t is nil or the zero time.
+func (t *Timestamp) IsZero() bool {
+	return t.Time().IsZero()
+}
+
+// MarshalJSON encodes t in the format expected by the Graph API, or as null if t is zero.
+func (t Timestamp) MarshalJSON() ([]byte, error) {
+	if time.Time(t).IsZero() {
+		return []byte("null"), nil
+	}
+
+	return json.Marshal(formatGraphTime(time.Time(t)))
+}
+
+// UnmarshalJSON decodes t from a JSON string or null.
+func (t *Timestamp) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*t = Timestamp{}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	parsed, err := ParseTime(s)
+	if err != nil {
+		return err
+	}
+
+	*t = Timestamp(parsed)
+
+	return nil
+}
+
+// String returns t in RFC 3339 format, or an empty string if t is zero.
+func (t *Timestamp) String() string {
+	if t.IsZero() {
+		return ""
+	}
+
+	return t.Time().Format(time.RFC3339Nano)
+}
+
+// ParseTime parses a date and time returned by the Graph API, which is
+// ISO 8601 with up to 7 digits of fractional seconds, e.g.
+// "2021-03-01T10:00:00.1234567Z". A time without Z or an offset, such as
+// the DateTime of a DateTimeTimeZone, and a date without a time are in UTC.
+//
+// An empty string is parsed as the zero time.
+func ParseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("msgraph4go: invalid time %q", s)
+}
+
+// formatGraphTime formats t in UTC as used by the Graph API.
+func formatGraphTime(t time.Time) string {
+	return t.UTC().Format(graphTimeFormat)
+}
+
+// NewDateTimeTimeZone returns a DateTimeTimeZone for t in its location,
+// e.g. for the Start of an Event. A time in the local location, which
+// doesn't have a name known to the Graph API, is converted to UTC.
+func NewDateTimeTimeZone(t time.Time) *DateTimeTimeZone {
+	if t.Location() == time.Local || t.Location() == time.UTC {
+		t = t.UTC()
+	}
+
+	return &DateTimeTimeZone{
+		DateTime: t.Format(localTimeFormat),
+		TimeZone: t.Location().String(),
+	}
+}
+
+// Time returns the date and time in its time zone, which is either an
+// IANA name such as "America/Los_Angeles" or a common Windows name such
+// as "Pacific Standard Time". An empty TimeZone is UTC, which is used by
+// the Graph API unless a Prefer: outlook.timezone header is sent.
+//
+// The zero time is returned if d is nil or DateTime is empty.
+func (d *DateTimeTimeZone) Time() (time.Time, error) {
+	if d == nil || d.DateTime == "" {
+		return time.Time{}, nil
+	}
+
+	loc, err := loadTimeZone(d.TimeZone)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// a DateTime normally has no offset, but use it if it does
+	if t, err := time.Parse(time.RFC3339Nano, d.DateTime); err == nil {
+		return t.In(loc), nil
+	}
+
+	t, err := time.ParseInLocation("2006-01-02T15:04:05.999999999", d.DateTime, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("msgraph4go: invalid time %q", d.DateTime)
+	}
+
+	return t, nil
+}
+
+// windowsTimeZones maps common Windows time zone names to IANA names.
+var windowsTimeZones = map[string]string{
+	"Dateline Standard Time":         "Etc/GMT+12",
+	"Hawaiian Standard Time":         "Pacific/Honolulu",
+	"Alaskan Standard Time":          "America/Anchorage",
+	"Pacific Standard Time":          "America/Los_Angeles",
+	"US Mountain Standard Time":      "America/Phoenix",
+	"Mountain Standard Time":         "America/Denver",
+	"Central Standard Time":          "America/Chicago",
+	"Eastern Standard Time":          "America/New_York",
+	"Atlantic Standard Time":         "America/Halifax",
+	"E. South America Standard Time": "America/Sao_Paulo",
+	"GMT Standard Time":              "Europe/London",
+	"Greenwich Standard Time":        "Atlantic/Reykjavik",
+	"W. Europe Standard Time":        "Europe/Berlin",
+	"Romance Standard Time":          "Europe/Paris",
+	"Central Europe Standard Time":   "Europe/Budapest",
+	"E. Europe Standard Time":        "Europe/Chisinau",
+	"FLE Standard Time":              "Europe/Kiev",
+	"Russian Standard Time":          "Europe/Moscow",
+	"South Africa Standard Time":     "Africa/Johannesburg",
+	"Arabian Standard Time":          "Asia/Dubai",
+	"India Standard Time":            "Asia/Kolkata",
+	"China Standard Time":            "Asia/Shanghai",
+	"Singapore Standard Time":        "Asia/Singapore",
+	"Tokyo Standard Time":            "Asia/Tokyo",
+	"Korea Standard Time":            "Asia/Seoul",
+	"AUS Eastern Standard Time":      "Australia/Sydney",
+	"New Zealand Standard Time":      "Pacific/Auckland",
+	"UTC":                            "UTC",
+	"Coordinated Universal Time":     "UTC",
+	"tzone://Microsoft/Utc":          "UTC",
+}
+
+// loadTimeZone returns the location of a time zone name of the Graph API.
+func loadTimeZone(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return time.UTC, nil
+	}
+
+	if iana, ok := windowsTimeZones[name]; ok {
+		name = iana
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("msgraph4go: unknown time zone %q", name)
+	}
+
+	return loc, nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestTimestampUnmarshal(t *testing.T) {
+	tests := []struct {
+		json string
+		want time.Time
+	}{
+		{`"2021-03-01T10:00:00Z"`, time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{`"2021-03-01T10:00:00.1234567Z"`, time.Date(2021, 3, 1, 10, 0, 0, 123456700, time.UTC)},
+		{`"2021-03-01T10:00:00.1Z"`, time.Date(2021, 3, 1, 10, 0, 0, 100000000, time.UTC)},
+
+		// without Z, as in a DateTimeTimeZone, the time is in UTC
+		{`"2021-03-01T10:00:00.1234567"`, time.Date(2021, 3, 1, 10, 0, 0, 123456700, time.UTC)},
+		{`"2021-03-01T10:00:00"`, time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)},
+
+		{`"2021-03-01T10:00:00.5+02:00"`, time.Date(2021, 3, 1, 8, 0, 0, 500000000, time.UTC)},
+		{`"2021-03-01"`, time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{`""`, time.Time{}},
+		{`null`, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		ts := *NewTimestamp(time.Now())
+		if err := json.Unmarshal([]byte(tt.json), &ts); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.json, err)
+			continue
+		}
+		if got := time.Time(ts); !got.Equal(tt.want) {
+			t.Errorf("Unmarshal(%s) = %v, want %v", tt.json, got, tt.want)
+		}
+	}
+
+	for _, invalid := range []string{`"yesterday"`, `"2021-13-01T10:00:00Z"`, `1614592800`, `true`} {
+		var ts Timestamp
+		if err := json.Unmarshal([]byte(invalid), &ts); err == nil {
+			t.Errorf("Unmarshal(%s) = %v, want error", invalid, time.Time(ts))
+		}
+	}
+}
+
+func TestTimestampMarshal(t *testing.T) {
+	tests := []struct {
+		ts   *Timestamp
+		want string
+	}{
+		{NewTimestamp(time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)), `"2021-03-01T10:00:00.0000000Z"`},
+		{NewTimestamp(time.Date(2021, 3, 1, 10, 0, 0, 123456789, time.UTC)), `"2021-03-01T10:00:00.1234567Z"`},
+		{NewTimestamp(time.Date(2021, 3, 1, 12, 0, 0, 0, time.FixedZone("CET", 2*60*60))), `"2021-03-01T10:00:00.0000000Z"`},
+		{NewTimestamp(time.Time{}), `null`},
+		{nil, `null`},
+	}
+
+	for _, tt := range tests {
+		data, err := json.Marshal(tt.ts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("Marshal(%v) = %s, want %s", tt.ts, data, tt.want)
+		}
+	}
+}
+
+func TestTimestampRoundTrip(t *testing.T) {
+	type item struct {
+		Created  *Timestamp `json:"created,omitempty"`
+		Modified *Timestamp `json:"modified,omitempty"`
+	}
+
+	// a nil Timestamp is left out, and null decodes to nil
+	var decoded item
+	if err := json.Unmarshal([]byte(`{"created":"2021-03-01T10:00:00.1234567Z","modified":null}`), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Modified != nil {
+		t.Errorf("Modified = %v, want nil", decoded.Modified)
+	}
+
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"created":"2021-03-01T10:00:00.1234567Z"}`; string(data) != want {
+		t.Errorf("Marshal = %s, want %s", data, want)
+	}
+
+	// the 7 digits of fractional seconds survive a round trip
+	var again item
+	if err := json.Unmarshal(data, &again); err != nil {
+		t.Fatal(err)
+	}
+	if !again.Created.Time().Equal(decoded.Created.Time()) || again.Created.Time().Nanosecond() != 123456700 {
+		t.Errorf("Created = %v, want %v", again.Created, decoded.Created)
+	}
+}
+
+func TestTimestampNil(t *testing.T) {
+	var ts *Timestamp
+
+	if !ts.Time().IsZero() || !ts.IsZero() || ts.String() != "" {
+		t.Errorf("nil Timestamp = %v %v %q, want zero", ts.Time(), ts.IsZero(), ts.String())
+	}
+
+	ts = NewTimestamp(time.Date(2021, 3, 1, 10, 0, 0, 500, time.UTC))
+	if ts.IsZero() || ts.String() != "2021-03-01T10:00:00.0000005Z" {
+		t.Errorf("Timestamp = %v %q", ts.IsZero(), ts.String())
+	}
+}
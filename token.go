@@ -0,0 +1,169 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+const (
+	// lockTimeout is how long to wait for another process to release a token file lock.
+	lockTimeout = 10 * time.Second
+
+	// lockStale is the age after which a token file lock is assumed to be
+	// abandoned. The modification time of a held lock is refreshed more often.
+	lockStale = 30 * time.Second
+)
+
+// storeTokenSource is an oauth2.TokenSource that refreshes the token when
+// it expires and saves the new token to a TokenStore.
+//
+// If the store implements TokenLocker, then it is locked while refreshing,
+// and the token is reloaded before refreshing, so that several processes
+// sharing the store only refresh the token once and always use the latest
+// refresh token.
+type storeTokenSource struct {
+	mu    sync.Mutex
+	ctx   context.Context
+	conf  *oauth2.Config
+	store TokenStore
+	key   TokenKey
+	token *oauth2.Token
+}
+
+// newStoreTokenSource returns a storeTokenSource starting with token.
+func newStoreTokenSource(ctx context.Context, conf *oauth2.Config, store TokenStore, key TokenKey, token *oauth2.Token) *storeTokenSource {
+	return &storeTokenSource{
+		ctx:   ctx,
+		conf:  conf,
+		store: store,
+		key:   key,
+		token: token,
+	}
+}
+
+// Token returns a valid token, refreshing and saving it if needed.
+func (s *storeTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token.Valid() {
+		return s.token, nil
+	}
+
+	if locker, ok := s.store.(TokenLocker); ok {
+		unlock, err := locker.Lock(s.key)
+		if err != nil {
+			return nil, &TokenStoreError{Op: "lock", Key: s.key, Err: err}
+		}
+		defer unlock()
+
+		// another process may have refreshed the token, possibly rotating the refresh token
+		if token, err := s.store.Load(s.key); err == nil {
+			if token.Valid() {
+				s.token = token
+				return token, nil
+			}
+			if token.RefreshToken != "" {
+				s.token = token
+			}
+		}
+	}
+
+	token, err := s.conf.TokenSource(s.ctx, s.token).Token()
+	if err != nil {
+		return nil, authError("refresh", err)
+	}
+
+	err = s.store.Save(s.key, token)
+	if err != nil {
+		return nil, &TokenStoreError{Op: "save", Key: s.key, Err: err}
+	}
+
+	s.token = token
+
+	return token, nil
+}
+
+// holdLock refreshes the modification time of the lock file lockName
+// while the lock is held, so a slow token refresh isn't mistaken for an
+// abandoned lock, returning a function to release the lock.
+func holdLock(lockName string) (unlock func()) {
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(lockStale / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				now := time.Now()
+				os.Chtimes(lockName, now, now)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			os.Remove(lockName)
+		})
+	}
+}
+
+// lockFile acquires an exclusive lock for fileName, shared with other
+// processes, returning a function to release the lock.
+//
+// The lock is a separate file, fileName with a ".lock" suffix, created
+// exclusively, which works on all platforms.
+func lockFile(fileName string) (unlock func(), err error) {
+	lockName := fileName + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+
+	for {
+		file, err := os.OpenFile(lockName, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			file.Close()
+			return holdLock(lockName), nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		// remove a lock abandoned by a process that exited without releasing it
+		if info, err := os.Stat(lockName); err == nil && time.Since(info.ModTime()) > lockStale {
+			os.Remove(lockName)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, errors.New("msgraph4go: timeout waiting for lock " + lockName)
+		}
+
+		time.Sleep(50 * time.Millisecond)
+	}
+}
@@ -0,0 +1,467 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/oauth2"
+)
+
+// ErrTokenNotFound is returned by a TokenStore when there is no token for a key.
+var ErrTokenNotFound = errors.New("msgraph4go: token not found")
+
+// TokenKey identifies a token within a TokenStore, so one store can hold
+// tokens for several applications, sets of scopes, and accounts.
+type TokenKey struct {
+	// ClientID is the application (client) ID.
+	ClientID string
+
+	// Scopes are the permissions granted to the token.
+	Scopes []string
+
+	// Account optionally identifies the signed in user, e.g. a userPrincipalName.
+	Account string
+}
+
+// String returns a canonical form of the key, which ignores the order and case of the scopes.
+func (k TokenKey) String() string {
+	scopes := make([]string, 0, len(k.Scopes))
+	seen := make(map[string]bool, len(k.Scopes))
+	for _, scope := range k.Scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if scope == "" || seen[scope] {
+			continue
+		}
+		seen[scope] = true
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+
+	return k.ClientID + "|" + strings.Join(scopes, " ") + "|" + k.Account
+}
+
+// TokenStore loads and saves OAuth2 tokens.
+//
+// Implementations must be safe for concurrent use.
+type TokenStore interface {
+	// Load returns the token for key, or ErrTokenNotFound if there is none.
+	Load(key TokenKey) (*oauth2.Token, error)
+
+	// Save stores the token for key, replacing any existing token.
+	Save(key TokenKey, token *oauth2.Token) error
+}
+
+// TokenStoreError is returned when a token can't be loaded from, saved
+// to, or locked in a TokenStore. Err is the error of the TokenStore.
+type TokenStoreError struct {
+	// Op is the operation that failed: "load", "save", or "lock".
+	Op string
+
+	// Key identifies the token.
+	Key TokenKey
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *TokenStoreError) Error() string {
+	return fmt.Sprintf("msgraph4go: token store %s failed: %v", e.Op, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *TokenStoreError) Unwrap() error {
+	return e.Err
+}
+
+// TokenLocker can be implemented by a TokenStore shared by several
+// processes, so only one of them refreshes an expired token at a time.
+type TokenLocker interface {
+	// Lock acquires an exclusive lock for key, returning a function to release the lock.
+	Lock(key TokenKey) (unlock func(), err error)
+}
+
+// WithTokenStore sets the TokenStore used to load and save tokens,
+// instead of the token file given to New.
+func WithTokenStore(store TokenStore) Option {
+	return func(o *options) {
+		o.tokenStore = store
+	}
+}
+
+// WithAccount sets the account used in the TokenKey, so one TokenStore can
+// hold tokens for several users. The account is also used as a login hint.
+func WithAccount(account string) Option {
+	return func(o *options) {
+		o.account = account
+	}
+}
+
+// MemoryTokenStore is a TokenStore that keeps tokens in memory.
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]*oauth2.Token
+}
+
+// NewMemoryTokenStore returns an empty MemoryTokenStore.
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string]*oauth2.Token)}
+}
+
+// Load returns the token for key, or ErrTokenNotFound if there is none.
+func (s *MemoryTokenStore) Load(key TokenKey) (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.tokens[key.String()]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+
+	// return a copy so the caller can't modify the stored token
+	t := *token
+	return &t, nil
+}
+
+// Save stores the token for key, replacing any existing token.
+func (s *MemoryTokenStore) Save(key TokenKey, token *oauth2.Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := *token
+	s.tokens[key.String()] = &t
+
+	return nil
+}
+
+// FileTokenStore is a TokenStore that keeps tokens in a JSON file, which is
+// only readable by the owner and can be shared by several processes.
+//
+// A file containing a single token, as written by earlier versions, is
+// read as the token for any key and converted when a token is saved.
+type FileTokenStore struct {
+	fileName string
+
+	// seal and open encrypt and decrypt the file contents, if not nil
+	seal func(plaintext []byte) ([]byte, error)
+	open func(ciphertext []byte) ([]byte, error)
+}
+
+// tokenFile is the contents of a FileTokenStore.
+type tokenFile struct {
+	Tokens map[string]*oauth2.Token `json:"tokens"`
+}
+
+// NewFileTokenStore returns a FileTokenStore that keeps tokens in fileName.
+func NewFileTokenStore(fileName string) *FileTokenStore {
+	return &FileTokenStore{fileName: fileName}
+}
+
+// Load returns the token for key, or ErrTokenNotFound if there is none.
+func (s *FileTokenStore) Load(key TokenKey) (*oauth2.Token, error) {
+	file, legacy, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+
+	if legacy != nil {
+		return legacy, nil
+	}
+
+	token, ok := file.Tokens[key.String()]
+	if !ok {
+		return nil, ErrTokenNotFound
+	}
+
+	return token, nil
+}
+
+// Save stores the token for key, replacing any existing token.
+func (s *FileTokenStore) Save(key TokenKey, token *oauth2.Token) error {
+	unlock, err := lockFile(s.fileName)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	file, _, err := s.read()
+	if err != nil && err != ErrTokenNotFound {
+		return err
+	}
+	if file.Tokens == nil {
+		file.Tokens = make(map[string]*oauth2.Token)
+	}
+
+	file.Tokens[key.String()] = token
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	if s.seal != nil {
+		data, err = s.seal(data)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeFileAtomic(s.fileName, data)
+}
+
+// Lock acquires an exclusive lock for refreshing the token for key.
+func (s *FileTokenStore) Lock(key TokenKey) (unlock func(), err error) {
+	return lockFile(s.fileName + ".refresh")
+}
+
+// read returns the contents of the file, or a legacy single token.
+func (s *FileTokenStore) read() (file tokenFile, legacy *oauth2.Token, err error) {
+	data, err := ioutil.ReadFile(s.fileName)
+	if os.IsNotExist(err) {
+		return file, nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return file, nil, err
+	}
+
+	if s.open != nil {
+		data, err = s.open(data)
+		if err != nil {
+			return file, nil, err
+		}
+	}
+
+	// check for a file with a single token
+	var probe map[string]json.RawMessage
+	err = json.Unmarshal(data, &probe)
+	if err != nil {
+		return file, nil, err
+	}
+	if _, ok := probe["access_token"]; ok {
+		legacy = &oauth2.Token{}
+		err = json.Unmarshal(data, legacy)
+		return file, legacy, err
+	}
+
+	err = json.Unmarshal(data, &file)
+
+	return file, nil, err
+}
+
+// writeFileAtomic replaces fileName with data.
+//
+// The data is written to a temporary file, readable only by the owner,
+// which is renamed to fileName so readers never see a partial file.
+func writeFileAtomic(fileName string, data []byte) (err error) {
+	file, err := ioutil.TempFile(filepath.Dir(fileName), filepath.Base(fileName)+".tmp*")
+	if err != nil {
+		return err
+	}
+
+	// remove the temporary file if it wasn't renamed
+	defer func() {
+		if err != nil {
+			os.Remove(file.Name())
+		}
+	}()
+
+	err = file.Chmod(0600)
+	if err == nil {
+		_, err = file.Write(data)
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), fileName)
+}
+
+const (
+	// encryptedFileVersion identifies the format of an encrypted token file.
+	encryptedFileVersion = "msgraph4go-aes256gcm-v1"
+
+	// pbkdf2Iterations is the number of PBKDF2 iterations to derive a key from a passphrase.
+	pbkdf2Iterations = 600000
+)
+
+// encryptedFile is the contents of an encrypted token file.
+type encryptedFile struct {
+	Version    string `json:"version"`
+	Salt       []byte `json:"salt,omitempty"`
+	Iterations int    `json:"iterations,omitempty"`
+	Nonce      []byte `json:"nonce"`
+	Data       []byte `json:"data"`
+}
+
+// NewEncryptedFileTokenStore returns a FileTokenStore that encrypts the
+// tokens in fileName using AES-256-GCM with key, which must be 32 bytes.
+func NewEncryptedFileTokenStore(fileName string, key []byte) (*FileTokenStore, error) {
+	if len(key) != 32 {
+		return nil, errors.New("msgraph4go: encryption key must be 32 bytes")
+	}
+
+	keyFor := func(salt []byte, iterations int) ([]byte, error) {
+		if salt != nil {
+			return nil, errors.New("msgraph4go: token file is encrypted with a passphrase")
+		}
+		return key, nil
+	}
+
+	return newEncryptedFileTokenStore(fileName, keyFor, false), nil
+}
+
+// NewPassphraseFileTokenStore returns a FileTokenStore that encrypts the
+// tokens in fileName using AES-256-GCM with a key derived from passphrase
+// using PBKDF2-SHA256 and a random salt.
+func NewPassphraseFileTokenStore(fileName string, passphrase string) *FileTokenStore {
+	// cache the derived key since deriving it is intentionally slow
+	var mu sync.Mutex
+	var cachedSalt, cachedKey []byte
+
+	keyFor := func(salt []byte, iterations int) ([]byte, error) {
+		if salt == nil {
+			return nil, errors.New("msgraph4go: token file is not encrypted with a passphrase")
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if cachedKey == nil || !hmac.Equal(salt, cachedSalt) {
+			cachedKey = pbkdf2.Key([]byte(passphrase), salt, iterations, 32, sha256.New)
+			cachedSalt = salt
+		}
+
+		return cachedKey, nil
+	}
+
+	return newEncryptedFileTokenStore(fileName, keyFor, true)
+}
+
+// newEncryptedFileTokenStore returns a FileTokenStore that encrypts the
+// file with the key returned by keyFor for the salt and iterations.
+func newEncryptedFileTokenStore(fileName string, keyFor func(salt []byte, iterations int) ([]byte, error), passphrase bool) *FileTokenStore {
+	s := &FileTokenStore{fileName: fileName}
+
+	s.seal = func(plaintext []byte) ([]byte, error) {
+		file := encryptedFile{Version: encryptedFileVersion}
+
+		if passphrase {
+			// reuse the salt of the existing file, to avoid deriving a new key
+			file.Salt, file.Iterations = existingSalt(fileName)
+			if file.Salt == nil {
+				file.Salt = make([]byte, 16)
+				if _, err := rand.Read(file.Salt); err != nil {
+					return nil, err
+				}
+				file.Iterations = pbkdf2Iterations
+			}
+		}
+
+		key, err := keyFor(file.Salt, file.Iterations)
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+
+		file.Nonce = make([]byte, aead.NonceSize())
+		if _, err := rand.Read(file.Nonce); err != nil {
+			return nil, err
+		}
+
+		file.Data = aead.Seal(nil, file.Nonce, plaintext, []byte(encryptedFileVersion))
+
+		return json.Marshal(file)
+	}
+
+	s.open = func(ciphertext []byte) ([]byte, error) {
+		var file encryptedFile
+		if err := json.Unmarshal(ciphertext, &file); err != nil {
+			return nil, err
+		}
+		if file.Version != encryptedFileVersion {
+			return nil, errors.New("msgraph4go: token file is not encrypted")
+		}
+
+		key, err := keyFor(file.Salt, file.Iterations)
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+
+		plaintext, err := aead.Open(nil, file.Nonce, file.Data, []byte(encryptedFileVersion))
+		if err != nil {
+			return nil, errors.New("msgraph4go: unable to decrypt token file, wrong key or passphrase")
+		}
+
+		return plaintext, nil
+	}
+
+	return s
+}
+
+// existingSalt returns the salt and iterations of an existing encrypted file, if any.
+func existingSalt(fileName string) ([]byte, int) {
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, 0
+	}
+
+	var file encryptedFile
+	if json.Unmarshal(data, &file) != nil || file.Version != encryptedFileVersion {
+		return nil, 0
+	}
+
+	return file.Salt, file.Iterations
+}
+
+// newGCM returns an AES-GCM AEAD for key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,208 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/oauth2"
+)
+
+var testTokenKey = TokenKey{ClientID: "client", Scopes: []string{"User.Read"}}
+
+func testToken() *oauth2.Token {
+	return &oauth2.Token{
+		AccessToken:  "access-token",
+		RefreshToken: "refresh-token",
+		TokenType:    "Bearer",
+		Expiry:       time.Date(2021, 4, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// TestPBKDF2SHA256 checks the key derivation against RFC 7914 section 11.
+func TestPBKDF2SHA256(t *testing.T) {
+	want, _ := hex.DecodeString("55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc" +
+		"49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783")
+
+	got := pbkdf2.Key([]byte("passwd"), []byte("salt"), 1, 64, sha256.New)
+	if !bytes.Equal(got, want) {
+		t.Errorf("pbkdf2.Key = %x, want %x", got, want)
+	}
+}
+
+func TestEncryptedFileTokenStore(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "token.json")
+	key := bytes.Repeat([]byte{1}, 32)
+
+	store, err := NewEncryptedFileTokenStore(fileName, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Save(testTokenKey, testToken()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("refresh-token")) {
+		t.Error("token file contains the refresh token in plaintext")
+	}
+
+	token, err := store.Load(testTokenKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.RefreshToken != "refresh-token" || !token.Expiry.Equal(testToken().Expiry) {
+		t.Errorf("Load = %+v, want %+v", token, testToken())
+	}
+
+	if _, err := NewEncryptedFileTokenStore(fileName, key[:16]); err == nil {
+		t.Error("NewEncryptedFileTokenStore accepted a 16 byte key")
+	}
+
+	wrongKey, _ := NewEncryptedFileTokenStore(fileName, bytes.Repeat([]byte{2}, 32))
+	if _, err := wrongKey.Load(testTokenKey); err == nil {
+		t.Error("Load succeeded with the wrong key")
+	}
+
+	if _, err := NewPassphraseFileTokenStore(fileName, "passphrase").Load(testTokenKey); err == nil {
+		t.Error("Load succeeded with a passphrase for a file encrypted with a key")
+	}
+}
+
+func TestPassphraseFileTokenStore(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "token.json")
+
+	store := NewPassphraseFileTokenStore(fileName, "correct horse")
+	if err := store.Save(testTokenKey, testToken()); err != nil {
+		t.Fatal(err)
+	}
+
+	var file encryptedFile
+	data, _ := ioutil.ReadFile(fileName)
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.Iterations != pbkdf2Iterations || len(file.Salt) != 16 {
+		t.Errorf("iterations = %d, salt = %d bytes", file.Iterations, len(file.Salt))
+	}
+
+	// a new store must derive the same key from the passphrase and salt
+	token, err := NewPassphraseFileTokenStore(fileName, "correct horse").Load(testTokenKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "access-token" {
+		t.Errorf("AccessToken = %q, want %q", token.AccessToken, "access-token")
+	}
+
+	if _, err := NewPassphraseFileTokenStore(fileName, "wrong horse").Load(testTokenKey); err == nil {
+		t.Error("Load succeeded with the wrong passphrase")
+	}
+}
+
+func TestEncryptedFileTokenStoreTampered(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	tests := []struct {
+		name   string
+		tamper func(file *encryptedFile)
+	}{
+		{"data", func(file *encryptedFile) { file.Data[0] ^= 1 }},
+		{"tag", func(file *encryptedFile) { file.Data[len(file.Data)-1] ^= 1 }},
+		{"nonce", func(file *encryptedFile) { file.Nonce[0] ^= 1 }},
+		{"truncated", func(file *encryptedFile) { file.Data = file.Data[:len(file.Data)-1] }},
+		{"version", func(file *encryptedFile) { file.Version = "msgraph4go-aes256gcm-v0" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fileName := filepath.Join(t.TempDir(), "token.json")
+
+			store, _ := NewEncryptedFileTokenStore(fileName, key)
+			if err := store.Save(testTokenKey, testToken()); err != nil {
+				t.Fatal(err)
+			}
+
+			var file encryptedFile
+			data, _ := ioutil.ReadFile(fileName)
+			if err := json.Unmarshal(data, &file); err != nil {
+				t.Fatal(err)
+			}
+
+			tt.tamper(&file)
+
+			data, _ = json.Marshal(file)
+			if err := ioutil.WriteFile(fileName, data, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if token, err := store.Load(testTokenKey); err == nil {
+				t.Errorf("Load = %+v, want error", token)
+			}
+		})
+	}
+}
+
+func TestLockFileHeldIsNotStale(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the lock to be refreshed")
+	}
+
+	fileName := filepath.Join(t.TempDir(), "token.json")
+	lockName := fileName + ".lock"
+
+	unlock, err := lockFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// age the lock as if the refresh were slow, the holder should refresh it
+	old := time.Now().Add(-2 * lockStale)
+	if err := os.Chtimes(lockName, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(lockStale/3 + time.Second)
+
+	info, err := os.Stat(lockName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(info.ModTime()) > lockStale {
+		t.Errorf("lock modification time %v was not refreshed", info.ModTime())
+	}
+
+	unlock()
+	unlock()
+
+	if _, err := os.Stat(lockName); !os.IsNotExist(err) {
+		t.Errorf("lock file exists after unlock: %v", err)
+	}
+}
@@ -34,7 +34,7 @@ type Attachment struct {
 	IsInline bool `json:"isInline,omitempty"`
 
 	// LastModifiedDateTime is when the attachment was last modified.
-	LastModifiedDateTime string `json:"lastModifiedDateTime,omitempty"`
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
 
 	// Name is the attachment's file name.
 	Name string `json:"name,omitempty"`
@@ -43,6 +43,18 @@ type Attachment struct {
 	Size int `json:"size,omitempty"`
 }
 
+// Attendee is an event attendee.
+type Attendee struct {
+	// EmailAddress includes the name and SMTP address of the attendee.
+	EmailAddress *EmailAddress `json:"emailAddress,omitempty"`
+
+	// Status is the attendee's response for the event. Read-only.
+	Status *ResponseStatus `json:"status,omitempty"`
+
+	// Type of attendee: required, optional, or resource.
+	Type string `json:"type,omitempty"`
+}
+
 // BaseItem is an abstract resource that contains a common set of
 // properties shared among several other resources types.
 // Resources that derive from baseItem include: drive, driveItem, site, sharedDriveItem
@@ -54,7 +66,7 @@ type BaseItem struct {
 	CreatedBy *IdentitySet `json:"createdBy,omitempty"`
 
 	// Date and time of item creation. Read-only.
-	CreatedDateTime string `json:"createdDateTime,omitempty"`
+	CreatedDateTime *Timestamp `json:"createdDateTime,omitempty"`
 
 	// Provides a user-visible description of the item. Optional.
 	Description string `json:"description,omitempty"`
@@ -66,7 +78,7 @@ type BaseItem struct {
 	LastModifiedBy *IdentitySet `json:"lastModifiedBy,omitempty"`
 
 	// Date and time the item was last modified. Read-only.
-	LastModifiedDateTime string `json:"lastModifiedDateTime,omitempty"`
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
 
 	// The name of the item. Read-write.
 	Name string `json:"name,omitempty"`
@@ -160,7 +172,7 @@ type Contact struct {
 	// The contact's birthday. The Timestamp type represents date and time information
 	// using ISO 8601 format and is always in UTC time. For example, midnight UTC on
 	// Jan 1, 2014 would look like this: '2014-01-01T00:00:00Z'
-	Birthday string `json:"birthday"`
+	Birthday *Timestamp `json:"birthday,omitempty"`
 
 	// The contact's business address.
 	BusinessAddress struct {
@@ -190,7 +202,7 @@ type Contact struct {
 	// The time the contact was created. The Timestamp type represents date and time
 	// information using ISO 8601 format and is always in UTC time. For example,
 	// midnight UTC on Jan 1, 2014 would look like this: '2014-01-01T00:00:00Z'
-	CreatedDateTime string `json:"createdDateTime"`
+	CreatedDateTime *Timestamp `json:"createdDateTime,omitempty"`
 
 	// The contact's department.
 	Department string `json:"department"`
@@ -238,7 +250,7 @@ type Contact struct {
 	// The time the contact was modified. The Timestamp type represents date and time
 	// information using ISO 8601 format and is always in UTC time. For example, midnight
 	// UTC on Jan 1, 2014 would look like this: '2014-01-01T00:00:00Z'
-	LastModifiedDateTime string `json:"lastModifiedDateTime"`
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
 
 	// The name of the contact's manager.
 	Manager string `json:"manager"`
@@ -352,7 +364,7 @@ type DriveItem struct {
 	CreatedBy *IdentitySet `json:"createdBy,omitempty"`
 
 	// Date and time of item creation. Read-only.
-	CreatedDateTime string `json:"createdDateTime,omitempty"`
+	CreatedDateTime *Timestamp `json:"createdDateTime,omitempty"`
 
 	// An eTag for the content of the item.
 	// This eTag is not changed if only the metadata is changed.
@@ -384,7 +396,7 @@ type DriveItem struct {
 	LastModifiedBy *IdentitySet `json:"lastModifiedBy,omitempty"`
 
 	// Date and time the item was last modified. Read-only.
-	LastModifiedDateTime string `json:"lastModifiedDateTime,omitempty"`
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
 
 	// TODO: location
 
@@ -438,7 +450,7 @@ type DriveItemVersion struct {
 	LastModifiedBy IdentitySet `json:"lastModifiedBy"`
 
 	// LastModifiedDateTime is Date and time the version was last modified. Read-only.
-	LastModifiedDateTime string `json:"lastModifiedDateTime"`
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
 
 	// Publication indicates the publication status of this particular version. Read-only.
 	Publication *PublicationFacet `json:"publication,omitempty"`
@@ -464,6 +476,95 @@ type EmailAddress struct {
 	Name string `json:"name,omitempty"`
 }
 
+// Event is an event in a user calendar, or the default calendar of a group.
+type Event struct {
+	OData
+
+	// Attendees is the collection of attendees for the event.
+	Attendees []Attendee `json:"attendees,omitempty"`
+
+	// Body of the message associated with the event, in HTML or text format.
+	Body *ItemBody `json:"body,omitempty"`
+
+	// BodyPreview is the preview of the message associated with the event, in text format.
+	BodyPreview string `json:"bodyPreview,omitempty"`
+
+	// Categories associated with the event.
+	Categories []string `json:"categories,omitempty"`
+
+	// ChangeKey is the version of the event.
+	ChangeKey string `json:"changeKey,omitempty"`
+
+	// CreatedDateTime when the event was created.
+	CreatedDateTime *Timestamp `json:"createdDateTime,omitempty"`
+
+	// End is the date, time, and time zone that the event ends.
+	End *DateTimeTimeZone `json:"end,omitempty"`
+
+	// ICalUID is a unique identifier for the event across calendars.
+	ICalUID string `json:"iCalUId,omitempty"`
+
+	// ID is the unique identifier for the event. Read-only.
+	ID string `json:"id,omitempty"`
+
+	// Importance of the event: low, normal, high.
+	Importance string `json:"importance,omitempty"`
+
+	// IsAllDay is true if the event lasts all day.
+	IsAllDay bool `json:"isAllDay,omitempty"`
+
+	// IsCancelled is true if the event has been canceled.
+	IsCancelled bool `json:"isCancelled,omitempty"`
+
+	// IsOnlineMeeting is true if the event has online meeting information.
+	IsOnlineMeeting bool `json:"isOnlineMeeting,omitempty"`
+
+	// IsOrganizer is true if the calendar owner is the organizer of the event.
+	IsOrganizer bool `json:"isOrganizer,omitempty"`
+
+	// IsReminderOn is true if an alert is set to remind the user of the event.
+	IsReminderOn bool `json:"isReminderOn,omitempty"`
+
+	// LastModifiedDateTime is the date and time the event was last changed.
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
+
+	// Location of the event.
+	Location *Location `json:"location,omitempty"`
+
+	// Organizer of the event.
+	Organizer *Recipient `json:"organizer,omitempty"`
+
+	// ReminderMinutesBeforeStart is the number of minutes before the event start time that the reminder alert occurs.
+	ReminderMinutesBeforeStart int `json:"reminderMinutesBeforeStart,omitempty"`
+
+	// ResponseStatus indicates the type of response sent in response to an event message.
+	ResponseStatus *ResponseStatus `json:"responseStatus,omitempty"`
+
+	// SeriesMasterID is the ID for the recurring series master item, if this event is part of a recurring series.
+	SeriesMasterID string `json:"seriesMasterId,omitempty"`
+
+	// ShowAs is the status to show: free, tentative, busy, oof, workingElsewhere, unknown.
+	ShowAs string `json:"showAs,omitempty"`
+
+	// Start is the date, time, and time zone that the event starts.
+	Start *DateTimeTimeZone `json:"start,omitempty"`
+
+	// Subject is the text of the event's subject line.
+	Subject string `json:"subject,omitempty"`
+
+	// Type of event: singleInstance, occurrence, exception, seriesMaster. Read-only.
+	Type string `json:"type,omitempty"`
+
+	// WebLink is the URL to open the event in Outlook on the web.
+	WebLink string `json:"webLink,omitempty"`
+}
+
+// EventCollection is a collection of Event types
+type EventCollection struct {
+	OData
+	Value []Event `json:"value"`
+}
+
 // Extension is an abstract type to support the OData v4 open type openTypeExtension.
 type Extension struct {
 	ID string `json:"id"`
@@ -489,13 +590,13 @@ type File struct {
 // local file system for the local version of an item.
 type FileSystemInfo struct {
 	// The UTC date and time the file was created on a client.
-	CreatedDateTime string `json:"createdDateTime,omitempty"`
+	CreatedDateTime *Timestamp `json:"createdDateTime,omitempty"`
 
 	// The UTC date and time the file was last accessed. Available for the recent file list only.
-	LastAccessedDateTime string `json:"lastAccessedDateTime,omitempty"`
+	LastAccessedDateTime *Timestamp `json:"lastAccessedDateTime,omitempty"`
 
 	// The UTC date and time the file was last modified on a client.
-	LastModifiedDateTime string `json:"lastModifiedDateTime,omitempty"`
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
 }
 
 // Folder groups folder-related data on an item into a single structure.
@@ -611,6 +712,21 @@ type ItemReference struct {
 	SharepointIds *SharepointIds `json:"sharepointIds,omitempty"`
 }
 
+// Location represents location information of an event.
+type Location struct {
+	// Address is the street address of the location.
+	Address *PhysicalAddress `json:"address,omitempty"`
+
+	// DisplayName is the name associated with the location.
+	DisplayName string `json:"displayName,omitempty"`
+
+	// LocationEmailAddress is the optional email address of the location.
+	LocationEmailAddress string `json:"locationEmailAddress,omitempty"`
+
+	// LocationType is the type of location, e.g. default, conferenceRoom, or hotel.
+	LocationType string `json:"locationType,omitempty"`
+}
+
 // Message is a message in a mailFolder.
 type Message struct {
 	OData
@@ -640,7 +756,7 @@ type Message struct {
 	ConversationIndex string `json:"conversationIndex,omitempty"`
 
 	// CreatedDateTime when the message was created.
-	CreatedDateTime string `json:"createdDateTime,omitempty"`
+	CreatedDateTime *Timestamp `json:"createdDateTime,omitempty"`
 
 	// Flag indicates the status, start date, due date, or completion date for the message.
 	Flag FollowupFlag `json:"flag,omitempty"`
@@ -690,13 +806,13 @@ type Message struct {
 	IsReadReceiptRequested bool `json:"isReadReceiptRequested"`
 
 	// LastModifiedDateTime is the date and time the message was last changed.
-	LastModifiedDateTime string `json:"lastModifiedDateTime,omitempty"`
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
 
 	// ParentFolderID is the unique identifier for the message's parent mailFolder.
 	ParentFolderID string `json:"parentFolderId,omitempty"`
 
 	// ReceivedDateTime is the date and time the message was received.
-	ReceivedDateTime string `json:"receivedDateTime,omitempty"`
+	ReceivedDateTime *Timestamp `json:"receivedDateTime,omitempty"`
 
 	// ReplyTo is the email addresses to use when replying.
 	ReplyTo []Recipient `json:"replyTo"`
@@ -709,7 +825,7 @@ type Message struct {
 	Sender *Recipient `json:"sender,omitempty"`
 
 	// SentDateTime is the date and time the message was sent.
-	SentDateTime string `json:"sentDateTime,omitempty"`
+	SentDateTime *Timestamp `json:"sentDateTime,omitempty"`
 
 	// Subject is the subject of the message.
 	Subject string `json:"subject,omitempty"`
@@ -773,7 +889,7 @@ type Notebook struct {
 	CreatedBy IdentitySet `json:"createdBy"`
 
 	// The date and time when the notebook was created. Read-only.
-	CreatedDateTime string `json:"createdDateTime"`
+	CreatedDateTime *Timestamp `json:"createdDateTime,omitempty"`
 
 	// The unique identifier of the notebook. Read-only.
 	ID string `json:"id"`
@@ -788,7 +904,7 @@ type Notebook struct {
 	LastModifiedBy IdentitySet `json:"lastModifiedBy"`
 
 	// The date and time when the notebook was last modified. Read-only.
-	LastModifiedDateTime string `json:"lastModifiedDateTime"`
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
 
 	// Links for opening the notebook.
 	Links NotebookLinks `json:"links"`
@@ -858,13 +974,13 @@ type Page struct {
 	CreatedByAppId string `json:"createdByAppId"`
 
 	// The date and time when the page was created.
-	CreatedDateTime string `json:"createdDateTime"`
+	CreatedDateTime *Timestamp `json:"createdDateTime,omitempty"`
 
 	// The unique identifier of the page. Read-only.
 	ID string `json:"id"`
 
 	// The date and time when the page was last modified.
-	LastModifiedDateTime string `json:"lastModifiedDateTime"`
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
 
 	// The indentation level of the page. Read-only.
 	Level int32 `json:"level"`
@@ -1023,7 +1139,7 @@ type RemoteItem struct {
 	CreatedBy *IdentitySet `json:"createdBy,omitempty"`
 
 	// Date and time of item creation. Read-only.
-	CreatedDateTime string `json:"createdDateTime,omitempty"`
+	CreatedDateTime *Timestamp `json:"createdDateTime,omitempty"`
 
 	// Indicates that the remote item is a file. Read-only.
 	File *File `json:"file,omitempty"`
@@ -1041,7 +1157,7 @@ type RemoteItem struct {
 	LastModifiedBy *IdentitySet `json:"lastModifiedBy,omitempty"`
 
 	// Date and time the item was last modified. Read-only.
-	LastModifiedDateTime string `json:"lastModifiedDateTime,omitempty"`
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
 
 	// Optional. Filename of the remote item. Read-only.
 	Name string `json:"name,omitempty"`
@@ -1071,13 +1187,22 @@ type RemoteItem struct {
 	WebURL string `json:"webUrl,omitempty"`
 }
 
+// ResponseStatus is the response status of an attendee or organizer for a meeting request.
+type ResponseStatus struct {
+	// Response type: none, organizer, tentativelyAccepted, accepted, declined, notResponded.
+	Response string `json:"response,omitempty"`
+
+	// Time is the date and time that the response was returned.
+	Time *Timestamp `json:"time,omitempty"`
+}
+
 // Section represents a section in a OneNote notebook. Sections can contain pages.
 type Section struct {
 	// Identity of the user, device, and application which created the item. Read-only.
 	CreatedBy IdentitySet `json:"createdBy"`
 
 	// The date and time when the section was created. Read-only.
-	CreatedDateTime string `json:"createdDateTime"`
+	CreatedDateTime *Timestamp `json:"createdDateTime,omitempty"`
 
 	// The unique identifier of the section. Read-only.
 	ID string `json:"id"`
@@ -1089,7 +1214,7 @@ type Section struct {
 	LastModifiedBy IdentitySet `json:"lastModifiedBy"`
 
 	// The date and time when the section was last modified.
-	LastModifiedDateTime string `json:"lastModifiedDateTime"`
+	LastModifiedDateTime *Timestamp `json:"lastModifiedDateTime,omitempty"`
 
 	// Links for opening the section.
 	Links NotebookLinks `json:"links"`
@@ -1182,6 +1307,56 @@ type SingleValueLegacyExtendedProperty struct {
 	Value string `json:"value"`
 }
 
+// Subscription allows a client app to receive change notifications about changes to data in Microsoft Graph.
+// See https://docs.microsoft.com/en-us/graph/api/resources/subscription?view=graph-rest-1.0
+type Subscription struct {
+	OData
+
+	// ApplicationID is the ID of the application used to create the subscription. Read-only.
+	ApplicationID string `json:"applicationId,omitempty"`
+
+	// ChangeType is the type of change that raises a notification: created, updated, deleted.
+	// Multiple values can be combined using a comma-separated list.
+	ChangeType string `json:"changeType,omitempty"`
+
+	// ClientState is sent in each notification, so the notification can be verified. Up to 128 characters.
+	ClientState string `json:"clientState,omitempty"`
+
+	// CreatorID is the ID of the user or service principal that created the subscription. Read-only.
+	CreatorID string `json:"creatorId,omitempty"`
+
+	// EncryptionCertificate is the base64 encoded public key certificate used to
+	// encrypt resource data included in notifications.
+	EncryptionCertificate string `json:"encryptionCertificate,omitempty"`
+
+	// EncryptionCertificateID identifies the certificate used to encrypt resource data.
+	EncryptionCertificateID string `json:"encryptionCertificateId,omitempty"`
+
+	// ExpirationDateTime is when the subscription expires, unless it is renewed.
+	ExpirationDateTime *Timestamp `json:"expirationDateTime,omitempty"`
+
+	// ID is the unique identifier for the subscription. Read-only.
+	ID string `json:"id,omitempty"`
+
+	// IncludeResourceData is true if notifications include the encrypted resource data.
+	IncludeResourceData bool `json:"includeResourceData,omitempty"`
+
+	// LifecycleNotificationURL is the URL of the endpoint that receives lifecycle notifications.
+	LifecycleNotificationURL string `json:"lifecycleNotificationUrl,omitempty"`
+
+	// NotificationURL is the URL of the endpoint that receives the notifications. It must use HTTPS.
+	NotificationURL string `json:"notificationUrl,omitempty"`
+
+	// Resource is the resource that is monitored for changes, e.g. "me/mailFolders('Inbox')/messages".
+	Resource string `json:"resource,omitempty"`
+}
+
+// SubscriptionCollection is a collection of Subscription types
+type SubscriptionCollection struct {
+	OData
+	Value []Subscription `json:"value"`
+}
+
 // User represents an Azure AD user account
 // Not all of the properties have been included from
 // https://docs.microsoft.com/en-us/graph/api/resources/user?view=graph-rest-1.0
@@ -17,20 +17,25 @@ limitations under the License.
 package msgraph4go
 
 import (
-	"encoding/json"
+	"context"
 	"net/url"
 )
 
 // GetMyProfile returns the user profile of the current user.
 func (c *MSGraphClient) GetMyProfile(query url.Values) (response User, err error) {
+	return c.GetMyProfileWithContext(context.Background(), query)
+}
+
+// GetMyProfileWithContext is like GetMyProfile, but uses ctx for the request.
+func (c *MSGraphClient) GetMyProfileWithContext(ctx context.Context, query url.Values) (response User, err error) {
 	var body []byte
 
-	body, err = c.Get("/me", query)
+	body, err = c.GetWithContext(ctx, "/me", query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
@@ -39,26 +44,52 @@ func (c *MSGraphClient) GetMyProfile(query url.Values) (response User, err error
 //
 // Photos are not supported on personal (consumer) accounts.
 func (c *MSGraphClient) GetMyPhotoInfo(query url.Values) (response ProfilePhoto, err error) {
+	return c.GetMyPhotoInfoWithContext(context.Background(), query)
+}
+
+// GetMyPhotoInfoWithContext is like GetMyPhotoInfo, but uses ctx for the request.
+func (c *MSGraphClient) GetMyPhotoInfoWithContext(ctx context.Context, query url.Values) (response ProfilePhoto, err error) {
 	var body []byte
 
-	body, err = c.Get("/me/photo", query)
+	body, err = c.GetWithContext(ctx, "/me/photo", query)
 	if err != nil {
 		return response, err
 	}
 
-	err = json.Unmarshal(body, &response)
+	err = decodeBody(body, &response)
 
 	return response, err
 }
 
 // GetMyPhoto returns the photo of the current user.
 func (c *MSGraphClient) GetMyPhoto(query url.Values) (response []byte, err error) {
+	return c.GetMyPhotoWithContext(context.Background(), query)
+}
+
+// GetMyPhotoWithContext is like GetMyPhoto, but uses ctx for the request.
+func (c *MSGraphClient) GetMyPhotoWithContext(ctx context.Context, query url.Values) (response []byte, err error) {
 	var body []byte
 
-	body, err = c.Get("/me/photo/$value", query)
+	body, err = c.GetWithContext(ctx, "/me/photo/$value", query)
 	if err != nil {
 		return nil, err
 	}
 
 	return body, err
 }
+
+// GetMyPhotoStream is like GetMyPhoto, but returns the photo as a Stream.
+func (c *MSGraphClient) GetMyPhotoStream(query url.Values) (stream *Stream, err error) {
+	return c.GetMyPhotoStreamWithContext(context.Background(), query)
+}
+
+// GetMyPhotoStreamWithContext is like GetMyPhotoStream, but uses ctx for the request.
+func (c *MSGraphClient) GetMyPhotoStreamWithContext(ctx context.Context, query url.Values) (stream *Stream, err error) {
+	return c.GetStreamWithContext(ctx, "/me/photo/$value", query)
+}
+
+// UsersDelta returns a Delta that tracks changes to the users of the organization.
+// Change.Item is a *User.
+func (c *MSGraphClient) UsersDelta(query url.Values, store CheckpointStore) *Delta {
+	return c.NewDelta("/users/delta", query, store, func() interface{} { return &User{} })
+}
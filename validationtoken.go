@@ -0,0 +1,285 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInvalidValidationToken is returned when a validation token of a
+// notification is not valid, so the notification may not have been sent
+// by Microsoft Graph.
+var ErrInvalidValidationToken = errors.New("msgraph4go: invalid validation token")
+
+const (
+	// defaultKeysURL is the URL of the keys used to sign validation tokens.
+	defaultKeysURL = "https://login.microsoftonline.com/common/discovery/v2.0/keys"
+
+	// changeTrackingAppID is the ID of the application that sends change
+	// notifications, which is the azp claim of validation tokens.
+	changeTrackingAppID = "0bf30f3b-4a52-48df-9a82-234910c4a086"
+
+	// keysRefreshInterval is how often the keys are refreshed.
+	keysRefreshInterval = 24 * time.Hour
+
+	// keysMinRefreshInterval limits how often the keys are refreshed for an unknown key ID.
+	keysMinRefreshInterval = 5 * time.Minute
+
+	// tokenClockSkew is the allowed difference between clocks when checking
+	// the expiration of a token.
+	tokenClockSkew = 5 * time.Minute
+)
+
+// TokenValidator validates the validationTokens of change notifications
+// that include resource data, and of lifecycle notifications.
+//
+// A token is valid if it is signed by one of the published keys of the
+// Microsoft identity platform, has not expired, was issued to the change
+// notification service for the application, and, if TenantIDs is set, was
+// issued by one of those tenants.
+//
+// See https://docs.microsoft.com/en-us/graph/webhooks-with-resource-data#validation-tokens-in-the-change-notification
+type TokenValidator struct {
+	// ClientID is the application ID, which must be the audience of the token.
+	ClientID string
+
+	// TenantIDs, if set, are the only tenants allowed to issue the token.
+	TenantIDs []string
+
+	// KeysURL is the URL of the signing keys, in JSON Web Key Set format.
+	KeysURL string
+
+	// HTTPClient is used to get the signing keys, or http.DefaultClient if nil.
+	HTTPClient *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+}
+
+// NewTokenValidator returns a TokenValidator for the application clientID,
+// allowing tokens issued by any of tenantIDs, or any tenant if none are given.
+func NewTokenValidator(clientID string, tenantIDs ...string) *TokenValidator {
+	return &TokenValidator{
+		ClientID:  clientID,
+		TenantIDs: tenantIDs,
+		KeysURL:   defaultKeysURL,
+	}
+}
+
+// validationClaims are the claims of a validation token that are checked.
+type validationClaims struct {
+	Audience  string `json:"aud"`
+	Issuer    string `json:"iss"`
+	TenantID  string `json:"tid"`
+	AppID     string `json:"azp"`
+	NotBefore int64  `json:"nbf"`
+	Expires   int64  `json:"exp"`
+}
+
+// Validate returns nil if token is a valid validation token, otherwise
+// an error that wraps ErrInvalidValidationToken, or the error getting the
+// signing keys.
+func (v *TokenValidator) Validate(ctx context.Context, token string) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: malformed token", ErrInvalidValidationToken)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeTokenPart(parts[0], &header); err != nil {
+		return err
+	}
+
+	var claims validationClaims
+	if err := decodeTokenPart(parts[1], &claims); err != nil {
+		return err
+	}
+
+	if header.Alg != "RS256" {
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidValidationToken, header.Alg)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidValidationToken)
+	}
+
+	hash := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature) != nil {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidValidationToken)
+	}
+
+	return v.checkClaims(claims, time.Now())
+}
+
+// checkClaims returns an error if claims are not valid at now.
+func (v *TokenValidator) checkClaims(claims validationClaims, now time.Time) error {
+	switch {
+	case now.After(time.Unix(claims.Expires, 0).Add(tokenClockSkew)):
+		return fmt.Errorf("%w: token expired", ErrInvalidValidationToken)
+	case now.Add(tokenClockSkew).Before(time.Unix(claims.NotBefore, 0)):
+		return fmt.Errorf("%w: token not yet valid", ErrInvalidValidationToken)
+	case claims.Audience != v.ClientID:
+		return fmt.Errorf("%w: audience %q", ErrInvalidValidationToken, claims.Audience)
+	case claims.AppID != changeTrackingAppID:
+		return fmt.Errorf("%w: authorized party %q", ErrInvalidValidationToken, claims.AppID)
+	case claims.Issuer != "https://sts.windows.net/"+claims.TenantID+"/":
+		return fmt.Errorf("%w: issuer %q", ErrInvalidValidationToken, claims.Issuer)
+	}
+
+	if len(v.TenantIDs) == 0 {
+		return nil
+	}
+
+	for _, tenantID := range v.TenantIDs {
+		if strings.EqualFold(tenantID, claims.TenantID) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: tenant %q", ErrInvalidValidationToken, claims.TenantID)
+}
+
+// decodeTokenPart decodes a base64url encoded JSON part of a token into v.
+func decodeTokenPart(part string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return fmt.Errorf("%w: malformed token", ErrInvalidValidationToken)
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%w: malformed token", ErrInvalidValidationToken)
+	}
+
+	return nil
+}
+
+// key returns the signing key with the key ID kid, getting the keys if
+// they haven't been fetched recently.
+func (v *TokenValidator) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	age := time.Since(v.fetchedAt)
+	key, ok := v.keys[kid]
+
+	// refresh stale keys, or for an unknown key ID since the keys are rotated
+	if age > keysRefreshInterval || (!ok && age > keysMinRefreshInterval) {
+		keys, err := v.fetchKeys(ctx)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+		v.fetchedAt = time.Now()
+
+		key, ok = v.keys[kid]
+	}
+
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown key %q", ErrInvalidValidationToken, kid)
+	}
+
+	return key, nil
+}
+
+// jsonWebKeySet is a JSON Web Key Set (RFC 7517).
+type jsonWebKeySet struct {
+	Keys []struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// fetchKeys gets the RSA signing keys from KeysURL, mapped by key ID.
+func (v *TokenValidator) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	keysURL := v.KeysURL
+	if keysURL == "" {
+		keysURL = defaultKeysURL
+	}
+
+	client := v.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, keysURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("msgraph4go: getting signing keys: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("msgraph4go: getting signing keys: %s", resp.Status)
+	}
+
+	var set jsonWebKeySet
+	err = json.NewDecoder(resp.Body).Decode(&set)
+	if err != nil {
+		return nil, fmt.Errorf("msgraph4go: getting signing keys: %w", err)
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			continue
+		}
+
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID = "11111111-1111-1111-1111-111111111111"
+	testTenantID = "22222222-2222-2222-2222-222222222222"
+)
+
+// newKeysServer returns a server of a JSON Web Key Set with the public
+// key of key as kid, counting the requests for the keys in fetches.
+func newKeysServer(t *testing.T, kid string, key *rsa.PrivateKey, fetches *int32) *httptest.Server {
+	t.Helper()
+
+	set := map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(fetches, 1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// signToken returns a RS256 token with claims, signed with key as kid.
+func signToken(t *testing.T, kid string, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	t.Helper()
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// validClaims returns the claims of a valid validation token.
+func validClaims() map[string]interface{} {
+	now := time.Now()
+
+	return map[string]interface{}{
+		"aud": testClientID,
+		"iss": "https://sts.windows.net/" + testTenantID + "/",
+		"tid": testTenantID,
+		"azp": changeTrackingAppID,
+		"nbf": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(time.Hour).Unix(),
+	}
+}
+
+func TestTokenValidatorValidate(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches int32
+	server := newKeysServer(t, "key1", key, &fetches)
+
+	validator := NewTokenValidator(testClientID, testTenantID)
+	validator.KeysURL = server.URL
+	validator.HTTPClient = server.Client()
+
+	with := func(name string, value interface{}) map[string]interface{} {
+		claims := validClaims()
+		claims[name] = value
+		return claims
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"valid", signToken(t, "key1", key, validClaims()), true},
+		{"expired", signToken(t, "key1", key, with("exp", time.Now().Add(-time.Hour).Unix())), false},
+		{"not yet valid", signToken(t, "key1", key, with("nbf", time.Now().Add(time.Hour).Unix())), false},
+		{"wrong aud", signToken(t, "key1", key, with("aud", "33333333-3333-3333-3333-333333333333")), false},
+		{"wrong azp", signToken(t, "key1", key, with("azp", testClientID)), false},
+		{"wrong iss", signToken(t, "key1", key, with("iss", "https://sts.windows.net/other/")), false},
+		{"wrong tenant", signToken(t, "key1", key, with("tid", "other")), false},
+		{"wrong key", signToken(t, "key1", otherKey, validClaims()), false},
+		{"unknown kid", signToken(t, "key2", key, validClaims()), false},
+		{"malformed", "not.a-token", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.Validate(context.Background(), tt.token)
+
+			if tt.valid && err != nil {
+				t.Errorf("Validate = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidValidationToken) {
+				t.Errorf("Validate = %v, want %v", err, ErrInvalidValidationToken)
+			}
+		})
+	}
+
+	// the keys are cached, even for the unknown key ID requested just after
+	if n := atomic.LoadInt32(&fetches); n != 1 {
+		t.Errorf("keys fetched %d times, want 1", n)
+	}
+}
+
+func TestTokenValidatorValidateTampered(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fetches int32
+	server := newKeysServer(t, "key1", key, &fetches)
+
+	validator := NewTokenValidator(testClientID)
+	validator.KeysURL = server.URL
+	validator.HTTPClient = server.Client()
+
+	parts := strings.Split(signToken(t, "key1", key, validClaims()), ".")
+
+	// replace the claims with those for another application, keeping the signature
+	claims := validClaims()
+	claims["aud"] = "33333333-3333-3333-3333-333333333333"
+	payload, _ := json.Marshal(claims)
+	parts[1] = base64.RawURLEncoding.EncodeToString(payload)
+
+	err = validator.Validate(context.Background(), strings.Join(parts, "."))
+	if !errors.Is(err, ErrInvalidValidationToken) || !strings.Contains(err.Error(), "signature") {
+		t.Errorf("Validate = %v, want signature mismatch", err)
+	}
+}
+
+func TestTokenValidatorKeysError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validator := NewTokenValidator(testClientID)
+	validator.KeysURL = server.URL
+
+	err = validator.Validate(context.Background(), signToken(t, "key1", key, validClaims()))
+	if err == nil || errors.Is(err, ErrInvalidValidationToken) {
+		t.Errorf("Validate = %v, want error getting the keys", err)
+	}
+}
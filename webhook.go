@@ -0,0 +1,385 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// maxNotificationSize is the maximum size of a notification request body.
+const maxNotificationSize = 10 << 20
+
+// Lifecycle events of lifecycle notifications, sent to the LifecycleNotificationURL of a Subscription.
+//
+// See https://docs.microsoft.com/en-us/graph/webhooks-lifecycle for more information.
+const (
+	// LifecycleReauthorizationRequired means the subscription must be
+	// reauthorized, e.g. by renewing it, or notifications will stop.
+	LifecycleReauthorizationRequired = "reauthorizationRequired"
+
+	// LifecycleSubscriptionRemoved means the subscription was removed and must be created again.
+	LifecycleSubscriptionRemoved = "subscriptionRemoved"
+
+	// LifecycleMissed means some notifications were not delivered, so the
+	// resource should be synchronized, e.g. with a delta query.
+	LifecycleMissed = "missed"
+)
+
+// ResourceData is the resource data of a change notification.
+//
+// For a notification without resource data, only the properties that
+// identify the resource are set. See https://docs.microsoft.com/en-us/graph/webhooks
+type ResourceData struct {
+	// ODataType is the type of the resource, e.g. "#Microsoft.Graph.Message".
+	ODataType string `json:"@odata.type,omitempty"`
+
+	// ODataID is the path of the resource.
+	ODataID string `json:"@odata.id,omitempty"`
+
+	// ODataETag is the ETag of the resource.
+	ODataETag string `json:"@odata.etag,omitempty"`
+
+	// ID is the ID of the resource.
+	ID string `json:"id,omitempty"`
+}
+
+// ChangeNotification is a notification sent to the notification URL of a Subscription.
+// See https://docs.microsoft.com/en-us/graph/api/resources/changenotification?view=graph-rest-1.0
+type ChangeNotification struct {
+	// ID is the unique ID of the notification.
+	ID string `json:"id,omitempty"`
+
+	// SubscriptionID is the ID of the subscription that generated the notification.
+	SubscriptionID string `json:"subscriptionId"`
+
+	// SubscriptionExpirationDateTime is when the subscription expires.
+	SubscriptionExpirationDateTime *Timestamp `json:"subscriptionExpirationDateTime,omitempty"`
+
+	// ClientState is the ClientState of the subscription.
+	ClientState string `json:"clientState,omitempty"`
+
+	// ChangeType is the type of change: created, updated, or deleted.
+	ChangeType string `json:"changeType,omitempty"`
+
+	// Resource is the URI of the resource that changed, relative to the Graph API base URL.
+	Resource string `json:"resource,omitempty"`
+
+	// TenantID is the ID of the tenant of the resource.
+	TenantID string `json:"tenantId,omitempty"`
+
+	// ResourceData identifies the resource that changed.
+	ResourceData json.RawMessage `json:"resourceData,omitempty"`
+
+	// EncryptedContent is the encrypted resource data, for a subscription with IncludeResourceData set.
+	EncryptedContent *EncryptedContent `json:"encryptedContent,omitempty"`
+
+	// LifecycleEvent is the type of a lifecycle notification, e.g. LifecycleReauthorizationRequired.
+	LifecycleEvent string `json:"lifecycleEvent,omitempty"`
+}
+
+// changeNotificationCollection is the body of a notification request.
+type changeNotificationCollection struct {
+	Value []ChangeNotification `json:"value"`
+
+	// ValidationTokens are sent with notifications that include resource
+	// data, and with lifecycle notifications.
+	ValidationTokens []string `json:"validationTokens,omitempty"`
+}
+
+// Notification is a ChangeNotification received by a WebhookHandler, with
+// the resource data decoded according to its type.
+type Notification struct {
+	ChangeNotification
+
+	// Data identifies the resource that changed.
+	Data ResourceData
+
+	// Item is the resource data decoded as a *Message, *DriveItem, *Contact,
+	// or *Event, based on its @odata.type, otherwise a map[string]interface{}.
+	// Item is nil if the notification has no resource data.
+	//
+	// Unless the resource data is included and decrypted, only the ID of the item is set.
+	Item interface{}
+}
+
+// notificationTypes maps the @odata.type of resource data, in lower case, to a new item.
+var notificationTypes = map[string]func() interface{}{
+	"#microsoft.graph.message":   func() interface{} { return &Message{} },
+	"#microsoft.graph.driveitem": func() interface{} { return &DriveItem{} },
+	"#microsoft.graph.contact":   func() interface{} { return &Contact{} },
+	"#microsoft.graph.event":     func() interface{} { return &Event{} },
+}
+
+// decodeNotification decodes the resource data of n according to its type,
+// decrypting the encrypted content, if any, using the matching key of keys.
+func decodeNotification(n ChangeNotification, keys map[string]*rsa.PrivateKey) (notification Notification, err error) {
+	notification.ChangeNotification = n
+
+	if len(n.ResourceData) != 0 && string(n.ResourceData) != "null" {
+		err = json.Unmarshal(n.ResourceData, &notification.Data)
+		if err != nil {
+			return notification, err
+		}
+	}
+
+	data := []byte(n.ResourceData)
+
+	if n.EncryptedContent != nil {
+		key, ok := keys[n.EncryptedContent.EncryptionCertificateID]
+		if !ok {
+			return notification, fmt.Errorf("msgraph4go: no decryption key for certificate %q",
+				n.EncryptedContent.EncryptionCertificateID)
+		}
+
+		data, err = n.EncryptedContent.Decrypt(key)
+		if err != nil {
+			return notification, err
+		}
+	}
+
+	if len(data) == 0 || string(data) == "null" {
+		return notification, nil
+	}
+
+	// the decrypted data may omit the type, so use the type of the resource data
+	var typed struct {
+		ODataType string `json:"@odata.type"`
+	}
+	err = json.Unmarshal(data, &typed)
+	if err != nil {
+		return notification, err
+	}
+	if typed.ODataType == "" {
+		typed.ODataType = notification.Data.ODataType
+	}
+
+	if newItem, ok := notificationTypes[strings.ToLower(typed.ODataType)]; ok {
+		notification.Item = newItem()
+		err = json.Unmarshal(data, notification.Item)
+	} else {
+		var m map[string]interface{}
+		err = json.Unmarshal(data, &m)
+		notification.Item = m
+	}
+
+	return notification, err
+}
+
+// WebhookHandler is a http.Handler for the notification URL of subscriptions.
+//
+// It answers the validation request sent when a subscription is created
+// or renewed, and decodes the notifications, calling the handle function
+// for each notification with the expected client state.
+//
+// A notification with a different client state is ignored, since it may
+// not have been sent by Microsoft Graph.
+//
+// Encrypted resource data is decrypted using the private keys added by
+// AddDecryptionKey. If SetTokenValidator is used, the validation tokens of
+// notifications with resource data, and of lifecycle notifications, are
+// validated, and the request is rejected if any token is missing or invalid.
+//
+// Every notification of a request is handled, and the request is
+// acknowledged with 202 Accepted even if some fail, since Microsoft Graph
+// would otherwise send all of them again. A notification that can't be
+// decoded, or for which the handle function returns an error, is reported
+// to the function set by SetErrorHandler, or logged if there is none.
+// The handle function should return quickly, since notifications must be
+// acknowledged within 3 seconds, e.g. by queueing the notification.
+type WebhookHandler struct {
+	clientState string
+	handle      func(ctx context.Context, notification Notification) error
+
+	// the settings and keys can be changed while notifications are handled
+	mu        sync.RWMutex
+	logger    Logger
+	validator *TokenValidator
+	onError   func(ctx context.Context, notification Notification, err error)
+	keys      map[string]*rsa.PrivateKey
+}
+
+// NewWebhookHandler returns a WebhookHandler that calls handle for each
+// notification with clientState, which should be a secret value used as
+// the ClientState of the subscriptions.
+func NewWebhookHandler(clientState string, handle func(ctx context.Context, notification Notification) error) *WebhookHandler {
+	return &WebhookHandler{
+		clientState: clientState,
+		handle:      handle,
+		logger:      nopLogger{},
+		keys:        make(map[string]*rsa.PrivateKey),
+	}
+}
+
+// AddDecryptionKey adds the private key of the encryption certificate with
+// the EncryptionCertificateID certificateID, used to decrypt resource data.
+//
+// More than one key can be added, so notifications can be decrypted while
+// the certificate of the subscriptions is changed.
+func (h *WebhookHandler) AddDecryptionKey(certificateID string, key *rsa.PrivateKey) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.keys[certificateID] = key
+}
+
+// decryptionKeys returns a copy of the keys added by AddDecryptionKey.
+func (h *WebhookHandler) decryptionKeys() map[string]*rsa.PrivateKey {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	keys := make(map[string]*rsa.PrivateKey, len(h.keys))
+	for id, key := range h.keys {
+		keys[id] = key
+	}
+
+	return keys
+}
+
+// SetTokenValidator sets the TokenValidator used to validate the validation tokens of notifications.
+func (h *WebhookHandler) SetTokenValidator(validator *TokenValidator) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.validator = validator
+}
+
+// SetLogger sets the Logger used to report rejected notifications.
+func (h *WebhookHandler) SetLogger(logger Logger) {
+	if logger == nil {
+		logger = nopLogger{}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.logger = logger
+}
+
+// SetErrorHandler sets the function called for each notification that
+// can't be decoded, or for which the handle function returns an error,
+// e.g. to retry it later. Only the ChangeNotification is set for a
+// notification that can't be decoded.
+func (h *WebhookHandler) SetErrorHandler(onError func(ctx context.Context, notification Notification, err error)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.onError = onError
+}
+
+// settings returns the settings of the handler.
+func (h *WebhookHandler) settings() (logger Logger, validator *TokenValidator, onError func(ctx context.Context, notification Notification, err error)) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.logger, h.validator, h.onError
+}
+
+// ServeHTTP handles a validation request or a notification request.
+func (h *WebhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// answer the validation request by returning the token as plain text
+	if token := r.URL.Query().Get("validationToken"); token != "" {
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		io.WriteString(w, token)
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	logger, validator, onError := h.settings()
+
+	var collection changeNotificationCollection
+	err := json.NewDecoder(io.LimitReader(r.Body, maxNotificationSize)).Decode(&collection)
+	if err == nil {
+		err = validateTokens(r.Context(), validator, collection)
+	}
+	if err != nil {
+		logger.Printf("invalid notification request: %v", err)
+		http.Error(w, "invalid notification", http.StatusBadRequest)
+		return
+	}
+
+	keys := h.decryptionKeys()
+
+	// handle every notification, since a failure would redeliver all of them
+	for _, n := range collection.Value {
+		if !h.validClientState(n.ClientState) {
+			logger.Printf("ignoring notification for subscription %s with invalid client state", n.SubscriptionID)
+			continue
+		}
+
+		notification, err := decodeNotification(n, keys)
+		if err == nil {
+			err = h.handle(r.Context(), notification)
+		}
+		if err != nil {
+			if onError != nil {
+				onError(r.Context(), notification, err)
+			} else {
+				logger.Printf("notification %s for subscription %s failed: %v",
+					notification.ID, notification.SubscriptionID, err)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// validClientState returns true if clientState is the expected client state.
+func (h *WebhookHandler) validClientState(clientState string) bool {
+	return subtle.ConstantTimeCompare([]byte(clientState), []byte(h.clientState)) == 1
+}
+
+// validateTokens validates the validation tokens of collection using validator, if not nil.
+func validateTokens(ctx context.Context, validator *TokenValidator, collection changeNotificationCollection) error {
+	if validator == nil {
+		return nil
+	}
+
+	// tokens are required for notifications with resource data and lifecycle notifications
+	required := false
+	for _, n := range collection.Value {
+		if n.EncryptedContent != nil || n.LifecycleEvent != "" {
+			required = true
+		}
+	}
+
+	if required && len(collection.ValidationTokens) == 0 {
+		return fmt.Errorf("%w: no validation tokens", ErrInvalidValidationToken)
+	}
+
+	for _, token := range collection.ValidationTokens {
+		err := validator.Validate(ctx, token)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
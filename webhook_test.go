@@ -0,0 +1,168 @@
+/*
+Copyright 2021 Bill Nixon
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package msgraph4go
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// notify posts body to the notification URL handled by h, returning the response.
+func notify(t *testing.T, h http.Handler, body string) *http.Response {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/notify", strings.NewReader(body)))
+
+	return w.Result()
+}
+
+func TestWebhookHandlerValidation(t *testing.T) {
+	h := NewWebhookHandler("secret", func(ctx context.Context, notification Notification) error {
+		t.Error("handle called for a validation request")
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/notify?validationToken=Validation%3A+Testing+client+application+reachability", nil))
+
+	resp := w.Result()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+	if string(body) != "Validation: Testing client application reachability" {
+		t.Errorf("body = %q, want the validation token", body)
+	}
+
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/notify", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET status = %d, want %d", w.Code, http.StatusMethodNotAllowed)
+	}
+}
+
+func TestWebhookHandlerClientState(t *testing.T) {
+	var handled []string
+	h := NewWebhookHandler("secret", func(ctx context.Context, notification Notification) error {
+		handled = append(handled, notification.ID)
+		return nil
+	})
+
+	resp := notify(t, h, `{"value":[
+		{"id":"1","subscriptionId":"s","clientState":"secret","changeType":"created","resource":"me/messages/m1",
+		 "resourceData":{"@odata.type":"#Microsoft.Graph.Message","id":"m1"}},
+		{"id":"2","subscriptionId":"s","clientState":"forged","changeType":"created"},
+		{"id":"3","subscriptionId":"s","changeType":"created"}
+	]}`)
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	if len(handled) != 1 || handled[0] != "1" {
+		t.Errorf("handled = %v, want [1]", handled)
+	}
+
+	if resp := notify(t, h, `{"value":`); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("malformed request status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestWebhookHandlerPartialFailure(t *testing.T) {
+	var handled []string
+	h := NewWebhookHandler("secret", func(ctx context.Context, notification Notification) error {
+		handled = append(handled, notification.ID)
+		if notification.ID == "2" {
+			return errors.New("queue full")
+		}
+		return nil
+	})
+
+	failed := make(map[string]error)
+	h.SetErrorHandler(func(ctx context.Context, notification Notification, err error) {
+		failed[notification.ID] = err
+	})
+
+	resp := notify(t, h, `{"value":[
+		{"id":"1","subscriptionId":"s","clientState":"secret"},
+		{"id":"2","subscriptionId":"s","clientState":"secret"},
+		{"id":"3","subscriptionId":"s","clientState":"secret",
+		 "encryptedContent":{"data":"","dataSignature":"","dataKey":"","encryptionCertificateId":"unknown"}},
+		{"id":"4","subscriptionId":"s","clientState":"secret"}
+	]}`)
+
+	// the request is acknowledged, so the handled notifications aren't sent again
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	if strings.Join(handled, ",") != "1,2,4" {
+		t.Errorf("handled = %v, want [1 2 4]", handled)
+	}
+	if len(failed) != 2 || failed["2"] == nil || failed["3"] == nil {
+		t.Errorf("failed = %v, want 2 and 3", failed)
+	}
+}
+
+func TestWebhookHandlerRequiresValidationTokens(t *testing.T) {
+	h := NewWebhookHandler("secret", func(ctx context.Context, notification Notification) error {
+		t.Error("handle called without validation tokens")
+		return nil
+	})
+	h.SetTokenValidator(NewTokenValidator("client"))
+
+	resp := notify(t, h, `{"value":[{"id":"1","subscriptionId":"s","clientState":"secret","lifecycleEvent":"missed"}]}`)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestWebhookHandlerConcurrentSettings(t *testing.T) {
+	h := NewWebhookHandler("secret", func(ctx context.Context, notification Notification) error {
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 100; n++ {
+			h.SetTokenValidator(nil)
+			h.SetLogger(nil)
+			h.AddDecryptionKey("cert", nil)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for n := 0; n < 100; n++ {
+			notify(t, h, `{"value":[{"id":"1","subscriptionId":"s","clientState":"secret"}]}`)
+		}
+	}()
+
+	wg.Wait()
+}